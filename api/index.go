@@ -17,7 +17,7 @@ import (
 var e *echo.Echo
 
 func init() {
-	// true 表示 Vercel 环境, nil for WaitGroup as Vercel doesn't run background tasks like ProcessStats
+	// true 表示 Vercel 环境, nil for WaitGroup as Vercel doesn't wait for background tasks such as ProcessStats
 	appInstance, _, err := bootstrap.SetupApp(true, nil, nil)
 	if err != nil {
 		// 在 Vercel 的 init 中，错误通常会导致部署失败或函数无法启动
@@ -29,8 +29,8 @@ func init() {
 	// SetupApp(true, nil) 内部已经处理了：
 	// - 从 os.Getenv("PROXIES_CONFIG") 加载代理配置
 	// - 初始化 Echo 实例 (e)
-	// - 根据 Vercel 环境跳过数据库和统计功能初始化
-	// - 注册路由 (包括代理路由，但不包括 /api/stats 和 StatsMiddleware)
+	// - 跳过数据库初始化，统计数据使用内存模式（实例回收后丢失），并启动 ProcessStats
+	// - 注册路由 (包括代理路由、/api/stats 和 StatsMiddleware)
 
 	// 可选：为Vercel函数URL本身添加根路径处理器。
 	// 如果 bootstrap.SetupApp 没有处理这个，可以在这里添加。
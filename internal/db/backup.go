@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ========================================
+// 备份与恢复
+// ========================================
+
+// ErrBackupUnsupported 表示当前数据库后端不支持在线备份。
+var ErrBackupUnsupported = errors.New("当前数据库不支持在线备份，PostgreSQL 请使用 pg_dump")
+
+const (
+	// DefaultBackupDir 是未配置 backup.dir 时的备份目录
+	DefaultBackupDir = "data/backups"
+	// DefaultBackupKeep 是未配置 backup.keep 时保留的备份数量
+	DefaultBackupKeep = 7
+
+	backupPrefix     = "stats-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102-150405"
+)
+
+// backupMu 串行化 CreateBackup，定时备份与管理接口同时触发时不会争用同一个文件名
+var backupMu sync.Mutex
+
+// BackupInfo 是备份目录中的一个备份文件。
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupTo 把当前数据库的一致性快照写入 path，服务运行期间也可以执行。
+// 先写入临时文件再重命名，path 上不会出现不完整的备份。
+func BackupTo(ctx context.Context, path string) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("备份文件 %s 已存在", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := store.Backup(ctx, db.DB, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// CreateBackup 在 dir 中生成一个以时间命名的备份，并只保留最近 keep 个。
+// 同一秒内的多个备份依次加上 -1、-2 等序号。dir 为空时使用 DefaultBackupDir，keep <= 0 时使用 DefaultBackupKeep。
+func CreateBackup(ctx context.Context, dir string, keep int) (*BackupInfo, error) {
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	stamp := time.Now().Format(backupTimeLayout)
+	name := backupPrefix + stamp + backupSuffix
+	path := filepath.Join(dir, name)
+	for seq := 1; ; seq++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		name = backupPrefix + stamp + "-" + strconv.Itoa(seq) + backupSuffix
+		path = filepath.Join(dir, name)
+	}
+
+	start := time.Now()
+	if err := BackupTo(ctx, path); err != nil {
+		log.Printf("创建数据库备份 %s 失败: %v", path, err)
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("已创建数据库备份 %s（%d 字节，耗时 %s）。", path, fi.Size(), time.Since(start).Round(time.Millisecond))
+
+	if err := PruneBackups(dir, keep); err != nil {
+		log.Printf("清理旧备份失败: %v", err)
+	}
+	return &BackupInfo{Name: name, Size: fi.Size(), CreatedAt: fi.ModTime()}, nil
+}
+
+// ListBackups 返回 dir 中由 CreateBackup 生成的备份，按时间从新到旧排列。
+func ListBackups(dir string) ([]BackupInfo, error) {
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		info  BackupInfo
+		stamp time.Time
+		seq   int
+	}
+	var found []entry
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		stamp, seq, ok := parseBackupName(e.Name())
+		if !ok {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, entry{BackupInfo{Name: e.Name(), Size: fi.Size(), CreatedAt: fi.ModTime()}, stamp, seq})
+	}
+	// 按文件名中的时间与序号排序
+	slices.SortFunc(found, func(a, b entry) int {
+		if c := b.stamp.Compare(a.stamp); c != 0 {
+			return c
+		}
+		return b.seq - a.seq
+	})
+
+	backups := make([]BackupInfo, len(found))
+	for i, e := range found {
+		backups[i] = e.info
+	}
+	return backups, nil
+}
+
+// parseBackupName 解析 stats-<时间>[-<序号>].db 形式的备份文件名。
+func parseBackupName(name string) (time.Time, int, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, 0, false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	if len(rest) < len(backupTimeLayout) {
+		return time.Time{}, 0, false
+	}
+	stamp, err := time.ParseInLocation(backupTimeLayout, rest[:len(backupTimeLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	seq := 0
+	if suffix := rest[len(backupTimeLayout):]; suffix != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+		if err != nil || !strings.HasPrefix(suffix, "-") || n <= 0 {
+			return time.Time{}, 0, false
+		}
+		seq = n
+	}
+	return stamp, seq, true
+}
+
+// PruneBackups 删除 dir 中超出 keep 个的旧备份，keep <= 0 时使用 DefaultBackupKeep。
+func PruneBackups(dir string, keep int) error {
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+	if keep <= 0 {
+		keep = DefaultBackupKeep
+	}
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(dir, b.Name)); err != nil {
+			return err
+		}
+		log.Printf("已删除旧备份 %s。", b.Name)
+	}
+	return nil
+}
+
+// BackupPath 返回备份目录中名为 name 的备份文件路径，name 不是 ListBackups 中的文件时返回错误。
+func BackupPath(dir, name string) (string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+	for _, b := range backups {
+		if b.Name == name {
+			return filepath.Join(dir, name), nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// ValidateBackup 检查 path 是完整的 go-proxy SQLite 数据库，返回其结构版本。
+// 结构版本高于当前程序支持的版本时返回 ErrSchemaTooNew。
+func ValidateBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	// 只读打开，避免在备份文件旁创建新的数据库或日志文件
+	file, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var result string
+	if err := file.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("无法读取备份文件: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("备份文件已损坏: %s", result)
+	}
+
+	var version int
+	if err := file.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("备份文件中没有 schema_migrations 表，不是 go-proxy 数据库: %w", err)
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("备份文件的结构版本为 0，不是完整的 go-proxy 数据库")
+	}
+	if latest := LatestSchemaVersion(); version > latest {
+		return version, fmt.Errorf("%w：备份为 %d，程序支持 %d", ErrSchemaTooNew, version, latest)
+	}
+	return version, nil
+}
+
+// RestoreBackup 校验备份文件 src 后用它替换 SQLite DSN 对应的数据库文件，调用前必须停止使用该数据库的服务。
+// 原数据库及其 -wal、-shm 文件会重命名为 <数据库>.before-restore-<时间>，返回该路径；原数据库不存在时返回空字符串。
+func RestoreBackup(src, dsn string) (string, error) {
+	dst, err := sqliteFilePath(dsn)
+	if err != nil {
+		return "", err
+	}
+	version, err := ValidateBackup(src)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("备份文件 %s 校验通过，结构版本 %d。", src, version)
+
+	// 先复制到 dst 所在目录，保证最后一步重命名是原子的
+	tmp := dst + ".restoring"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	var saved string
+	if _, err := os.Stat(dst); err == nil {
+		saved = dst + ".before-restore-" + time.Now().Format(backupTimeLayout)
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if err := os.Rename(dst+suffix, saved+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+				os.Remove(tmp)
+				return "", fmt.Errorf("保存原数据库失败: %w", err)
+			}
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return saved, err
+	}
+	return saved, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-proxy/pkg/config"
+)
+
+func TestSQLiteFilePath(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		want    string
+		wantErr bool
+	}{
+		{dsn: "", want: DefaultSQLitePath},
+		{dsn: "data/stats.db", want: "data/stats.db"},
+		{dsn: "/var/lib/go-proxy/stats.db", want: "/var/lib/go-proxy/stats.db"},
+		{dsn: "file:data/stats.db", want: "data/stats.db"},
+		{dsn: "file:data/stats.db?_pragma=busy_timeout(5000)&_txlock=immediate", want: "data/stats.db"},
+		{dsn: "file:///var/lib/go-proxy/stats.db?cache=shared", want: "/var/lib/go-proxy/stats.db"},
+		{dsn: "file:data/my%20stats.db", want: "data/my stats.db"},
+		{dsn: ":memory:", wantErr: true},
+		{dsn: "file::memory:?cache=shared", wantErr: true},
+		{dsn: "file:stats?mode=memory", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := sqliteFilePath(tt.dsn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sqliteFilePath(%q) error = %v, wantErr %v", tt.dsn, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqliteFilePath(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		name string
+		seq  int
+		ok   bool
+	}{
+		{name: "stats-20261019-072000.db", ok: true},
+		{name: "stats-20261019-072000-2.db", seq: 2, ok: true},
+		{name: "stats-20261019-072000-x.db"},
+		{name: "stats-20261019-072000-0.db"},
+		{name: "stats-20261019.db"},
+		{name: "stats-20261019-072000.db.tmp"},
+		{name: "other-20261019-072000.db"},
+	}
+	for _, tt := range tests {
+		_, seq, ok := parseBackupName(tt.name)
+		if ok != tt.ok || seq != tt.seq {
+			t.Errorf("parseBackupName(%q) = %d, %v, want %d, %v", tt.name, seq, ok, tt.seq, tt.ok)
+		}
+	}
+}
+
+// 同一秒内连续创建的备份不应冲突，并按创建顺序从新到旧列出。
+func TestCreateBackupSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "stats.db")
+	openTestDB(t, config.DatabaseConfig{Driver: DriverSQLite, DSN: dsn})
+
+	backupDir := filepath.Join(dir, "backups")
+	var names []string
+	for range 3 {
+		info, err := CreateBackup(context.Background(), backupDir, 10)
+		if err != nil {
+			t.Fatalf("CreateBackup: %v", err)
+		}
+		names = append(names, info.Name)
+	}
+
+	list, err := ListBackups(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("ListBackups 返回 %d 个备份, want 3", len(list))
+	}
+	// 若恰好跨秒，后创建的备份时间更晚，顺序依然成立
+	for i, b := range list {
+		if want := names[len(names)-1-i]; b.Name != want {
+			t.Errorf("ListBackups[%d] = %s, want %s", i, b.Name, want)
+		}
+	}
+
+	if err := PruneBackups(backupDir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := ListBackups(backupDir); len(list) != 1 || list[0].Name != names[2] {
+		t.Errorf("PruneBackups 后保留 %+v, want %s", list, names[2])
+	}
+
+	// 恢复时 file: DSN 解析为数据库文件路径
+	saved, err := RestoreBackup(filepath.Join(backupDir, names[2]), "file:"+dsn+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if saved == "" {
+		t.Fatal("RestoreBackup 未保存原数据库")
+	}
+	if _, err := os.Stat(saved); err != nil {
+		t.Errorf("原数据库未保留: %v", err)
+	}
+	if _, err := os.Stat(dsn); err != nil {
+		t.Errorf("恢复后的数据库不存在: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-proxy/pkg/balance"
+)
+
+// ========================================
+// 余额历史
+// ========================================
+
+// InsertBalanceSnapshot 记录一次成功的余额查询结果。
+func InsertBalanceSnapshot(b balance.Balance) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, err := db.Exec(`
+		INSERT INTO balance_history (account, provider, currency, balance, total, used)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		b.Name, b.Provider, b.Currency, b.Balance, b.Total, b.Used,
+	)
+	if err != nil {
+		log.Printf("记录余额历史 (account: %s) 时出错: %v", b.Name, err)
+	}
+	return err
+}
+
+// GetBalanceHistory 返回某个账户 since 之后的余额点，按时间升序排列。
+func GetBalanceHistory(account string, since time.Time) ([]balance.Point, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	rows, err := db.Query(`
+		SELECT timestamp, balance FROM balance_history
+		WHERE account = ? AND timestamp >= ?
+		ORDER BY timestamp ASC`,
+		account, since.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		log.Printf("查询余额历史 (account: %s) 时出错: %v", account, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []balance.Point{}
+	for rows.Next() {
+		var p balance.Point
+		if err := rows.Scan(&p.Timestamp, &p.Balance); err != nil {
+			log.Printf("扫描余额历史行时出错: %v", err)
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetBalanceAlerts 返回各余额告警最近一次发送的时间，键为告警标识（账户|类型）。
+func GetBalanceAlerts() (map[string]time.Time, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	rows, err := db.Query(`SELECT alert_key, sent_at FROM balance_alerts`)
+	if err != nil {
+		log.Printf("查询余额告警记录时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make(map[string]time.Time)
+	for rows.Next() {
+		var key string
+		var sentAt time.Time
+		if err := rows.Scan(&key, &sentAt); err != nil {
+			return nil, err
+		}
+		alerts[key] = sentAt
+	}
+	return alerts, rows.Err()
+}
+
+// MarkBalanceAlert 记录余额告警的发送时间。
+func MarkBalanceAlert(key string, sentAt time.Time) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, err := db.Exec(`
+		INSERT INTO balance_alerts (alert_key, sent_at) VALUES (?, ?)
+		ON CONFLICT (alert_key) DO UPDATE SET sent_at = excluded.sent_at`,
+		key, sentAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		log.Printf("保存余额告警记录时出错: %v", err)
+	}
+	return err
+}
+
+// ClearBalanceAlert 在告警条件解除后删除记录，条件再次出现时立即通知。
+func ClearBalanceAlert(key string) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, err := db.Exec(`DELETE FROM balance_alerts WHERE alert_key = ?`, key)
+	if err != nil {
+		log.Printf("删除余额告警记录时出错: %v", err)
+	}
+	return err
+}
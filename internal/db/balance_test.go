@@ -0,0 +1,36 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-proxy/pkg/config"
+)
+
+// 余额告警的发送时间需要跨重启保留。
+func TestBalanceAlerts(t *testing.T) {
+	openTestDB(t, config.DatabaseConfig{Driver: DriverSQLite, DSN: filepath.Join(t.TempDir(), "stats.db")})
+
+	sent := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	if err := MarkBalanceAlert("main|low", sent.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkBalanceAlert("main|low", sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkBalanceAlert("main|burn", sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClearBalanceAlert("main|burn"); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts, err := GetBalanceAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || !alerts["main|low"].Equal(sent) {
+		t.Errorf("GetBalanceAlerts = %v, want main|low at %v", alerts, sent)
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// ========================================
+// 请求/响应体
+// ========================================
+
+// RequestBodyRecord 是 request_bodies 中的一条记录，内容已解压。
+type RequestBodyRecord struct {
+	RequestLogID      int64     `json:"request_log_id"`
+	ServiceName       string    `json:"service_name"`
+	ContentType       string    `json:"content_type"`
+	RequestHeaders    string    `json:"request_headers"`
+	Request           string    `json:"request"`
+	Response          string    `json:"response"`
+	RequestTruncated  bool      `json:"request_truncated"`
+	ResponseTruncated bool      `json:"response_truncated"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// GetRequestBody 返回 request_logs.id 对应的请求/响应体，不存在时返回 nil。
+func GetRequestBody(requestLogID int64) (*RequestBodyRecord, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var (
+		r          RequestBodyRecord
+		contentTyp sql.NullString
+		headers    sql.NullString
+		req, resp  []byte
+		compressed bool
+	)
+	err := db.QueryRow(`
+		SELECT request_log_id, service_name, content_type, request_headers, request_body, response_body,
+		       request_truncated, response_truncated, compressed, expires_at, timestamp
+		FROM request_bodies WHERE request_log_id = ?`, requestLogID,
+	).Scan(&r.RequestLogID, &r.ServiceName, &contentTyp, &headers, &req, &resp,
+		&r.RequestTruncated, &r.ResponseTruncated, &compressed, &r.ExpiresAt, &r.Timestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("查询 request_bodies (id: %d) 时出错: %v", requestLogID, err)
+		return nil, err
+	}
+	r.ContentType = contentTyp.String
+	r.RequestHeaders = headers.String
+
+	if compressed {
+		if req, err = gunzip(req); err != nil {
+			return nil, err
+		}
+		if resp, err = gunzip(resp); err != nil {
+			return nil, err
+		}
+	}
+	r.Request = string(req)
+	r.Response = string(resp)
+	return &r, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压请求体失败: %w", err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========================================
+// 预算
+// ========================================
+
+// UsageTotals 是一段时间内累计的 token 与费用。
+type UsageTotals struct {
+	Tokens int64   `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+// SumUsageSince 汇总 since 之后某个客户端密钥（column 为 client_key）或团队（column 为 team）的用量。
+func SumUsageSince(column, subject string, since time.Time) (UsageTotals, error) {
+	var totals UsageTotals
+	if db == nil {
+		return totals, fmt.Errorf("数据库未初始化")
+	}
+	if column != "client_key" && column != "team" {
+		return totals, fmt.Errorf("不支持的汇总维度: %s", column)
+	}
+	// request_logs.timestamp 由 CURRENT_TIMESTAMP 写入，为 UTC 文本
+	row := db.QueryRow(`
+		SELECT COALESCE(SUM(prompt_tokens + completion_tokens), 0), COALESCE(SUM(cost), 0)
+		FROM request_logs
+		WHERE `+column+` = ? AND timestamp >= ?`,
+		subject, since.UTC().Format(time.DateTime),
+	)
+	if err := row.Scan(&totals.Tokens, &totals.Cost); err != nil {
+		log.Printf("汇总 %s=%s 的用量时出错: %v", column, subject, err)
+		return totals, err
+	}
+	return totals, nil
+}
+
+// GetBudgetAlerts 返回某个预算周期内已发送过的通知级别。
+func GetBudgetAlerts(scope, subject, period, periodStart string) (map[string]bool, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	rows, err := db.Query(`
+		SELECT level FROM budget_alerts
+		WHERE scope = ? AND subject = ? AND period = ? AND period_start = ?`,
+		scope, subject, period, periodStart,
+	)
+	if err != nil {
+		log.Printf("查询预算通知记录时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := make(map[string]bool)
+	for rows.Next() {
+		var level string
+		if err := rows.Scan(&level); err != nil {
+			return nil, err
+		}
+		levels[level] = true
+	}
+	return levels, rows.Err()
+}
+
+// MarkBudgetAlert 记录某个预算周期内已发送的通知级别。
+func MarkBudgetAlert(scope, subject, period, periodStart, level string) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, err := db.Exec(`
+		INSERT INTO budget_alerts (scope, subject, period, period_start, level)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		scope, subject, period, periodStart, level,
+	)
+	if err != nil {
+		log.Printf("保存预算通知记录时出错: %v", err)
+	}
+	return err
+}
@@ -1,23 +1,23 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
+	"math"
 	"sync"
 	"time"
 
 	"go-proxy/pkg/config"
 	"go-proxy/pkg/types"
-
-	_ "modernc.org/sqlite" // 纯 Go SQLite driver，无需 CGO
 )
 
 var (
-	db   *sql.DB
-	once sync.Once
-	mu   sync.Mutex // To protect concurrent writes
+	db    *conn
+	store Store
+	once  sync.Once
+	mu    sync.Mutex // To protect concurrent writes
 )
 
 // ========================================
@@ -27,15 +27,22 @@ var (
 type statsCache struct {
 	mu            sync.RWMutex
 	stats         []Stat
-	daily         []DailyStat
-	distribution  []ServiceDistribution
 	statsExpiry   time.Time
-	dailyExpiry   time.Time
-	distExpiry    time.Time
+	queries       map[string]queryCacheEntry // 按查询参数缓存的时间序列与分布
 	cacheDuration time.Duration
 }
 
+// queryCacheEntry 是一组查询参数对应的缓存结果。
+type queryCacheEntry struct {
+	value  any
+	expiry time.Time
+}
+
+// maxCachedQueries 限制参数化查询的缓存条数，超出时整体清空
+const maxCachedQueries = 256
+
 var cache = &statsCache{
+	queries:       make(map[string]queryCacheEntry),
 	cacheDuration: 60 * time.Second,
 }
 
@@ -44,11 +51,35 @@ func InvalidateCache() {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 	cache.statsExpiry = time.Time{}
-	cache.dailyExpiry = time.Time{}
-	cache.distExpiry = time.Time{}
+	cache.queries = make(map[string]queryCacheEntry)
 	log.Println("统计缓存已失效。")
 }
 
+// cachedQuery 返回 key 对应的缓存结果，过期或不存在时调用 load 并缓存。
+func cachedQuery[T any](key string, load func() (T, error)) (T, error) {
+	cache.mu.RLock()
+	entry, ok := cache.queries[key]
+	cache.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiry) {
+		if v, ok := entry.value.(T); ok {
+			return v, nil
+		}
+	}
+
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+
+	cache.mu.Lock()
+	if len(cache.queries) >= maxCachedQueries {
+		cache.queries = make(map[string]queryCacheEntry)
+	}
+	cache.queries[key] = queryCacheEntry{value: v, expiry: time.Now().Add(cache.cacheDuration)}
+	cache.mu.Unlock()
+	return v, nil
+}
+
 // ========================================
 // 数据类型
 // ========================================
@@ -60,158 +91,80 @@ type Stat struct {
 	Vendor       string  `json:"vendor"`
 	Target       string  `json:"target"`
 	ResponseTime float64 `json:"response_time"` // 平均响应时间（毫秒）
+	// 最近7天的响应时间分位数（毫秒）
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
 }
 
-// DailyStat 表示某一天的调用次数统计。
+// DailyStat 表示一个时间段的调用次数统计，Date 为时间段的起点标签
+// （小时 2006-01-02 15:00、天与周 2006-01-02、月 2006-01）。
 type DailyStat struct {
-	Date         string `json:"date"`
-	RequestCount int    `json:"request_count"`
+	Date          string `json:"date"`
+	RequestCount  int    `json:"request_count"`
+	RequestBytes  int64  `json:"request_bytes"`
+	ResponseBytes int64  `json:"response_bytes"`
 }
 
 // ServiceDistribution 表示某个服务在时间范围内的调用次数。
 type ServiceDistribution struct {
-	ServiceName  string `json:"service_name"`
-	RequestCount int    `json:"request_count"`
+	ServiceName   string `json:"service_name"`
+	RequestCount  int    `json:"request_count"`
+	RequestBytes  int64  `json:"request_bytes"`
+	ResponseBytes int64  `json:"response_bytes"`
 }
 
 // ========================================
 // 初始化
 // ========================================
 
-// InitDB 初始化SQLite数据库连接并创建必要的表。
-func InitDB(dataSourceName string, isVercelEnv bool) error {
-	if isVercelEnv {
-		log.Println("在 Vercel 环境中运行，跳过 SQLite 数据库初始化。")
-		db = nil
-		return nil
-	}
-
+// InitDB 按配置打开数据库连接并执行尚未应用的结构迁移，cfg.Driver 为空时使用 SQLite。
+// Vercel 环境或 cfg.Driver 为 memory 时不使用数据库，统计数据保存在内存中。
+// 初始化失败后可以再次调用，例如回退到内存模式。
+func InitDB(cfg config.DatabaseConfig, isVercelEnv bool) error {
 	var err error
 	once.Do(func() {
-		dataDir := "data"
-		if _, statErr := os.Stat(dataDir); os.IsNotExist(statErr) {
-			if mkdirErr := os.Mkdir(dataDir, 0755); mkdirErr != nil {
-				err = fmt.Errorf("创建目录 '%s' 时出错: %v", dataDir, mkdirErr)
-				log.Printf(err.Error())
-				return
+		if isVercelEnv || cfg.Driver == DriverMemory {
+			if isVercelEnv {
+				log.Println("在 Vercel 环境中运行，跳过数据库初始化。")
 			}
-		}
-		db, err = sql.Open("sqlite", dataSourceName)
-		if err != nil {
-			log.Printf("打开数据库时出错: %v", err)
+			initMemory(cfg)
 			return
 		}
-
-		if err = db.Ping(); err != nil {
-			log.Printf("ping数据库时出错: %v", err)
-			db.Close()
-			db = nil
+		if err = OpenDB(cfg); err != nil {
 			return
 		}
-
-		// 启用 WAL 模式
-		_, err = db.Exec("PRAGMA journal_mode=WAL;")
-		if err != nil {
-			log.Printf("启用 WAL 模式时出错: %v", err)
-		} else {
-			log.Println("WAL 模式已成功启用。")
-		}
-
-		// 建表：request_stats
-		_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS request_stats (
-			service_name TEXT PRIMARY KEY,
-			request_count INTEGER NOT NULL DEFAULT 0
-		);`)
-		if err != nil {
-			log.Printf("创建 request_stats 表时出错: %v", err)
-			db.Close()
-			db = nil
-			return
-		}
-
-		// 建表：request_logs
-		_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS request_logs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			service_name TEXT NOT NULL,
-			host TEXT,
-			request_uri TEXT,
-			status_code INTEGER,
-			response_time INTEGER DEFAULT 0,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`)
-		if err != nil {
-			log.Printf("创建 request_logs 表时出错: %v", err)
-			db.Close()
-			db = nil
-			return
-		}
-
-		// 索引：request_logs
-		if _, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_request_logs_service_ts ON request_logs(service_name, timestamp DESC);`); err != nil {
-			log.Printf("创建 request_logs 索引时出错: %v", err)
-		}
-
-		// 兼容迁移：添加可能缺失的列
-		addColumnIfNotExists("request_logs", "status_code", "INTEGER DEFAULT 0")
-		addColumnIfNotExists("request_logs", "response_time", "INTEGER DEFAULT 0")
-
-		// 建表：proxy_config
-		_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS proxy_config (
-			path TEXT PRIMARY KEY,
-			target TEXT NOT NULL,
-			vendor TEXT
-		);`)
-		if err != nil {
-			log.Printf("创建 proxy_config 表时出错: %v", err)
-			db.Close()
-			db = nil
-			return
-		}
-
-		// 建表：daily_summary（预聚合表）
-		_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS daily_summary (
-			date TEXT NOT NULL,
-			service_name TEXT NOT NULL,
-			request_count INTEGER NOT NULL DEFAULT 0,
-			success_count INTEGER NOT NULL DEFAULT 0,
-			total_response_time INTEGER NOT NULL DEFAULT 0,
-			PRIMARY KEY (date, service_name)
-		);`)
-		if err != nil {
-			log.Printf("创建 daily_summary 表时出错: %v", err)
+		if _, err = Migrate(); err != nil {
+			log.Printf("数据库迁移失败: %v", err)
 			db.Close()
 			db = nil
+			store = nil
 			return
 		}
-
 		log.Println("数据库初始化成功。")
 	})
+	if err != nil {
+		once = sync.Once{}
+	}
 	return err
 }
 
-// addColumnIfNotExists 检查并添加缺失的列。
-func addColumnIfNotExists(table, column, colType string) {
-	var count int
-	row := db.QueryRow(fmt.Sprintf(
-		"SELECT COUNT(*) FROM pragma_table_info('%s') WHERE name='%s'",
-		table, column,
-	))
-	if err := row.Scan(&count); err != nil {
-		log.Printf("检查 %s.%s 列时出错: %v", table, column, err)
-		return
-	}
-	if count == 0 {
-		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, colType))
-		if err != nil {
-			log.Printf("添加 %s.%s 列时出错: %v", table, column, err)
-			return
-		}
-		log.Printf("成功添加 %s 列到 %s 表", column, table)
+// OpenDB 只打开数据库连接，不执行迁移，供 `go-proxy migrate` 等命令使用。
+func OpenDB(cfg config.DatabaseConfig) error {
+	s, err := newStore(cfg.Driver, cfg.Timezone)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	sqlDB, err := s.Open(cfg.DSN)
+	if err != nil {
+		log.Printf("打开数据库时出错: %v", err)
+		return err
 	}
+	store = s
+	db = &conn{DB: sqlDB, store: s}
+	return nil
 }
 
 // CloseDB 关闭数据库连接。
@@ -245,51 +198,87 @@ func IsSummaryEmpty() bool {
 	return count == 0
 }
 
-// AggregateDaily 将 request_logs 中指定天数内的数据聚合到 daily_summary 表。
+// AggregateDaily 将 request_logs 中指定天数内的数据聚合到 daily_summary 表，包括响应时间直方图。
 // daysBack: 向前聚合多少天。首次运行传较大值覆盖全部历史，后续传1即可。
 func AggregateDaily(daysBack int) error {
+	if mem != nil {
+		return nil // 内存模式在写入时已经汇总
+	}
 	if db == nil {
 		return fmt.Errorf("数据库未初始化")
 	}
 
-	query := fmt.Sprintf(`
-	INSERT OR REPLACE INTO daily_summary (date, service_name, request_count, success_count, total_response_time)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("开始聚合事务时出错: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	since := time.Now().AddDate(0, 0, -daysBack).Format(time.DateOnly)
+	day := store.LocalDate("timestamp")
+	query := `
+	INSERT INTO daily_summary (date, service_name, request_count, success_count, total_response_time,
+		request_bytes, response_bytes, latency_histogram)
 	SELECT 
-		date(timestamp, 'localtime') AS day,
+		` + day + ` AS day,
 		service_name,
 		COUNT(*) AS request_count,
 		SUM(CASE WHEN status_code BETWEEN 200 AND 299 THEN 1 ELSE 0 END) AS success_count,
-		SUM(CASE WHEN response_time > 0 AND response_time < 60000 THEN response_time ELSE 0 END) AS total_response_time
+		SUM(CASE WHEN response_time > 0 AND response_time < 60000 THEN response_time ELSE 0 END) AS total_response_time,
+		COALESCE(SUM(request_bytes), 0) AS request_bytes,
+		COALESCE(SUM(response_bytes), 0) AS response_bytes,
+		? AS latency_histogram
 	FROM request_logs
-	WHERE date(timestamp, 'localtime') >= date('now', 'localtime', '-%d days')
-	GROUP BY day, service_name;
-	`, daysBack)
+	WHERE ` + day + ` >= ?
+	GROUP BY day, service_name
+	ON CONFLICT (date, service_name) DO UPDATE SET
+		request_count = excluded.request_count,
+		success_count = excluded.success_count,
+		total_response_time = excluded.total_response_time,
+		request_bytes = excluded.request_bytes,
+		response_bytes = excluded.response_bytes,
+		latency_histogram = excluded.latency_histogram;
+	`
 
-	_, err := db.Exec(query)
-	if err != nil {
+	if _, err = tx.Exec(query, emptyLatencyHistogram, since); err != nil {
 		log.Printf("聚合每日统计时出错: %v", err)
 		return err
 	}
+	if err = aggregateLatency(tx, "daily_summary", "date", day, day+" >= ?", since); err != nil {
+		log.Printf("聚合响应时间直方图时出错: %v", err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("提交聚合事务时出错: %v", err)
+		return err
+	}
 	log.Printf("每日统计聚合完成（回溯 %d 天）。", daysBack)
 	return nil
 }
 
-// CleanupOldData 删除超过指定天数的 request_logs 和 daily_summary 数据。
+// CleanupOldData 删除超过指定天数的 request_logs、daily_summary 和 balance_history 数据。
 // doVacuum 为 true 时额外执行 VACUUM 归还磁盘空间（耗时较长，建议每天执行一次）。
 func CleanupOldData(retentionDays int, doVacuum bool) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	if mem != nil {
+		// 请求日志由环形缓冲区限制条数，这里只淘汰每日汇总
+		if n := mem.prune(mem.daily, cutoff.Format(time.DateOnly)); n > 0 {
+			log.Printf("已清理 %d 条过期的内存每日汇总。", n)
+		}
+		return nil
+	}
 	if db == nil {
 		return fmt.Errorf("数据库未初始化")
 	}
 
 	// 分批删除 request_logs，每次最多删除 10000 条，避免长时间锁表
-	deleteLogsQuery := fmt.Sprintf(`
+	result, err := db.Exec(`
 	DELETE FROM request_logs WHERE id IN (
 		SELECT id FROM request_logs 
-		WHERE timestamp < datetime('now', 'localtime', '-%d days')
+		WHERE timestamp < ?
 		LIMIT 10000
-	);`, retentionDays)
-
-	result, err := db.Exec(deleteLogsQuery)
+	);`, cutoff.UTC().Format(time.DateTime))
 	if err != nil {
 		log.Printf("清理旧 request_logs 时出错: %v", err)
 		return err
@@ -299,11 +288,7 @@ func CleanupOldData(retentionDays int, doVacuum bool) error {
 	}
 
 	// 清理 daily_summary
-	deleteSummaryQuery := fmt.Sprintf(`
-	DELETE FROM daily_summary WHERE date < date('now', 'localtime', '-%d days');
-	`, retentionDays)
-
-	result, err = db.Exec(deleteSummaryQuery)
+	result, err = db.Exec(`DELETE FROM daily_summary WHERE date < ?;`, cutoff.Format(time.DateOnly))
 	if err != nil {
 		log.Printf("清理旧 daily_summary 时出错: %v", err)
 		return err
@@ -312,11 +297,30 @@ func CleanupOldData(retentionDays int, doVacuum bool) error {
 		log.Printf("已清理 %d 条过期 daily_summary 记录。", rows)
 	}
 
+	// 清理 balance_history
+	result, err = db.Exec(`DELETE FROM balance_history WHERE timestamp < ?;`, cutoff.UTC().Format(time.DateTime))
+	if err != nil {
+		log.Printf("清理旧 balance_history 时出错: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		log.Printf("已清理 %d 条过期 balance_history 记录。", rows)
+	}
+
+	// 清理 request_bodies，保留期限在写入时按代理配置计算，与 retentionDays 无关
+	result, err = db.Exec(`DELETE FROM request_bodies WHERE expires_at < ?;`, time.Now().UTC().Format(time.DateTime))
+	if err != nil {
+		log.Printf("清理过期 request_bodies 时出错: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		log.Printf("已清理 %d 条过期 request_bodies 记录。", rows)
+	}
+
 	// VACUUM：归还已删除数据占用的磁盘空间。
-	// SQLite DELETE 只将页面加入 freelist，不会自动缩减文件，必须显式执行 VACUUM。
 	if doVacuum {
 		log.Println("开始执行 VACUUM，回收数据库磁盘空间...")
-		if _, err = db.Exec("VACUUM;"); err != nil {
+		if err = store.Vacuum(context.Background(), db.DB); err != nil {
 			log.Printf("VACUUM 执行失败: %v", err)
 		} else {
 			log.Println("VACUUM 完成，数据库文件已压缩。")
@@ -351,48 +355,6 @@ func GetStatsWithCache() ([]Stat, error) {
 	return stats, nil
 }
 
-// GetDailyStatsWithCache 带缓存的每日统计查询。
-func GetDailyStatsWithCache() ([]DailyStat, error) {
-	cache.mu.RLock()
-	if time.Now().Before(cache.dailyExpiry) && cache.daily != nil {
-		defer cache.mu.RUnlock()
-		return cache.daily, nil
-	}
-	cache.mu.RUnlock()
-
-	stats, err := GetDailyStatsLast7Days()
-	if err != nil {
-		return nil, err
-	}
-
-	cache.mu.Lock()
-	cache.daily = stats
-	cache.dailyExpiry = time.Now().Add(cache.cacheDuration)
-	cache.mu.Unlock()
-	return stats, nil
-}
-
-// GetDistributionWithCache 带缓存的服务分布查询。
-func GetDistributionWithCache() ([]ServiceDistribution, error) {
-	cache.mu.RLock()
-	if time.Now().Before(cache.distExpiry) && cache.distribution != nil {
-		defer cache.mu.RUnlock()
-		return cache.distribution, nil
-	}
-	cache.mu.RUnlock()
-
-	stats, err := GetServiceDistributionLast7Days()
-	if err != nil {
-		return nil, err
-	}
-
-	cache.mu.Lock()
-	cache.distribution = stats
-	cache.distExpiry = time.Now().Add(cache.cacheDuration)
-	cache.mu.Unlock()
-	return stats, nil
-}
-
 // ========================================
 // 统计查询（改造后，从 daily_summary 读取）
 // ========================================
@@ -400,6 +362,9 @@ func GetDistributionWithCache() ([]ServiceDistribution, error) {
 // GetStats 从数据库中检索所有请求统计信息。
 // 平均响应时间改为从 daily_summary 计算最近7天的平均值。
 func GetStats() ([]Stat, error) {
+	if mem != nil {
+		return mem.stats(), nil
+	}
 	if db == nil {
 		return nil, fmt.Errorf("数据库未初始化")
 	}
@@ -412,18 +377,18 @@ func GetStats() ([]Stat, error) {
 		COALESCE(rs.request_count, 0) AS request_count,
 		pc.vendor,
 		pc.target,
-		COALESCE(ROUND(
-			(SELECT CAST(SUM(total_response_time) AS REAL) / NULLIF(SUM(request_count), 0)
+		COALESCE(
+			(SELECT CAST(SUM(total_response_time) AS DOUBLE PRECISION) / NULLIF(SUM(request_count), 0)
 			 FROM daily_summary
 			 WHERE service_name = pc.path
-			 AND date >= date('now','localtime','-7 days')
-			 ), 2), 0) AS response_time
+			 AND date >= ?
+			 ), 0) AS response_time
 	FROM proxy_config pc
 	LEFT JOIN request_stats rs ON pc.path = rs.service_name
 	ORDER BY COALESCE(rs.request_count, 0) DESC
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, time.Now().AddDate(0, 0, -7).Format(time.DateOnly))
 	if err != nil {
 		log.Printf("查询统计信息时出错: %v", err)
 		return nil, err
@@ -443,6 +408,7 @@ func GetStats() ([]Stat, error) {
 			log.Printf("扫描统计信息行时出错: %v", err)
 			continue
 		}
+		s.ResponseTime = math.Round(s.ResponseTime*100) / 100
 		stats = append(stats, s)
 	}
 
@@ -451,89 +417,16 @@ func GetStats() ([]Stat, error) {
 		return nil, err
 	}
 
-	return stats, nil
-}
-
-// GetDailyStatsLast7Days 返回最近7天（含今天）的每日请求次数。
-// 改为从 daily_summary 表查询。
-func GetDailyStatsLast7Days() ([]DailyStat, error) {
-	if db == nil {
-		return nil, fmt.Errorf("数据库未初始化")
-	}
-
-	query := `
-	WITH RECURSIVE dates(day) AS (
-	    SELECT date('now','localtime','-6 days')
-	    UNION ALL
-	    SELECT date(day,'+1 day') FROM dates WHERE day < date('now','localtime')
-	)
-	SELECT d.day AS date, COALESCE(SUM(ds.request_count), 0) AS request_count
-	FROM dates d
-	LEFT JOIN daily_summary ds ON d.day = ds.date
-	GROUP BY d.day
-	ORDER BY d.day;
-	`
-
-	rows, err := db.Query(query)
+	// 分位数与平均响应时间使用同一时间窗口
+	latency, err := recentLatencyByService(7)
 	if err != nil {
-		log.Printf("查询每日统计信息时出错: %v", err)
-		return nil, err
+		log.Printf("查询响应时间分位数时出错: %v", err)
 	}
-	defer rows.Close()
-
-	stats := []DailyStat{}
-	for rows.Next() {
-		var s DailyStat
-		if err := rows.Scan(&s.Date, &s.RequestCount); err != nil {
-			log.Printf("扫描每日统计信息行时出错: %v", err)
-			continue
+	for i := range stats {
+		if h, ok := latency[stats[i].ServiceName]; ok {
+			p := h.Percentiles()
+			stats[i].P50, stats[i].P90, stats[i].P95, stats[i].P99 = p.P50, p.P90, p.P95, p.P99
 		}
-		stats = append(stats, s)
-	}
-
-	if err = rows.Err(); err != nil {
-		log.Printf("迭代每日统计信息行时出错: %v", err)
-		return nil, err
-	}
-
-	return stats, nil
-}
-
-// GetServiceDistributionLast7Days 返回最近7天内各服务的调用次数分布。
-// 改为从 daily_summary 表查询。
-func GetServiceDistributionLast7Days() ([]ServiceDistribution, error) {
-	if db == nil {
-		return nil, fmt.Errorf("数据库未初始化")
-	}
-
-	query := `
-	SELECT service_name, SUM(request_count) AS request_count
-	FROM daily_summary
-	WHERE date >= date('now','localtime','-6 days')
-	GROUP BY service_name
-	ORDER BY request_count DESC;
-	`
-
-	rows, err := db.Query(query)
-	if err != nil {
-		log.Printf("查询服务调用分布时出错: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
-
-	stats := []ServiceDistribution{}
-	for rows.Next() {
-		var s ServiceDistribution
-		if err := rows.Scan(&s.ServiceName, &s.RequestCount); err != nil {
-			log.Printf("扫描服务调用分布行时出错: %v", err)
-			continue
-		}
-		stats = append(stats, s)
-	}
-
-	if err = rows.Err(); err != nil {
-		log.Printf("迭代服务调用分布行时出错: %v", err)
-		return nil, err
 	}
 
 	return stats, nil
@@ -545,6 +438,10 @@ func GetServiceDistributionLast7Days() ([]ServiceDistribution, error) {
 
 // BatchLogRequestDetails 批量记录请求的详细信息。
 func BatchLogRequestDetails(stats []types.RequestStat) error {
+	if mem != nil {
+		mem.record(stats)
+		return nil
+	}
 	if db == nil {
 		return fmt.Errorf("数据库未初始化")
 	}
@@ -561,8 +458,11 @@ func BatchLogRequestDetails(stats []types.RequestStat) error {
 
 	stmt, err := tx.Prepare(`
 		INSERT INTO request_logs 
-		(service_name, host, request_uri, status_code, response_time) 
-		VALUES (?, ?, ?, ?, ?)
+		(service_name, host, request_uri, status_code, response_time,
+		 model, client_key, team, prompt_tokens, completion_tokens, cost,
+		 method, client_ip, user_agent, request_bytes, response_bytes, upstream, request_id) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
 	`)
 	if err != nil {
 		log.Printf("准备批量插入 request_logs 语句时出错: %v", err)
@@ -570,16 +470,64 @@ func BatchLogRequestDetails(stats []types.RequestStat) error {
 	}
 	defer stmt.Close()
 
+	var bodyStmt *sql.Stmt
 	for _, stat := range stats {
-		_, err := stmt.Exec(
+		var id int64
+		err := stmt.QueryRow(
 			stat.ServiceName,
 			stat.Host,
 			stat.RequestURI,
 			stat.StatusCode,
 			stat.ResponseTime,
-		)
+			stat.Model,
+			stat.ClientKey,
+			stat.Team,
+			stat.PromptTokens,
+			stat.CompletionTokens,
+			stat.Cost,
+			stat.Method,
+			stat.ClientIP,
+			stat.UserAgent,
+			stat.RequestBytes,
+			stat.ResponseBytes,
+			stat.Upstream,
+			stat.RequestID,
+		).Scan(&id)
 		if err != nil {
 			log.Printf("执行批量插入 request_logs (service: %s) 时出错: %v", stat.ServiceName, err)
+			continue
+		}
+		if stat.Body == nil {
+			continue
+		}
+
+		if bodyStmt == nil {
+			bodyStmt, err = tx.Prepare(`
+				INSERT INTO request_bodies
+				(request_log_id, service_name, content_type, request_headers, request_body, response_body,
+				 request_truncated, response_truncated, compressed, expires_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`)
+			if err != nil {
+				log.Printf("准备插入 request_bodies 语句时出错: %v", err)
+				return err
+			}
+			defer bodyStmt.Close()
+		}
+		b := stat.Body
+		if _, err := bodyStmt.Exec(
+			id,
+			stat.ServiceName,
+			b.ContentType,
+			b.RequestHeaders,
+			b.Request,
+			b.Response,
+			boolInt(b.RequestTruncated),
+			boolInt(b.ResponseTruncated),
+			boolInt(b.Compressed),
+			b.ExpiresAt.UTC().Format(time.DateTime),
+		); err != nil {
+			log.Printf("插入 request_bodies (service: %s) 时出错: %v", stat.ServiceName, err)
 		}
 	}
 
@@ -588,6 +536,10 @@ func BatchLogRequestDetails(stats []types.RequestStat) error {
 
 // BatchIncrementRequestCounts 批量增加指定服务的请求计数。
 func BatchIncrementRequestCounts(counts map[string]int) error {
+	if mem != nil {
+		mem.incrementCounts(counts)
+		return nil
+	}
 	if db == nil {
 		return fmt.Errorf("数据库未初始化")
 	}
@@ -626,6 +578,10 @@ func BatchIncrementRequestCounts(counts map[string]int) error {
 
 // UpdateProxyConfig 更新代理配置到数据库。
 func UpdateProxyConfig(configs []config.ProxyConfig) error {
+	if mem != nil {
+		mem.setProxies(configs)
+		return nil
+	}
 	if db == nil {
 		return fmt.Errorf("数据库未初始化")
 	}
@@ -658,3 +614,67 @@ func UpdateProxyConfig(configs []config.ProxyConfig) error {
 
 	return tx.Commit()
 }
+
+// ScrubRequestURIs 用 scrub 重写 request_logs 中已保存的 request_uri，返回被修改的行数。
+// 用于清理启用脱敏之前写入的敏感查询参数，按 id 分批处理以避免长时间锁表。
+func ScrubRequestURIs(scrub func(string) string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	const batchSize = 1000
+
+	var lastID, updated int64
+	for {
+		rows, err := db.Query(`
+			SELECT id, request_uri FROM request_logs
+			WHERE id > ? AND request_uri LIKE '%?%'
+			ORDER BY id LIMIT ?`, lastID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+		changes := make(map[int64]string)
+		n := 0
+		for rows.Next() {
+			var id int64
+			var uri string
+			if err := rows.Scan(&id, &uri); err != nil {
+				rows.Close()
+				return updated, err
+			}
+			n++
+			lastID = id
+			if scrubbed := scrub(uri); scrubbed != uri {
+				changes[id] = scrubbed
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+
+		if len(changes) > 0 {
+			mu.Lock()
+			tx, err := db.Begin()
+			if err != nil {
+				mu.Unlock()
+				return updated, err
+			}
+			for id, uri := range changes {
+				if _, err := tx.Exec(`UPDATE request_logs SET request_uri = ? WHERE id = ?`, uri, id); err != nil {
+					tx.Rollback()
+					mu.Unlock()
+					return updated, err
+				}
+			}
+			err = tx.Commit()
+			mu.Unlock()
+			if err != nil {
+				return updated, err
+			}
+			updated += int64(len(changes))
+		}
+		if n < batchSize {
+			return updated, nil
+		}
+	}
+}
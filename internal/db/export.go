@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-proxy/pkg/export"
+)
+
+// ========================================
+// 数据导出
+// ========================================
+
+// 可导出的数据集
+const (
+	ExportRequests = "requests" // request_logs 明细
+	ExportDaily    = "daily"    // daily_summary 每日汇总，含响应时间分位数
+	ExportUsage    = "usage"    // 按天、服务、模型、客户端密钥汇总的用量与费用
+)
+
+// ExportQuery 是导出的数据集与范围。From 为起点（含），To 为终点（不含）；Service 为空表示全部服务。
+type ExportQuery struct {
+	Dataset string
+	From    time.Time
+	To      time.Time
+	Service string
+}
+
+var exportColumns = map[string][]export.Column{
+	ExportRequests: {
+		{Name: "id", Type: export.Int64},
+		{Name: "timestamp", Type: export.Timestamp},
+		{Name: "service_name", Type: export.String},
+		{Name: "host", Type: export.String},
+		{Name: "request_uri", Type: export.String},
+		{Name: "status_code", Type: export.Int64},
+		{Name: "response_time", Type: export.Int64},
+		{Name: "model", Type: export.String},
+		{Name: "client_key", Type: export.String},
+		{Name: "team", Type: export.String},
+		{Name: "prompt_tokens", Type: export.Int64},
+		{Name: "completion_tokens", Type: export.Int64},
+		{Name: "cost", Type: export.Float64},
+		{Name: "method", Type: export.String},
+		{Name: "client_ip", Type: export.String},
+		{Name: "user_agent", Type: export.String},
+		{Name: "request_bytes", Type: export.Int64},
+		{Name: "response_bytes", Type: export.Int64},
+		{Name: "upstream", Type: export.String},
+		{Name: "request_id", Type: export.String},
+	},
+	ExportDaily: {
+		{Name: "date", Type: export.String},
+		{Name: "service_name", Type: export.String},
+		{Name: "request_count", Type: export.Int64},
+		{Name: "success_count", Type: export.Int64},
+		{Name: "total_response_time", Type: export.Int64},
+		{Name: "request_bytes", Type: export.Int64},
+		{Name: "response_bytes", Type: export.Int64},
+		{Name: "p50", Type: export.Float64},
+		{Name: "p90", Type: export.Float64},
+		{Name: "p95", Type: export.Float64},
+		{Name: "p99", Type: export.Float64},
+	},
+	ExportUsage: {
+		{Name: "date", Type: export.String},
+		{Name: "service_name", Type: export.String},
+		{Name: "model", Type: export.String},
+		{Name: "client_key", Type: export.String},
+		{Name: "team", Type: export.String},
+		{Name: "request_count", Type: export.Int64},
+		{Name: "prompt_tokens", Type: export.Int64},
+		{Name: "completion_tokens", Type: export.Int64},
+		{Name: "cost", Type: export.Float64},
+	},
+}
+
+// ExportColumns 返回数据集的列定义。
+func ExportColumns(dataset string) ([]export.Column, error) {
+	cols, ok := exportColumns[dataset]
+	if !ok {
+		return nil, fmt.Errorf("不支持的数据集 %q，可选 requests、daily、usage", dataset)
+	}
+	return cols, nil
+}
+
+// ExportRows 逐行读取数据集并交给 write，不在内存中缓存结果，返回写出的行数。
+// 行中的值按 ExportColumns 的顺序与类型排列。
+func ExportRows(ctx context.Context, q ExportQuery, write func(row []any) error) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	if _, err := ExportColumns(q.Dataset); err != nil {
+		return 0, err
+	}
+
+	var query string
+	var args []any
+	switch q.Dataset {
+	case ExportRequests, ExportUsage:
+		where := `timestamp >= ? AND timestamp < ?`
+		args = []any{q.From.UTC().Format(time.DateTime), q.To.UTC().Format(time.DateTime)}
+		if q.Service != "" {
+			where += ` AND service_name = ?`
+			args = append(args, q.Service)
+		}
+		if q.Dataset == ExportRequests {
+			query = `
+			SELECT id, timestamp, service_name, COALESCE(host, ''), COALESCE(request_uri, ''),
+				COALESCE(status_code, 0), COALESCE(response_time, 0),
+				COALESCE(model, ''), COALESCE(client_key, ''), COALESCE(team, ''),
+				COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0), COALESCE(cost, 0),
+				COALESCE(method, ''), COALESCE(client_ip, ''), COALESCE(user_agent, ''),
+				COALESCE(request_bytes, 0), COALESCE(response_bytes, 0),
+				COALESCE(upstream, ''), COALESCE(request_id, '')
+			FROM request_logs WHERE ` + where + ` ORDER BY id`
+		} else {
+			query = `
+			SELECT ` + store.LocalDate("timestamp") + ` AS day, service_name,
+				COALESCE(model, ''), COALESCE(client_key, ''), COALESCE(team, ''),
+				COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost), 0)
+			FROM request_logs WHERE ` + where + `
+			GROUP BY day, service_name, COALESCE(model, ''), COALESCE(client_key, ''), COALESCE(team, '')
+			ORDER BY day, service_name`
+		}
+	case ExportDaily:
+		from, to := dayRange(StatsQuery{From: q.From, To: q.To})
+		query = `
+		SELECT date, service_name, request_count, success_count, total_response_time,
+			request_bytes, response_bytes, COALESCE(latency_histogram, '')
+		FROM daily_summary WHERE date >= ? AND date < ?`
+		args = []any{from, to}
+		if q.Service != "" {
+			query += ` AND service_name = ?`
+			args = append(args, q.Service)
+		}
+		query += ` ORDER BY date, service_name`
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("查询导出数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		var row []any
+		switch q.Dataset {
+		case ExportRequests:
+			var (
+				id, status, latency, prompt, completion int64
+				reqBytes, respBytes                     int64
+				ts                                      time.Time
+				service, host, uri, model, key, team    string
+				method, ip, ua, upstream, requestID     string
+				cost                                    float64
+			)
+			if err := rows.Scan(&id, &ts, &service, &host, &uri, &status, &latency,
+				&model, &key, &team, &prompt, &completion, &cost,
+				&method, &ip, &ua, &reqBytes, &respBytes, &upstream, &requestID); err != nil {
+				return n, err
+			}
+			row = []any{id, ts, service, host, uri, status, latency, model, key, team, prompt, completion, cost,
+				method, ip, ua, reqBytes, respBytes, upstream, requestID}
+		case ExportUsage:
+			var (
+				day, service, model, key, team string
+				count, prompt, completion      int64
+				cost                           float64
+			)
+			if err := rows.Scan(&day, &service, &model, &key, &team, &count, &prompt, &completion, &cost); err != nil {
+				return n, err
+			}
+			row = []any{day, service, model, key, team, count, prompt, completion, cost}
+		case ExportDaily:
+			var (
+				date, service, hist          string
+				count, success, totalLatency int64
+				reqBytes, respBytes          int64
+			)
+			if err := rows.Scan(&date, &service, &count, &success, &totalLatency, &reqBytes, &respBytes, &hist); err != nil {
+				return n, err
+			}
+			var p LatencyPercentiles
+			if h := decodeLatencyHistogram(hist); h != nil {
+				p = h.Percentiles()
+			}
+			row = []any{date, service, count, success, totalLatency, reqBytes, respBytes, p.P50, p.P90, p.P95, p.P99}
+		}
+		if err := write(row); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
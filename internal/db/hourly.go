@@ -0,0 +1,206 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// ========================================
+// 小时汇总
+// ========================================
+
+// HourlyStat 是一个小时内的流量、错误率与响应时间。
+type HourlyStat struct {
+	Hour         string  `json:"hour"` // 本地时间 2006-01-02 15:00
+	RequestCount int     `json:"request_count"`
+	SuccessCount int     `json:"success_count"`
+	ErrorCount   int     `json:"error_count"` // 状态码 >= 400 的请求数
+	ErrorRate    float64 `json:"error_rate"`  // ErrorCount / RequestCount
+	ResponseTime float64 `json:"response_time"`
+	P50          float64 `json:"p50"`
+	P95          float64 `json:"p95"`
+	P99          float64 `json:"p99"`
+}
+
+// IsHourlySummaryEmpty 检查 hourly_summary 表是否为空。
+func IsHourlySummaryEmpty() bool {
+	if db == nil {
+		return true
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM hourly_summary").Scan(&count); err != nil {
+		log.Printf("检查 hourly_summary 时出错: %v", err)
+		return true
+	}
+	return count == 0
+}
+
+// AggregateHourly 将 request_logs 中最近 hoursBack 个小时（含当前小时）的数据聚合到 hourly_summary 表。
+// 定时任务传 2 即可覆盖当前小时和上一个小时的迟到数据。
+func AggregateHourly(hoursBack int) error {
+	if mem != nil {
+		return nil // 内存模式在写入时已经汇总
+	}
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if hoursBack < 1 {
+		hoursBack = 1
+	}
+	// 起点取本地整点再转为 UTC，避免非整点时区下首个小时只聚合到一部分数据
+	start := bucketStart(time.Now().Add(-time.Duration(hoursBack-1)*time.Hour), GranularityHour).UTC().Format(time.DateTime)
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("开始小时聚合事务时出错: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	hour := store.LocalHour("timestamp")
+	_, err = tx.Exec(`
+	INSERT INTO hourly_summary (hour, service_name, request_count, success_count, error_count, total_response_time,
+		request_bytes, response_bytes, latency_histogram)
+	SELECT
+		`+hour+` AS hour,
+		service_name,
+		COUNT(*) AS request_count,
+		SUM(CASE WHEN status_code BETWEEN 200 AND 299 THEN 1 ELSE 0 END) AS success_count,
+		SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END) AS error_count,
+		SUM(CASE WHEN response_time > 0 AND response_time < 60000 THEN response_time ELSE 0 END) AS total_response_time,
+		COALESCE(SUM(request_bytes), 0) AS request_bytes,
+		COALESCE(SUM(response_bytes), 0) AS response_bytes,
+		? AS latency_histogram
+	FROM request_logs
+	WHERE timestamp >= ?
+	GROUP BY hour, service_name
+	ON CONFLICT (hour, service_name) DO UPDATE SET
+		request_count = excluded.request_count,
+		success_count = excluded.success_count,
+		error_count = excluded.error_count,
+		total_response_time = excluded.total_response_time,
+		request_bytes = excluded.request_bytes,
+		response_bytes = excluded.response_bytes,
+		latency_histogram = excluded.latency_histogram;
+	`, emptyLatencyHistogram, start)
+	if err != nil {
+		log.Printf("聚合小时统计时出错: %v", err)
+		return err
+	}
+	if err = aggregateLatency(tx, "hourly_summary", "hour", hour, "timestamp >= ?", start); err != nil {
+		log.Printf("聚合小时响应时间直方图时出错: %v", err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("提交小时聚合事务时出错: %v", err)
+		return err
+	}
+	return nil
+}
+
+// CleanupHourlySummary 删除超过 retentionDays 天的 hourly_summary 数据。
+func CleanupHourlySummary(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).Format(time.DateTime)
+	if mem != nil {
+		if n := mem.prune(mem.hourly, cutoff); n > 0 {
+			log.Printf("已清理 %d 条过期的内存小时汇总。", n)
+		}
+		return nil
+	}
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result, err := db.Exec(`DELETE FROM hourly_summary WHERE hour < ?`, cutoff)
+	if err != nil {
+		log.Printf("清理旧 hourly_summary 时出错: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		log.Printf("已清理 %d 条过期 hourly_summary 记录。", rows)
+	}
+	return nil
+}
+
+// GetHourlyStatsWithCache 带缓存的小时统计查询。
+func GetHourlyStatsWithCache(q StatsQuery) ([]HourlyStat, error) {
+	return cachedQuery(q.cacheKey("hourly"), func() ([]HourlyStat, error) { return GetHourlyStats(q) })
+}
+
+// GetHourlyStats 从 hourly_summary 返回 [From, To) 内每小时的流量、错误率与响应时间，没有数据的小时补 0。
+// Service 为空时合并全部服务。
+func GetHourlyStats(q StatsQuery) ([]HourlyStat, error) {
+	if mem != nil {
+		return mem.hourlyStats(q), nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	q.Granularity = GranularityHour
+	src := sourceFor(q)
+	cond, args := src.where(q)
+	rows, err := db.Query(`
+	SELECT hour, request_count, success_count, error_count, total_response_time, latency_histogram
+	FROM hourly_summary WHERE `+cond, args...)
+	if err != nil {
+		log.Printf("查询小时统计时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	type agg struct {
+		requests, success, errors int
+		totalTime                 int64
+		hist                      LatencyHistogram
+	}
+	hours := make(map[time.Time]*agg)
+	for rows.Next() {
+		var key string
+		var requests, success, errors int
+		var totalTime int64
+		var data *string
+		if err := rows.Scan(&key, &requests, &success, &errors, &totalTime, &data); err != nil {
+			log.Printf("扫描小时统计行时出错: %v", err)
+			continue
+		}
+		t, ok := src.bucketOf(key, q)
+		if !ok {
+			continue
+		}
+		a := hours[t]
+		if a == nil {
+			a = &agg{hist: newLatencyHistogram()}
+			hours[t] = a
+		}
+		a.requests += requests
+		a.success += success
+		a.errors += errors
+		a.totalTime += totalTime
+		if data != nil {
+			if h := decodeLatencyHistogram(*data); h != nil {
+				a.hist.Merge(h)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("迭代小时统计行时出错: %v", err)
+		return nil, err
+	}
+
+	stats := []HourlyStat{}
+	for t := bucketStart(q.From, GranularityHour); t.Before(q.To); t = t.Add(time.Hour) {
+		s := HourlyStat{Hour: bucketLabel(t, GranularityHour)}
+		if a := hours[t]; a != nil {
+			s.RequestCount, s.SuccessCount, s.ErrorCount = a.requests, a.success, a.errors
+			if a.requests > 0 {
+				s.ErrorRate = math.Round(float64(a.errors)/float64(a.requests)*10000) / 10000
+				s.ResponseTime = math.Round(float64(a.totalTime)/float64(a.requests)*100) / 100
+			}
+			p := a.hist.Percentiles()
+			s.P50, s.P95, s.P99 = p.P50, p.P95, p.P99
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-proxy/pkg/types"
+)
+
+// ========================================
+// 客户端密钥
+// ========================================
+
+const clientKeyColumns = `id, name, key_prefix, allowed_paths, expires_at, disabled, created_at, rate_limit_rpm, rate_limit_concurrent, team, rate_limit_tpm`
+
+// scanClientKey 将一行 client_keys 记录转换为 types.ClientKey。
+func scanClientKey(scanner interface{ Scan(dest ...any) error }) (*types.ClientKey, error) {
+	var (
+		k            types.ClientKey
+		allowedPaths string
+		expiresAt    sql.NullTime
+		createdAt    sql.NullTime
+	)
+	if err := scanner.Scan(&k.ID, &k.Name, &k.KeyPrefix, &allowedPaths, &expiresAt, &k.Disabled, &createdAt,
+		&k.RateLimitRPM, &k.RateLimitConcurrent, &k.Team, &k.RateLimitTPM); err != nil {
+		return nil, err
+	}
+	if allowedPaths != "" {
+		k.AllowedPaths = strings.Split(allowedPaths, ",")
+	}
+	if expiresAt.Valid {
+		k.ExpiresAt = expiresAt.Time
+	}
+	if createdAt.Valid {
+		k.CreatedAt = createdAt.Time
+	}
+	k.Source = "db"
+	return &k, nil
+}
+
+// nullableTime 将零值时间转换为 NULL。
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// CreateClientKey 保存一个新的客户端密钥，keyHash 为完整密钥的 SHA-256 十六进制摘要。
+func CreateClientKey(k types.ClientKey, keyHash string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	var id int64
+	err := db.QueryRow(
+		`INSERT INTO client_keys (name, key_hash, key_prefix, allowed_paths, expires_at, disabled, rate_limit_rpm, rate_limit_concurrent, team, rate_limit_tpm)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		k.Name, keyHash, k.KeyPrefix, strings.Join(k.AllowedPaths, ","), nullableTime(k.ExpiresAt), boolInt(k.Disabled),
+		k.RateLimitRPM, k.RateLimitConcurrent, k.Team, k.RateLimitTPM,
+	).Scan(&id)
+	if err != nil {
+		log.Printf("保存客户端密钥 (name: %s) 时出错: %v", k.Name, err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetClientKeyByHash 按哈希查找客户端密钥，不存在时返回 nil, nil。
+func GetClientKeyByHash(keyHash string) (*types.ClientKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	row := db.QueryRow(`SELECT `+clientKeyColumns+` FROM client_keys WHERE key_hash = ?`, keyHash)
+	k, err := scanClientKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("查询客户端密钥时出错: %v", err)
+		return nil, err
+	}
+	return k, nil
+}
+
+// ListClientKeys 返回数据库中保存的所有客户端密钥（不含哈希）。
+func ListClientKeys() ([]types.ClientKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	rows, err := db.Query(`SELECT ` + clientKeyColumns + ` FROM client_keys ORDER BY id`)
+	if err != nil {
+		log.Printf("查询客户端密钥列表时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []types.ClientKey{}
+	for rows.Next() {
+		k, err := scanClientKey(rows)
+		if err != nil {
+			log.Printf("扫描客户端密钥行时出错: %v", err)
+			continue
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// ClientKeyUpdate 描述对客户端密钥的部分更新，nil 字段保持不变。
+type ClientKeyUpdate struct {
+	Disabled            *bool      `json:"disabled"`
+	AllowedPaths        *[]string  `json:"allowed_paths"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+	RateLimitRPM        *int       `json:"rate_limit_rpm"`
+	RateLimitConcurrent *int       `json:"rate_limit_concurrent"`
+	RateLimitTPM        *int       `json:"rate_limit_tpm"`
+	Team                *string    `json:"team"`
+}
+
+// UpdateClientKey 更新指定 ID 的客户端密钥，记录不存在时返回 sql.ErrNoRows。
+func UpdateClientKey(id int64, u ClientKeyUpdate) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	var sets []string
+	var args []any
+	if u.Disabled != nil {
+		sets = append(sets, "disabled = ?")
+		args = append(args, boolInt(*u.Disabled))
+	}
+	if u.AllowedPaths != nil {
+		sets = append(sets, "allowed_paths = ?")
+		args = append(args, strings.Join(*u.AllowedPaths, ","))
+	}
+	if u.ExpiresAt != nil {
+		sets = append(sets, "expires_at = ?")
+		args = append(args, nullableTime(*u.ExpiresAt))
+	}
+	if u.RateLimitRPM != nil {
+		sets = append(sets, "rate_limit_rpm = ?")
+		args = append(args, *u.RateLimitRPM)
+	}
+	if u.RateLimitConcurrent != nil {
+		sets = append(sets, "rate_limit_concurrent = ?")
+		args = append(args, *u.RateLimitConcurrent)
+	}
+	if u.RateLimitTPM != nil {
+		sets = append(sets, "rate_limit_tpm = ?")
+		args = append(args, *u.RateLimitTPM)
+	}
+	if u.Team != nil {
+		sets = append(sets, "team = ?")
+		args = append(args, *u.Team)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	args = append(args, id)
+
+	mu.Lock()
+	defer mu.Unlock()
+	result, err := db.Exec(`UPDATE client_keys SET `+strings.Join(sets, ", ")+` WHERE id = ?`, args...)
+	if err != nil {
+		log.Printf("更新客户端密钥 (id: %d) 时出错: %v", id, err)
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteClientKey 删除指定 ID 的客户端密钥，记录不存在时返回 sql.ErrNoRows。
+func DeleteClientKey(id int64) error {
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	result, err := db.Exec(`DELETE FROM client_keys WHERE id = ?`, id)
+	if err != nil {
+		log.Printf("删除客户端密钥 (id: %d) 时出错: %v", id, err)
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
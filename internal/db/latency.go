@@ -0,0 +1,305 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ========================================
+// 响应时间分位数
+// ========================================
+
+// latencyBounds 直方图各桶的上界（毫秒，含），最后一个桶收集超过最大上界的请求。
+// 桶边界固定，同一服务不同日期的直方图可以直接逐桶相加合并。
+// 分位数在桶内线性插值，误差不超过所在桶的宽度。
+var latencyBounds = []int64{
+	10, 25, 50, 75, 100, 150, 200, 300, 400, 500, 750,
+	1000, 1500, 2000, 3000, 4000, 5000, 7500, 10000, 15000, 20000,
+	30000, 45000, 60000, 90000, 120000, 180000, 300000,
+}
+
+// latencyBucketExpr 计算 response_time 所在桶序号的 SQL 表达式
+var latencyBucketExpr = func() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, bound := range latencyBounds {
+		fmt.Fprintf(&b, " WHEN response_time <= %d THEN %d", bound, i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(latencyBounds))
+	return b.String()
+}()
+
+// latencyBucketOf 返回 response_time 所在桶的序号，与 latencyBucketExpr 一致。
+func latencyBucketOf(ms int64) int {
+	for i, bound := range latencyBounds {
+		if ms <= bound {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+// LatencyHistogram 是按 latencyBounds 分桶的请求计数，长度为 len(latencyBounds)+1。
+type LatencyHistogram []int64
+
+func newLatencyHistogram() LatencyHistogram {
+	return make(LatencyHistogram, len(latencyBounds)+1)
+}
+
+// decodeLatencyHistogram 解析 daily_summary.latency_histogram，桶数不符（例如桶边界调整前写入的数据）时返回 nil。
+func decodeLatencyHistogram(s string) LatencyHistogram {
+	var h LatencyHistogram
+	if err := json.Unmarshal([]byte(s), &h); err != nil || len(h) != len(latencyBounds)+1 {
+		return nil
+	}
+	return h
+}
+
+// Merge 将 other 逐桶累加到 h。
+func (h LatencyHistogram) Merge(other LatencyHistogram) {
+	for i := range h {
+		if i < len(other) {
+			h[i] += other[i]
+		}
+	}
+}
+
+// Count 返回直方图中的请求总数。
+func (h LatencyHistogram) Count() int64 {
+	var n int64
+	for _, c := range h {
+		n += c
+	}
+	return n
+}
+
+// Quantile 返回 q（0~1）分位的响应时间（毫秒），没有数据时返回 0。
+// 落在最后一个桶时返回最大上界。
+func (h LatencyHistogram) Quantile(q float64) float64 {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	var cum int64
+	for i, c := range h {
+		if c == 0 || float64(cum+c) < rank {
+			cum += c
+			continue
+		}
+		if i == len(latencyBounds) {
+			return float64(latencyBounds[len(latencyBounds)-1])
+		}
+		var lower float64
+		if i > 0 {
+			lower = float64(latencyBounds[i-1])
+		}
+		upper := float64(latencyBounds[i])
+		return lower + (upper-lower)*(rank-float64(cum))/float64(c)
+	}
+	return float64(latencyBounds[len(latencyBounds)-1])
+}
+
+// LatencyPercentiles 是一组请求的响应时间分位数（毫秒）。
+type LatencyPercentiles struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// Percentiles 计算 P50/P90/P95/P99，保留两位小数。
+func (h LatencyHistogram) Percentiles() LatencyPercentiles {
+	round := func(v float64) float64 { return float64(int64(v*100+0.5)) / 100 }
+	return LatencyPercentiles{
+		Count: h.Count(),
+		P50:   round(h.Quantile(0.50)),
+		P90:   round(h.Quantile(0.90)),
+		P95:   round(h.Quantile(0.95)),
+		P99:   round(h.Quantile(0.99)),
+	}
+}
+
+// LatencyPoint 是一个时间段的响应时间分位数。
+type LatencyPoint struct {
+	Date string `json:"date"`
+	LatencyPercentiles
+}
+
+// LatencyReport 是时间范围内某个服务（为空表示全部服务）的响应时间分位数与按粒度划分的序列。
+type LatencyReport struct {
+	ServiceName string             `json:"service_name,omitempty"`
+	Summary     LatencyPercentiles `json:"summary"`
+	Series      []LatencyPoint     `json:"series"`
+}
+
+// emptyLatencyHistogram 是空直方图的 JSON，聚合时作为默认值写入，以区分升级前尚未回填的记录
+var emptyLatencyHistogram = func() string {
+	data, _ := json.Marshal(newLatencyHistogram())
+	return string(data)
+}()
+
+// aggregateLatency 将 request_logs 中满足 where 条件的响应时间按 keyExpr（时间段）、服务写入 table.latency_histogram。
+// keyCol 为 table 中与 keyExpr 对应的列。需在同一事务中紧接 table 的 upsert 之后执行。
+func aggregateLatency(tx *txConn, table, keyCol, keyExpr, where string, args ...any) error {
+	rows, err := tx.Query(fmt.Sprintf(`
+	SELECT
+		%s AS period,
+		service_name,
+		%s AS bucket,
+		COUNT(*)
+	FROM request_logs
+	WHERE response_time > 0 AND %s
+	GROUP BY period, service_name, bucket;
+	`, keyExpr, latencyBucketExpr, where), args...)
+	if err != nil {
+		return err
+	}
+
+	type key struct{ period, service string }
+	hists := make(map[key]LatencyHistogram)
+	for rows.Next() {
+		var k key
+		var bucket int
+		var n int64
+		if err := rows.Scan(&k.period, &k.service, &bucket, &n); err != nil {
+			rows.Close()
+			return err
+		}
+		h, ok := hists[k]
+		if !ok {
+			h = newLatencyHistogram()
+			hists[k] = h
+		}
+		if bucket >= 0 && bucket < len(h) {
+			h[bucket] += n
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET latency_histogram = ? WHERE %s = ? AND service_name = ?`, table, keyCol)
+	for k, h := range hists {
+		data, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(update, string(data), k.period, k.service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsLatencyMissing 检查 daily_summary 中是否有尚未生成直方图的记录（升级前聚合的数据）。
+func IsLatencyMissing() bool {
+	if db == nil {
+		return false
+	}
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM daily_summary WHERE latency_histogram IS NULL").Scan(&count)
+	if err != nil {
+		log.Printf("检查 latency_histogram 时出错: %v", err)
+		return false
+	}
+	return count > 0
+}
+
+// recentLatencyByService 合并最近 days 天各服务的直方图。
+func recentLatencyByService(days int) (map[string]LatencyHistogram, error) {
+	rows, err := db.Query(`
+	SELECT service_name, latency_histogram FROM daily_summary
+	WHERE date >= ? AND latency_histogram IS NOT NULL
+	`, time.Now().AddDate(0, 0, -days).Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]LatencyHistogram)
+	for rows.Next() {
+		var service, data string
+		if err := rows.Scan(&service, &data); err != nil {
+			log.Printf("扫描响应时间直方图时出错: %v", err)
+			continue
+		}
+		h := decodeLatencyHistogram(data)
+		if h == nil {
+			continue
+		}
+		if _, ok := result[service]; !ok {
+			result[service] = newLatencyHistogram()
+		}
+		result[service].Merge(h)
+	}
+	return result, rows.Err()
+}
+
+// GetLatencyWithCache 带缓存的响应时间分位数查询。
+func GetLatencyWithCache(q StatsQuery) (*LatencyReport, error) {
+	return cachedQuery(q.cacheKey("latency"), func() (*LatencyReport, error) { return GetLatency(q) })
+}
+
+// GetLatency 合并 [From, To) 内的直方图计算分位数，小时粒度从 hourly_summary 读取，其余粒度按天精度从 daily_summary 读取。
+func GetLatency(q StatsQuery) (*LatencyReport, error) {
+	if mem != nil {
+		return mem.latency(q), nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	src := sourceFor(q)
+	cond, args := src.where(q)
+	query := fmt.Sprintf(`SELECT %s, latency_histogram FROM %s WHERE %s AND latency_histogram IS NOT NULL`, src.keyCol, src.table, cond)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("查询响应时间直方图时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	total := newLatencyHistogram()
+	buckets := make(map[time.Time]LatencyHistogram)
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			log.Printf("扫描响应时间直方图时出错: %v", err)
+			continue
+		}
+		h := decodeLatencyHistogram(data)
+		if h == nil {
+			continue
+		}
+		start, ok := src.bucketOf(key, q)
+		if !ok {
+			continue
+		}
+		if _, ok := buckets[start]; !ok {
+			buckets[start] = newLatencyHistogram()
+		}
+		buckets[start].Merge(h)
+		total.Merge(h)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("迭代响应时间直方图时出错: %v", err)
+		return nil, err
+	}
+
+	report := &LatencyReport{ServiceName: q.Service, Summary: total.Percentiles(), Series: []LatencyPoint{}}
+	for t := bucketStart(q.From, q.Granularity); t.Before(q.To); t = nextBucket(t, q.Granularity) {
+		h := buckets[t]
+		if h == nil {
+			h = newLatencyHistogram()
+		}
+		report.Series = append(report.Series, LatencyPoint{Date: bucketLabel(t, q.Granularity), LatencyPercentiles: h.Percentiles()})
+	}
+	return report, nil
+}
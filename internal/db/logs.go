@@ -0,0 +1,261 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ========================================
+// 请求日志查询
+// ========================================
+
+// 请求日志的排序字段
+const (
+	LogSortID           = "id" // 按写入顺序，即按时间
+	LogSortResponseTime = "response_time"
+	LogSortStatusCode   = "status_code"
+)
+
+// ErrInvalidCursor 表示分页游标无法解析或与排序字段不匹配。
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// LogQuery 是请求日志的过滤、排序与分页参数，零值字段表示不过滤。
+type LogQuery struct {
+	Service     string
+	Host        string
+	StatusMin   int
+	StatusMax   int
+	From        time.Time // 含
+	To          time.Time // 不含
+	MinLatency  int64     // 毫秒
+	URIContains string
+	ClientIP    string
+	RequestID   string
+	Sort        string // LogSortID、LogSortResponseTime 或 LogSortStatusCode，默认 LogSortID
+	Asc         bool
+	Cursor      string // 上一页返回的 NextCursor
+	Limit       int
+}
+
+// RequestLog 是 request_logs 中的一条记录。
+type RequestLog struct {
+	ID               int64     `json:"id"`
+	ServiceName      string    `json:"service_name"`
+	Host             string    `json:"host"`
+	RequestURI       string    `json:"request_uri"`
+	StatusCode       int       `json:"status_code"`
+	ResponseTime     int64     `json:"response_time"`
+	Model            string    `json:"model"`
+	ClientKey        string    `json:"client_key"`
+	Team             string    `json:"team"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	Method           string    `json:"method"`
+	ClientIP         string    `json:"client_ip"`
+	UserAgent        string    `json:"user_agent"`
+	RequestBytes     int64     `json:"request_bytes"`
+	ResponseBytes    int64     `json:"response_bytes"`
+	Upstream         string    `json:"upstream"`
+	RequestID        string    `json:"request_id"`
+	HasBody          bool      `json:"has_body"` // 是否有采样记录的请求/响应体
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// LogPage 是一页请求日志，NextCursor 为空表示没有更多数据。
+type LogPage struct {
+	Items      []RequestLog `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// logCursor 是键集分页的位置：上一页最后一条记录的排序值与 id。
+// 按 id 排序时编码为 "id"，其他字段编码为 "排序值:id"。
+type logCursor struct {
+	value int64
+	id    int64
+}
+
+func parseLogCursor(s, sort string) (logCursor, error) {
+	if sort == LogSortID {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return logCursor{}, ErrInvalidCursor
+		}
+		return logCursor{value: id, id: id}, nil
+	}
+	v, id, ok := strings.Cut(s, ":")
+	if !ok {
+		return logCursor{}, ErrInvalidCursor
+	}
+	var c logCursor
+	var err1, err2 error
+	c.value, err1 = strconv.ParseInt(v, 10, 64)
+	c.id, err2 = strconv.ParseInt(id, 10, 64)
+	if err1 != nil || err2 != nil {
+		return logCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (c logCursor) encode(sort string) string {
+	if sort == LogSortID {
+		return strconv.FormatInt(c.id, 10)
+	}
+	return fmt.Sprintf("%d:%d", c.value, c.id)
+}
+
+// escapeLike 转义 LIKE 模式中的通配符，配合 ESCAPE '\' 使用。
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SearchRequestLogs 按条件查询请求日志，使用 (排序字段, id) 键集分页，翻页性能不随页数下降。
+func SearchRequestLogs(q LogQuery) (*LogPage, error) {
+	if db == nil && mem == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	switch q.Sort {
+	case "":
+		q.Sort = LogSortID
+	case LogSortID, LogSortResponseTime, LogSortStatusCode:
+	default:
+		return nil, fmt.Errorf("不支持的排序字段 %q", q.Sort)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	if mem != nil {
+		return mem.searchLogs(q)
+	}
+
+	var conds []string
+	var args []any
+	if q.Service != "" {
+		conds = append(conds, "l.service_name = ?")
+		args = append(args, q.Service)
+	}
+	if q.Host != "" {
+		conds = append(conds, "l.host = ?")
+		args = append(args, q.Host)
+	}
+	if q.StatusMin > 0 {
+		conds = append(conds, "l.status_code >= ?")
+		args = append(args, q.StatusMin)
+	}
+	if q.StatusMax > 0 {
+		conds = append(conds, "l.status_code <= ?")
+		args = append(args, q.StatusMax)
+	}
+	if !q.From.IsZero() {
+		conds = append(conds, "l.timestamp >= ?")
+		args = append(args, q.From.UTC().Format(time.DateTime))
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "l.timestamp < ?")
+		args = append(args, q.To.UTC().Format(time.DateTime))
+	}
+	if q.MinLatency > 0 {
+		conds = append(conds, "l.response_time >= ?")
+		args = append(args, q.MinLatency)
+	}
+	if q.URIContains != "" {
+		conds = append(conds, `l.request_uri LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.URIContains)+"%")
+	}
+	if q.ClientIP != "" {
+		conds = append(conds, "l.client_ip = ?")
+		args = append(args, q.ClientIP)
+	}
+	if q.RequestID != "" {
+		conds = append(conds, "l.request_id = ?")
+		args = append(args, q.RequestID)
+	}
+
+	sortCol := "l." + q.Sort
+	op, dir := "<", "DESC"
+	if q.Asc {
+		op, dir = ">", "ASC"
+	}
+	if q.Cursor != "" {
+		c, err := parseLogCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if q.Sort == LogSortID {
+			conds = append(conds, "l.id "+op+" ?")
+			args = append(args, c.id)
+		} else {
+			conds = append(conds, fmt.Sprintf("(COALESCE(%s, 0), l.id) %s (?, ?)", sortCol, op))
+			args = append(args, c.value, c.id)
+		}
+	}
+
+	query := `
+	SELECT l.id, l.service_name, COALESCE(l.host, ''), COALESCE(l.request_uri, ''),
+		COALESCE(l.status_code, 0), COALESCE(l.response_time, 0),
+		COALESCE(l.model, ''), COALESCE(l.client_key, ''), COALESCE(l.team, ''),
+		COALESCE(l.prompt_tokens, 0), COALESCE(l.completion_tokens, 0), COALESCE(l.cost, 0),
+		COALESCE(l.method, ''), COALESCE(l.client_ip, ''), COALESCE(l.user_agent, ''),
+		COALESCE(l.request_bytes, 0), COALESCE(l.response_bytes, 0),
+		COALESCE(l.upstream, ''), COALESCE(l.request_id, ''),
+		EXISTS(SELECT 1 FROM request_bodies b WHERE b.request_log_id = l.id),
+		l.timestamp
+	FROM request_logs l`
+	if len(conds) > 0 {
+		query += "\n\tWHERE " + strings.Join(conds, " AND ")
+	}
+	if q.Sort == LogSortID {
+		query += fmt.Sprintf("\n\tORDER BY l.id %s", dir)
+	} else {
+		query += fmt.Sprintf("\n\tORDER BY COALESCE(%s, 0) %s, l.id %s", sortCol, dir, dir)
+	}
+	// 多取一条用于判断是否还有下一页
+	query += "\n\tLIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("查询请求日志时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &LogPage{Items: []RequestLog{}}
+	for rows.Next() {
+		var r RequestLog
+		if err := rows.Scan(&r.ID, &r.ServiceName, &r.Host, &r.RequestURI,
+			&r.StatusCode, &r.ResponseTime,
+			&r.Model, &r.ClientKey, &r.Team,
+			&r.PromptTokens, &r.CompletionTokens, &r.Cost,
+			&r.Method, &r.ClientIP, &r.UserAgent,
+			&r.RequestBytes, &r.ResponseBytes,
+			&r.Upstream, &r.RequestID,
+			&r.HasBody, &r.Timestamp); err != nil {
+			log.Printf("扫描请求日志行时出错: %v", err)
+			return nil, err
+		}
+		page.Items = append(page.Items, r)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("迭代请求日志行时出错: %v", err)
+		return nil, err
+	}
+
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[len(page.Items)-1]
+		c := logCursor{id: last.ID}
+		switch q.Sort {
+		case LogSortResponseTime:
+			c.value = last.ResponseTime
+		case LogSortStatusCode:
+			c.value = int64(last.StatusCode)
+		}
+		page.NextCursor = c.encode(q.Sort)
+	}
+	return page, nil
+}
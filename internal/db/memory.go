@@ -0,0 +1,428 @@
+package db
+
+import (
+	"cmp"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/types"
+)
+
+// ========================================
+// 内存模式
+// ========================================
+
+// DefaultMemoryLogs 是未配置 database.memory_logs 时内存模式保留的请求日志条数
+const DefaultMemoryLogs = 10000
+
+// mem 不为 nil 时处于内存模式，此时 db 为 nil。统计查询改由 mem 提供，结果格式与数据库模式相同。
+var mem *memoryStats
+
+// memoryStats 在进程内存中保存统计数据，用于没有数据库的部署（Vercel 或 database.driver: memory）。
+// 最近的请求日志保存在固定容量的环形缓冲区中，每日、每小时汇总在写入时滚动累加，
+// 由 CleanupOldData、CleanupHourlySummary 按保留天数淘汰。进程退出后数据全部丢失。
+type memoryStats struct {
+	mu      sync.RWMutex
+	started time.Time
+	logs    []RequestLog // 环形缓冲区
+	next    int          // 下一条日志写入的位置
+	lastID  int64
+	proxies []config.ProxyConfig
+	totals  map[string]int // 对应 request_stats
+	daily   map[memoryKey]*memoryBucket
+	hourly  map[memoryKey]*memoryBucket
+}
+
+// memoryKey 对应 daily_summary、hourly_summary 的主键，period 与表中 date、hour 列的格式相同。
+type memoryKey struct {
+	period  string
+	service string
+}
+
+// memoryBucket 对应 daily_summary、hourly_summary 中的一行。
+type memoryBucket struct {
+	requests, success, errors   int
+	totalResponseTime           int64
+	requestBytes, responseBytes int64
+	latency                     LatencyHistogram
+}
+
+func newMemoryStats(maxLogs int) *memoryStats {
+	if maxLogs <= 0 {
+		maxLogs = DefaultMemoryLogs
+	}
+	return &memoryStats{
+		started: time.Now(),
+		logs:    make([]RequestLog, 0, maxLogs),
+		totals:  make(map[string]int),
+		daily:   make(map[memoryKey]*memoryBucket),
+		hourly:  make(map[memoryKey]*memoryBucket),
+	}
+}
+
+// initMemory 进入内存模式。
+func initMemory(cfg config.DatabaseConfig) {
+	db = nil
+	mem = newMemoryStats(cfg.MemoryLogs)
+	log.Printf("统计数据使用内存模式（保留最近 %d 条请求日志），服务重启后数据将丢失。", cap(mem.logs))
+}
+
+// StatsEnabled 检查统计功能是否可用：数据库已初始化或处于内存模式。
+func StatsEnabled() bool {
+	return db != nil || mem != nil
+}
+
+// StorageInfo 描述统计数据的存储方式，供 Web 界面提示数据是否持久化。
+type StorageInfo struct {
+	Driver     string     `json:"driver"`
+	Persistent bool       `json:"persistent"`
+	Since      *time.Time `json:"since,omitempty"`    // 内存模式开始记录的时间
+	MaxLogs    int        `json:"max_logs,omitempty"` // 内存模式保留的请求日志条数
+}
+
+// GetStorageInfo 返回统计数据的存储方式。
+func GetStorageInfo() StorageInfo {
+	if mem != nil {
+		since := mem.started
+		return StorageInfo{Driver: DriverMemory, Since: &since, MaxLogs: cap(mem.logs)}
+	}
+	return StorageInfo{Driver: Driver(), Persistent: db != nil}
+}
+
+// ========================================
+// 写入
+// ========================================
+
+func (m *memoryStats) record(stats []types.RequestStat) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, stat := range stats {
+		m.lastID++
+		entry := RequestLog{
+			ID:               m.lastID,
+			ServiceName:      stat.ServiceName,
+			Host:             stat.Host,
+			RequestURI:       stat.RequestURI,
+			StatusCode:       stat.StatusCode,
+			ResponseTime:     stat.ResponseTime,
+			Model:            stat.Model,
+			ClientKey:        stat.ClientKey,
+			Team:             stat.Team,
+			PromptTokens:     stat.PromptTokens,
+			CompletionTokens: stat.CompletionTokens,
+			Cost:             stat.Cost,
+			Method:           stat.Method,
+			ClientIP:         stat.ClientIP,
+			UserAgent:        stat.UserAgent,
+			RequestBytes:     stat.RequestBytes,
+			ResponseBytes:    stat.ResponseBytes,
+			Upstream:         stat.Upstream,
+			RequestID:        stat.RequestID,
+			Timestamp:        now.UTC().Truncate(time.Second),
+		}
+		if len(m.logs) < cap(m.logs) {
+			m.logs = append(m.logs, entry)
+		} else {
+			m.logs[m.next] = entry
+		}
+		m.next = (m.next + 1) % cap(m.logs)
+
+		m.add(m.daily, memoryKey{now.Format(time.DateOnly), stat.ServiceName}, stat)
+		m.add(m.hourly, memoryKey{bucketStart(now, GranularityHour).Format(time.DateTime), stat.ServiceName}, stat)
+	}
+}
+
+// add 按 AggregateDaily、AggregateHourly 的口径把一条请求累加到汇总中。
+func (m *memoryStats) add(summary map[memoryKey]*memoryBucket, k memoryKey, stat types.RequestStat) {
+	b := summary[k]
+	if b == nil {
+		b = &memoryBucket{latency: newLatencyHistogram()}
+		summary[k] = b
+	}
+	b.requests++
+	if stat.StatusCode >= 200 && stat.StatusCode <= 299 {
+		b.success++
+	}
+	if stat.StatusCode >= 400 {
+		b.errors++
+	}
+	if stat.ResponseTime > 0 && stat.ResponseTime < 60000 {
+		b.totalResponseTime += stat.ResponseTime
+	}
+	b.requestBytes += stat.RequestBytes
+	b.responseBytes += stat.ResponseBytes
+	if stat.ResponseTime > 0 {
+		b.latency[latencyBucketOf(stat.ResponseTime)]++
+	}
+}
+
+func (m *memoryStats) incrementCounts(counts map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for service, n := range counts {
+		m.totals[service] += n
+	}
+}
+
+func (m *memoryStats) setProxies(configs []config.ProxyConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.proxies = slices.Clone(configs)
+}
+
+// prune 删除 summary 中早于 cutoff 的时间段，返回删除的条数。
+func (m *memoryStats) prune(summary map[memoryKey]*memoryBucket, cutoff string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for k := range summary {
+		if k.period < cutoff {
+			delete(summary, k)
+			n++
+		}
+	}
+	return n
+}
+
+// ========================================
+// 查询
+// ========================================
+
+// buckets 返回 src 时间范围内（及 q.Service 指定的服务）的汇总，key 为所属时间段的起点。
+// 调用方需持有读锁。
+func (m *memoryStats) buckets(src summarySource, q StatsQuery) map[time.Time]*memoryBucket {
+	summary := m.daily
+	if src.table == "hourly_summary" {
+		summary = m.hourly
+	}
+	result := make(map[time.Time]*memoryBucket)
+	for k, b := range summary {
+		if k.period < src.from || k.period >= src.to || (q.Service != "" && k.service != q.Service) {
+			continue
+		}
+		t, ok := src.bucketOf(k.period, q)
+		if !ok {
+			continue
+		}
+		r := result[t]
+		if r == nil {
+			r = &memoryBucket{latency: newLatencyHistogram()}
+			result[t] = r
+		}
+		r.merge(b)
+	}
+	return result
+}
+
+func (b *memoryBucket) merge(other *memoryBucket) {
+	b.requests += other.requests
+	b.success += other.success
+	b.errors += other.errors
+	b.totalResponseTime += other.totalResponseTime
+	b.requestBytes += other.requestBytes
+	b.responseBytes += other.responseBytes
+	b.latency.Merge(other.latency)
+}
+
+func (m *memoryStats) stats() []Stat {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	since := time.Now().AddDate(0, 0, -7).Format(time.DateOnly)
+	recent := make(map[string]*memoryBucket)
+	for k, b := range m.daily {
+		if k.period < since {
+			continue
+		}
+		r := recent[k.service]
+		if r == nil {
+			r = &memoryBucket{latency: newLatencyHistogram()}
+			recent[k.service] = r
+		}
+		r.merge(b)
+	}
+
+	stats := []Stat{}
+	for _, p := range m.proxies {
+		s := Stat{ServiceName: p.Path, RequestCount: m.totals[p.Path], Vendor: p.Vendor, Target: p.Target}
+		if b := recent[p.Path]; b != nil {
+			if b.requests > 0 {
+				s.ResponseTime = math.Round(float64(b.totalResponseTime)/float64(b.requests)*100) / 100
+			}
+			pct := b.latency.Percentiles()
+			s.P50, s.P90, s.P95, s.P99 = pct.P50, pct.P90, pct.P95, pct.P99
+		}
+		stats = append(stats, s)
+	}
+	slices.SortStableFunc(stats, func(a, b Stat) int { return cmp.Compare(b.RequestCount, a.RequestCount) })
+	return stats
+}
+
+func (m *memoryStats) timeSeries(q StatsQuery) []DailyStat {
+	m.mu.RLock()
+	buckets := m.buckets(sourceFor(q), q)
+	m.mu.RUnlock()
+
+	series := []DailyStat{}
+	for t := bucketStart(q.From, q.Granularity); t.Before(q.To); t = nextBucket(t, q.Granularity) {
+		s := DailyStat{Date: bucketLabel(t, q.Granularity)}
+		if b := buckets[t]; b != nil {
+			s.RequestCount, s.RequestBytes, s.ResponseBytes = b.requests, b.requestBytes, b.responseBytes
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+func (m *memoryStats) distribution(q StatsQuery) []ServiceDistribution {
+	from, to := dayRange(q)
+	m.mu.RLock()
+	byService := make(map[string]*ServiceDistribution)
+	for k, b := range m.daily {
+		if k.period < from || k.period >= to || (q.Service != "" && k.service != q.Service) {
+			continue
+		}
+		d := byService[k.service]
+		if d == nil {
+			d = &ServiceDistribution{ServiceName: k.service}
+			byService[k.service] = d
+		}
+		d.RequestCount += b.requests
+		d.RequestBytes += b.requestBytes
+		d.ResponseBytes += b.responseBytes
+	}
+	m.mu.RUnlock()
+
+	stats := []ServiceDistribution{}
+	for _, d := range byService {
+		stats = append(stats, *d)
+	}
+	slices.SortFunc(stats, func(a, b ServiceDistribution) int {
+		return cmp.Or(cmp.Compare(b.RequestCount, a.RequestCount), strings.Compare(a.ServiceName, b.ServiceName))
+	})
+	return stats
+}
+
+func (m *memoryStats) latency(q StatsQuery) *LatencyReport {
+	m.mu.RLock()
+	buckets := m.buckets(sourceFor(q), q)
+	m.mu.RUnlock()
+
+	total := newLatencyHistogram()
+	for _, b := range buckets {
+		total.Merge(b.latency)
+	}
+	report := &LatencyReport{ServiceName: q.Service, Summary: total.Percentiles(), Series: []LatencyPoint{}}
+	for t := bucketStart(q.From, q.Granularity); t.Before(q.To); t = nextBucket(t, q.Granularity) {
+		h := newLatencyHistogram()
+		if b := buckets[t]; b != nil {
+			h = b.latency
+		}
+		report.Series = append(report.Series, LatencyPoint{Date: bucketLabel(t, q.Granularity), LatencyPercentiles: h.Percentiles()})
+	}
+	return report
+}
+
+func (m *memoryStats) hourlyStats(q StatsQuery) []HourlyStat {
+	q.Granularity = GranularityHour
+	m.mu.RLock()
+	buckets := m.buckets(sourceFor(q), q)
+	m.mu.RUnlock()
+
+	stats := []HourlyStat{}
+	for t := bucketStart(q.From, GranularityHour); t.Before(q.To); t = t.Add(time.Hour) {
+		s := HourlyStat{Hour: bucketLabel(t, GranularityHour)}
+		if b := buckets[t]; b != nil {
+			s.RequestCount, s.SuccessCount, s.ErrorCount = b.requests, b.success, b.errors
+			if b.requests > 0 {
+				s.ErrorRate = math.Round(float64(b.errors)/float64(b.requests)*10000) / 10000
+				s.ResponseTime = math.Round(float64(b.totalResponseTime)/float64(b.requests)*100) / 100
+			}
+			p := b.latency.Percentiles()
+			s.P50, s.P95, s.P99 = p.P50, p.P95, p.P99
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// searchLogs 在环形缓冲区中按 SearchRequestLogs 的语义过滤、排序与分页，q 已经过校验。
+func (m *memoryStats) searchLogs(q LogQuery) (*LogPage, error) {
+	var cursor *logCursor
+	if q.Cursor != "" {
+		c, err := parseLogCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &c
+	}
+	sortValue := func(r RequestLog) int64 {
+		switch q.Sort {
+		case LogSortResponseTime:
+			return r.ResponseTime
+		case LogSortStatusCode:
+			return int64(r.StatusCode)
+		}
+		return r.ID
+	}
+	// compare 按 (排序值, id) 比较，降序时取反
+	compare := func(av, aid, bv, bid int64) int {
+		c := cmp.Or(cmp.Compare(av, bv), cmp.Compare(aid, bid))
+		if !q.Asc {
+			c = -c
+		}
+		return c
+	}
+
+	m.mu.RLock()
+	var matched []RequestLog
+	for _, r := range m.logs {
+		if !q.matches(r) {
+			continue
+		}
+		if cursor != nil && compare(sortValue(r), r.ID, cursor.value, cursor.id) <= 0 {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(matched, func(a, b RequestLog) int {
+		return compare(sortValue(a), a.ID, sortValue(b), b.ID)
+	})
+
+	page := &LogPage{Items: []RequestLog{}}
+	if len(matched) > q.Limit {
+		page.Items = append(page.Items, matched[:q.Limit]...)
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = logCursor{value: sortValue(last), id: last.ID}.encode(q.Sort)
+	} else {
+		page.Items = append(page.Items, matched...)
+	}
+	return page, nil
+}
+
+// matches 判断一条日志是否满足 q 的过滤条件，与 SearchRequestLogs 生成的 SQL 条件一致。
+func (q LogQuery) matches(r RequestLog) bool {
+	switch {
+	case q.Service != "" && r.ServiceName != q.Service,
+		q.Host != "" && r.Host != q.Host,
+		q.StatusMin > 0 && r.StatusCode < q.StatusMin,
+		q.StatusMax > 0 && r.StatusCode > q.StatusMax,
+		!q.From.IsZero() && r.Timestamp.Before(q.From.Truncate(time.Second)),
+		!q.To.IsZero() && !r.Timestamp.Before(q.To.Truncate(time.Second)),
+		q.MinLatency > 0 && r.ResponseTime < q.MinLatency,
+		q.URIContains != "" && !strings.Contains(strings.ToLower(r.RequestURI), strings.ToLower(q.URIContains)),
+		q.ClientIP != "" && r.ClientIP != q.ClientIP,
+		q.RequestID != "" && r.RequestID != q.RequestID:
+		return false
+	}
+	return true
+}
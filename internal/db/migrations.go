@@ -0,0 +1,349 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========================================
+// 结构迁移
+// ========================================
+
+// ErrSchemaTooNew 表示数据库已被更新版本的 go-proxy 迁移过，当前程序无法安全使用。
+var ErrSchemaTooNew = errors.New("数据库结构版本高于当前程序支持的版本")
+
+// migration 是一次编号的结构变更，up 在事务中执行，成功后记录到 schema_migrations。
+// 语句使用 SQLite 写法，建表语句需经过 store.DDL 转换。已发布的迁移不能修改，只能追加新的版本。
+type migration struct {
+	version int
+	name    string
+	up      func(t *txConn) error
+}
+
+var migrations = []migration{
+	{version: 1, name: "initial schema", up: migrateInitialSchema},
+	{version: 2, name: "request log details", up: migrateRequestLogDetails},
+	{version: 3, name: "balance alerts", up: migrateBalanceAlerts},
+}
+
+// MigrationStatus 是一个迁移版本的执行状态。
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool // 数据库中记录了、但当前程序不认识的版本
+}
+
+// LatestSchemaVersion 返回当前程序支持的最新结构版本。
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// ensureMigrationsTable 创建 schema_migrations 表。
+func ensureMigrationsTable() error {
+	_, err := db.Exec(store.DDL(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`))
+	return err
+}
+
+// SchemaVersion 返回数据库当前的结构版本，未执行过任何迁移时为 0。
+func SchemaVersion() (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Migrate 按版本顺序执行尚未应用的迁移，返回本次应用的数量。
+// 数据库版本高于程序支持的版本时返回 ErrSchemaTooNew，不做任何修改。
+func Migrate() (int, error) {
+	current, err := SchemaVersion()
+	if err != nil {
+		return 0, fmt.Errorf("读取数据库结构版本失败: %w", err)
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return 0, fmt.Errorf("%w：数据库为 %d，程序支持 %d，请升级 go-proxy", ErrSchemaTooNew, current, latest)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		ok, err := applyMigration(m)
+		if err != nil {
+			return applied, fmt.Errorf("执行迁移 %d（%s）失败: %w", m.version, m.name, err)
+		}
+		if ok {
+			applied++
+			log.Printf("已应用数据库迁移 %d（%s）。", m.version, m.name)
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration 在事务中执行一个迁移。多个实例同时启动时，
+// 后获得锁的实例会发现迁移已被应用并跳过，此时返回 false。
+func applyMigration(m migration) (bool, error) {
+	t, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer t.Rollback()
+
+	if err := store.LockMigrations(context.Background(), t.Tx); err != nil {
+		return false, err
+	}
+	var exists int
+	if err := t.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists > 0 {
+		return false, nil
+	}
+	if err := m.up(t); err != nil {
+		return false, err
+	}
+	if _, err := t.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return false, err
+	}
+	return true, t.Commit()
+}
+
+// GetMigrationStatus 返回所有已知迁移以及数据库中记录的迁移的状态，按版本排列。
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	var unknown []MigrationStatus
+	for rows.Next() {
+		var s MigrationStatus
+		var at sql.NullTime
+		if err := rows.Scan(&s.Version, &s.Name, &at); err != nil {
+			return nil, err
+		}
+		s.Applied = true
+		s.AppliedAt = at.Time
+		applied[s.Version] = s
+		if s.Version > LatestSchemaVersion() {
+			s.Unknown = true
+			unknown = append(unknown, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(migrations)+len(unknown))
+	for _, m := range migrations {
+		s, ok := applied[m.version]
+		if !ok {
+			s = MigrationStatus{Version: m.version, Name: m.name}
+		}
+		result = append(result, s)
+	}
+	return append(result, unknown...), nil
+}
+
+// ========================================
+// 迁移定义
+// ========================================
+
+// execAll 依次执行建表等语句。
+func execAll(t *txConn, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := t.Exec(store.DDL(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateInitialSchema 创建引入迁移机制时的全部表。
+// 对迁移机制之前创建的数据库，表已存在，只补齐当时按需添加的列。
+func migrateInitialSchema(t *txConn) error {
+	err := execAll(t, `
+	CREATE TABLE IF NOT EXISTS request_stats (
+		service_name TEXT PRIMARY KEY,
+		request_count INTEGER NOT NULL DEFAULT 0
+	);`, `
+	CREATE TABLE IF NOT EXISTS request_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		service_name TEXT NOT NULL,
+		host TEXT,
+		request_uri TEXT,
+		status_code INTEGER,
+		response_time INTEGER DEFAULT 0,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		model TEXT,
+		client_key TEXT,
+		team TEXT,
+		prompt_tokens INTEGER DEFAULT 0,
+		completion_tokens INTEGER DEFAULT 0,
+		cost REAL DEFAULT 0
+	);`, `
+	CREATE TABLE IF NOT EXISTS proxy_config (
+		path TEXT PRIMARY KEY,
+		target TEXT NOT NULL,
+		vendor TEXT
+	);`, `
+	CREATE TABLE IF NOT EXISTS daily_summary (
+		date TEXT NOT NULL,
+		service_name TEXT NOT NULL,
+		request_count INTEGER NOT NULL DEFAULT 0,
+		success_count INTEGER NOT NULL DEFAULT 0,
+		total_response_time INTEGER NOT NULL DEFAULT 0,
+		latency_histogram TEXT,
+		PRIMARY KEY (date, service_name)
+	);`, `
+	CREATE TABLE IF NOT EXISTS hourly_summary (
+		hour TEXT NOT NULL,
+		service_name TEXT NOT NULL,
+		request_count INTEGER NOT NULL DEFAULT 0,
+		success_count INTEGER NOT NULL DEFAULT 0,
+		error_count INTEGER NOT NULL DEFAULT 0,
+		total_response_time INTEGER NOT NULL DEFAULT 0,
+		latency_histogram TEXT,
+		PRIMARY KEY (hour, service_name)
+	);`, `
+	CREATE TABLE IF NOT EXISTS client_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		key_prefix TEXT NOT NULL DEFAULT '',
+		allowed_paths TEXT NOT NULL DEFAULT '',
+		expires_at DATETIME,
+		disabled INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		rate_limit_rpm INTEGER NOT NULL DEFAULT 0,
+		rate_limit_concurrent INTEGER NOT NULL DEFAULT 0,
+		rate_limit_tpm INTEGER NOT NULL DEFAULT 0,
+		team TEXT NOT NULL DEFAULT ''
+	);`, `
+	CREATE TABLE IF NOT EXISTS budget_alerts (
+		scope TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		period TEXT NOT NULL,
+		period_start TEXT NOT NULL,
+		level TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (scope, subject, period, period_start, level)
+	);`, `
+	CREATE TABLE IF NOT EXISTS balance_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account TEXT NOT NULL,
+		provider TEXT,
+		currency TEXT,
+		balance REAL NOT NULL,
+		total REAL DEFAULT 0,
+		used REAL DEFAULT 0,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`, `
+	CREATE TABLE IF NOT EXISTS request_bodies (
+		request_log_id INTEGER PRIMARY KEY,
+		service_name TEXT NOT NULL,
+		content_type TEXT,
+		request_body BLOB,
+		response_body BLOB,
+		request_truncated INTEGER NOT NULL DEFAULT 0,
+		response_truncated INTEGER NOT NULL DEFAULT 0,
+		compressed INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		request_headers TEXT
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// 迁移机制之前的版本在启动时按需添加以下列，旧数据库可能缺少其中一部分
+	legacyColumns := []struct{ table, column, def string }{
+		{"request_logs", "status_code", "INTEGER DEFAULT 0"},
+		{"request_logs", "response_time", "INTEGER DEFAULT 0"},
+		{"request_logs", "model", "TEXT"},
+		{"request_logs", "client_key", "TEXT"},
+		{"request_logs", "team", "TEXT"},
+		{"request_logs", "prompt_tokens", "INTEGER DEFAULT 0"},
+		{"request_logs", "completion_tokens", "INTEGER DEFAULT 0"},
+		{"request_logs", "cost", "REAL DEFAULT 0"},
+		{"daily_summary", "latency_histogram", "TEXT"},
+		{"client_keys", "rate_limit_rpm", "INTEGER NOT NULL DEFAULT 0"},
+		{"client_keys", "rate_limit_concurrent", "INTEGER NOT NULL DEFAULT 0"},
+		{"client_keys", "rate_limit_tpm", "INTEGER NOT NULL DEFAULT 0"},
+		{"client_keys", "team", "TEXT NOT NULL DEFAULT ''"},
+		{"request_bodies", "request_headers", "TEXT"},
+	}
+	for _, c := range legacyColumns {
+		exists, err := store.HasColumn(context.Background(), t.Tx, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		// 表名、列名与类型均为上面的常量
+		if err := execAll(t, `ALTER TABLE `+c.table+` ADD COLUMN `+c.column+` `+c.def+`;`); err != nil {
+			return err
+		}
+		log.Printf("成功添加 %s 列到 %s 表", c.column, c.table)
+	}
+
+	return execAll(t,
+		`CREATE INDEX IF NOT EXISTS idx_request_logs_service_ts ON request_logs(service_name, timestamp DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_request_logs_client_key_ts ON request_logs(client_key, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_balance_history_account_ts ON balance_history(account, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_request_bodies_expires ON request_bodies(expires_at);`,
+	)
+}
+
+// migrateRequestLogDetails 为 request_logs 增加请求方法、客户端、流量、上游与请求 ID，
+// 并在 daily_summary、hourly_summary 中按天、按小时汇总流量。
+func migrateRequestLogDetails(t *txConn) error {
+	return execAll(t,
+		`ALTER TABLE request_logs ADD COLUMN method TEXT;`,
+		`ALTER TABLE request_logs ADD COLUMN client_ip TEXT;`,
+		`ALTER TABLE request_logs ADD COLUMN user_agent TEXT;`,
+		`ALTER TABLE request_logs ADD COLUMN request_bytes INTEGER DEFAULT 0;`,
+		`ALTER TABLE request_logs ADD COLUMN response_bytes INTEGER DEFAULT 0;`,
+		`ALTER TABLE request_logs ADD COLUMN upstream TEXT;`,
+		`ALTER TABLE request_logs ADD COLUMN request_id TEXT;`,
+		`ALTER TABLE daily_summary ADD COLUMN request_bytes INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE daily_summary ADD COLUMN response_bytes INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE hourly_summary ADD COLUMN request_bytes INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE hourly_summary ADD COLUMN response_bytes INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE INDEX IF NOT EXISTS idx_request_logs_request_id ON request_logs(request_id);`,
+	)
+}
+
+// migrateBalanceAlerts 创建 balance_alerts，保存余额告警最近一次发送时间，避免重启后重复通知。
+func migrateBalanceAlerts(t *txConn) error {
+	return execAll(t, `
+	CREATE TABLE IF NOT EXISTS balance_alerts (
+		alert_key TEXT PRIMARY KEY,
+		sent_at DATETIME NOT NULL
+	);`)
+}
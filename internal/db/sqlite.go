@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // 纯 Go SQLite driver，无需 CGO
+)
+
+// DefaultSQLitePath 是未配置 database.dsn 时 SQLite 数据库文件的路径。
+const DefaultSQLitePath = "data/stats.db"
+
+// sqliteStore 是默认的存储后端，dsn 为数据库文件路径。
+type sqliteStore struct{}
+
+func (sqliteStore) Driver() string { return DriverSQLite }
+
+func (sqliteStore) Open(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		dsn = DefaultSQLitePath
+	}
+	if path, err := sqliteFilePath(dsn); err == nil {
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// 启用 WAL 模式
+	if _, err := sqlDB.Exec("PRAGMA journal_mode=WAL;"); err != nil {
+		log.Printf("启用 WAL 模式时出错: %v", err)
+	} else {
+		log.Println("WAL 模式已成功启用。")
+	}
+	return sqlDB, nil
+}
+
+// sqliteFilePath 返回 SQLite DSN 对应的数据库文件路径：去掉 file: 前缀与 ? 之后的连接参数。
+// 内存数据库没有对应的文件，返回错误。
+func sqliteFilePath(dsn string) (string, error) {
+	if dsn == "" {
+		return DefaultSQLitePath, nil
+	}
+	path := dsn
+	if rest, ok := strings.CutPrefix(path, "file:"); ok {
+		path, _, _ = strings.Cut(rest, "?")
+		// file:///abs/path 与 file://localhost/abs/path 形式的 URI
+		if p, ok := strings.CutPrefix(path, "//"); ok {
+			p = strings.TrimPrefix(p, "localhost")
+			if !strings.HasPrefix(p, "/") {
+				return "", fmt.Errorf("不支持的 SQLite DSN: %s", dsn)
+			}
+			path = p
+		}
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			path = unescaped
+		}
+		if strings.Contains(dsn, "mode=memory") {
+			path = ":memory:"
+		}
+	}
+	if path == "" || path == ":memory:" {
+		return "", fmt.Errorf("SQLite DSN %q 是内存数据库，没有对应的文件", dsn)
+	}
+	return path, nil
+}
+
+func (sqliteStore) Rebind(query string) string { return query }
+
+func (sqliteStore) DDL(stmt string) string { return stmt }
+
+func (sqliteStore) HasColumn(ctx context.Context, q queryRower, table, column string) (bool, error) {
+	var count int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	return count > 0, err
+}
+
+// LockMigrations 无需额外加锁：SQLite 写事务本身是串行的，且只由一个进程使用。
+func (sqliteStore) LockMigrations(ctx context.Context, tx *sql.Tx) error { return nil }
+
+func (sqliteStore) LocalDate(expr string) string {
+	return "date(" + expr + ", 'localtime')"
+}
+
+func (sqliteStore) LocalHour(expr string) string {
+	return "strftime('%Y-%m-%d %H:00:00', " + expr + ", 'localtime')"
+}
+
+// Vacuum 执行 VACUUM。SQLite DELETE 只将页面加入 freelist，不会自动缩减文件，必须显式执行。
+func (sqliteStore) Vacuum(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "VACUUM;")
+	return err
+}
+
+// Backup 使用 VACUUM INTO 生成快照：在一个读事务中完成，不阻塞写入，结果是压缩过的完整数据库文件。
+func (sqliteStore) Backup(ctx context.Context, db *sql.DB, path string) error {
+	_, err := db.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
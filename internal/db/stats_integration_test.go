@@ -0,0 +1,201 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/types"
+)
+
+// pgDSNEnv 指定用于集成测试的 PostgreSQL 连接串，未设置时跳过 PostgreSQL 用例。
+// 测试在临时 schema 中运行，结束后删除。
+const pgDSNEnv = "GO_PROXY_TEST_PG_DSN"
+
+func TestStatsQueriesSQLite(t *testing.T) {
+	openTestDB(t, config.DatabaseConfig{Driver: DriverSQLite, DSN: filepath.Join(t.TempDir(), "stats.db")})
+	exerciseStatsQueries(t)
+}
+
+func TestStatsQueriesPostgres(t *testing.T) {
+	dsn := os.Getenv(pgDSNEnv)
+	if dsn == "" {
+		t.Skipf("未设置 %s，跳过 PostgreSQL 集成测试", pgDSNEnv)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("go_proxy_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("创建测试 schema 失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("删除测试 schema 失败: %v", err)
+		}
+	})
+
+	openTestDB(t, config.DatabaseConfig{Driver: DriverPostgres, DSN: withSearchPath(dsn, schema)})
+	exerciseStatsQueries(t)
+}
+
+// withSearchPath 为 URL 或 key=value 形式的连接串追加 search_path。
+func withSearchPath(dsn, schema string) string {
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+// openTestDB 打开数据库并执行全部迁移，测试结束时关闭并还原包级状态。
+func openTestDB(t *testing.T, cfg config.DatabaseConfig) {
+	t.Helper()
+	if err := OpenDB(cfg); err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		CloseDB()
+		db, store = nil, nil
+		InvalidateCache()
+	})
+
+	applied, err := Migrate()
+	if err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	if applied != LatestSchemaVersion() {
+		t.Errorf("应用了 %d 个迁移, want %d", applied, LatestSchemaVersion())
+	}
+	if v, err := SchemaVersion(); err != nil || v != LatestSchemaVersion() {
+		t.Fatalf("SchemaVersion = %d, %v", v, err)
+	}
+	// 再次迁移不应重复执行
+	if applied, err := Migrate(); err != nil || applied != 0 {
+		t.Fatalf("重复迁移 = %d, %v", applied, err)
+	}
+}
+
+// exerciseStatsQueries 写入一批请求并检查统计接口使用的各个查询。
+func exerciseStatsQueries(t *testing.T) {
+	t.Helper()
+	if err := UpdateProxyConfig([]config.ProxyConfig{
+		{Path: "/openai", Target: "https://api.openai.com", Vendor: "openai"},
+		{Path: "/claude", Target: "https://api.anthropic.com", Vendor: "anthropic"},
+	}); err != nil {
+		t.Fatalf("UpdateProxyConfig: %v", err)
+	}
+
+	stats := []types.RequestStat{
+		{ServiceName: "/openai", Host: "localhost", RequestURI: "/openai/v1/chat/completions?step=1", StatusCode: 200, ResponseTime: 120,
+			Model: "gpt-4o", ClientKey: "alice", Team: "red", PromptTokens: 10, CompletionTokens: 5, Cost: 0.01,
+			Method: "POST", ClientIP: "10.0.0.1", UserAgent: "test", RequestBytes: 100, ResponseBytes: 1000, Upstream: "https://api.openai.com", RequestID: "req-1"},
+		{ServiceName: "/openai", Host: "localhost", RequestURI: "/openai/v1/models", StatusCode: 500, ResponseTime: 900,
+			ClientKey: "alice", Team: "red", Method: "GET", ClientIP: "10.0.0.1", RequestBytes: 0, ResponseBytes: 50, RequestID: "req-2"},
+		{ServiceName: "/claude", Host: "localhost", RequestURI: "/claude/v1/messages", StatusCode: 200, ResponseTime: 300,
+			ClientKey: "bob", Team: "red", PromptTokens: 20, CompletionTokens: 30, Cost: 0.02,
+			Method: "POST", ClientIP: "10.0.0.2", RequestBytes: 200, ResponseBytes: 2000, RequestID: "req-3"},
+	}
+	if err := BatchLogRequestDetails(stats); err != nil {
+		t.Fatalf("BatchLogRequestDetails: %v", err)
+	}
+	if err := BatchIncrementRequestCounts(map[string]int{"/openai": 2, "/claude": 1}); err != nil {
+		t.Fatalf("BatchIncrementRequestCounts: %v", err)
+	}
+	if err := AggregateDaily(1); err != nil {
+		t.Fatalf("AggregateDaily: %v", err)
+	}
+	if err := AggregateHourly(2); err != nil {
+		t.Fatalf("AggregateHourly: %v", err)
+	}
+
+	list, err := GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	counts := map[string]int{}
+	for _, s := range list {
+		counts[s.ServiceName] = s.RequestCount
+	}
+	if counts["/openai"] != 2 || counts["/claude"] != 1 {
+		t.Errorf("GetStats 请求数 = %v", counts)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := StatsQuery{From: today, To: today.AddDate(0, 0, 1), Granularity: GranularityDay}
+
+	series, err := GetTimeSeries(day)
+	if err != nil {
+		t.Fatalf("GetTimeSeries: %v", err)
+	}
+	if len(series) != 1 || series[0].RequestCount != 3 || series[0].RequestBytes != 300 || series[0].ResponseBytes != 3050 {
+		t.Errorf("GetTimeSeries = %+v", series)
+	}
+
+	dist, err := GetServiceDistribution(day)
+	if err != nil {
+		t.Fatalf("GetServiceDistribution: %v", err)
+	}
+	if len(dist) != 2 || dist[0].ServiceName != "/openai" || dist[0].RequestCount != 2 {
+		t.Errorf("GetServiceDistribution = %+v", dist)
+	}
+
+	report, err := GetLatency(StatsQuery{From: day.From, To: day.To, Granularity: GranularityDay, Service: "/openai"})
+	if err != nil {
+		t.Fatalf("GetLatency: %v", err)
+	}
+	if report.Summary.Count != 2 {
+		t.Errorf("GetLatency count = %d, want 2", report.Summary.Count)
+	}
+
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+	hourly, err := GetHourlyStats(StatsQuery{From: hour, To: hour.Add(time.Hour), Granularity: GranularityHour})
+	if err != nil {
+		t.Fatalf("GetHourlyStats: %v", err)
+	}
+	if len(hourly) != 1 || hourly[0].RequestCount != 3 || hourly[0].ErrorCount != 1 {
+		t.Errorf("GetHourlyStats = %+v", hourly)
+	}
+
+	page, err := SearchRequestLogs(LogQuery{Service: "/openai", StatusMin: 500, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchRequestLogs: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].RequestID != "req-2" || page.Items[0].Method != "GET" {
+		t.Errorf("SearchRequestLogs = %+v", page.Items)
+	}
+	page, err = SearchRequestLogs(LogQuery{Sort: LogSortResponseTime, Limit: 2})
+	if err != nil {
+		t.Fatalf("SearchRequestLogs 排序: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].ResponseTime != 900 || page.NextCursor == "" {
+		t.Fatalf("SearchRequestLogs 排序 = %+v", page)
+	}
+	next, err := SearchRequestLogs(LogQuery{Sort: LogSortResponseTime, Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("SearchRequestLogs 翻页: %v", err)
+	}
+	if len(next.Items) != 1 || next.Items[0].ResponseTime != 120 || next.NextCursor != "" {
+		t.Errorf("SearchRequestLogs 翻页 = %+v", next)
+	}
+
+	used, err := SumUsageSince("team", "red", today)
+	if err != nil {
+		t.Fatalf("SumUsageSince: %v", err)
+	}
+	if used.Tokens != 65 {
+		t.Errorf("SumUsageSince tokens = %d, want 65", used.Tokens)
+	}
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========================================
+// 参数化统计查询
+// ========================================
+
+// 时间序列的粒度
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// StatsQuery 是时间序列与服务分布的查询参数。From 为起点（含），To 为终点（不含），均为本地时间。
+// Service 为空表示全部服务。
+type StatsQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Service     string
+}
+
+func (q StatsQuery) cacheKey(kind string) string {
+	return fmt.Sprintf("%s|%d|%d|%s|%s", kind, q.From.Unix(), q.To.Unix(), q.Granularity, q.Service)
+}
+
+// bucketStart 返回 t 所在时间段的起点，周从周一开始。
+func bucketStart(t time.Time, granularity string) time.Time {
+	y, m, d := t.Date()
+	switch granularity {
+	case GranularityHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case GranularityWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	case GranularityMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+func nextBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityHour:
+		return t.Add(time.Hour)
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func bucketLabel(t time.Time, granularity string) string {
+	switch granularity {
+	case GranularityHour:
+		return t.Format("2006-01-02 15:00")
+	case GranularityMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format(time.DateOnly)
+	}
+}
+
+// dayRange 返回 daily_summary 的日期查询范围 [from, to)，终点不在零点时包含终点所在的那一天。
+func dayRange(q StatsQuery) (string, string) {
+	end := q.To
+	if end.After(bucketStart(end, GranularityDay)) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return q.From.Format(time.DateOnly), end.Format(time.DateOnly)
+}
+
+// hourRange 返回 hourly_summary 的小时查询范围 [from, to)，终点不在整点时包含终点所在的小时。
+func hourRange(q StatsQuery) (string, string) {
+	end := q.To
+	if end.After(bucketStart(end, GranularityHour)) {
+		end = bucketStart(end, GranularityHour).Add(time.Hour)
+	}
+	return bucketStart(q.From, GranularityHour).Format(time.DateTime), end.Format(time.DateTime)
+}
+
+// summarySource 描述按粒度选用的预聚合表：小时粒度读 hourly_summary，其余粒度读 daily_summary。
+type summarySource struct {
+	table    string
+	keyCol   string
+	layout   string // keyCol 的时间格式
+	from, to string
+}
+
+func sourceFor(q StatsQuery) summarySource {
+	if q.Granularity == GranularityHour {
+		from, to := hourRange(q)
+		return summarySource{table: "hourly_summary", keyCol: "hour", layout: time.DateTime, from: from, to: to}
+	}
+	from, to := dayRange(q)
+	return summarySource{table: "daily_summary", keyCol: "date", layout: time.DateOnly, from: from, to: to}
+}
+
+// where 返回时间范围与服务的过滤条件及参数。
+func (src summarySource) where(q StatsQuery) (string, []any) {
+	cond := fmt.Sprintf("%s >= ? AND %s < ?", src.keyCol, src.keyCol)
+	args := []any{src.from, src.to}
+	if q.Service != "" {
+		cond += " AND service_name = ?"
+		args = append(args, q.Service)
+	}
+	return cond, args
+}
+
+// bucketOf 将 keyCol 的值解析为所属时间段的起点。
+func (src summarySource) bucketOf(key string, q StatsQuery) (time.Time, bool) {
+	t, err := time.ParseInLocation(src.layout, key, q.From.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return bucketStart(t, q.Granularity), true
+}
+
+// GetTimeSeriesWithCache 带缓存的时间序列查询。
+func GetTimeSeriesWithCache(q StatsQuery) ([]DailyStat, error) {
+	return cachedQuery(q.cacheKey("series"), func() ([]DailyStat, error) { return GetTimeSeries(q) })
+}
+
+// GetDistributionWithCache 带缓存的服务分布查询。
+func GetDistributionWithCache(q StatsQuery) ([]ServiceDistribution, error) {
+	return cachedQuery(q.cacheKey("distribution"), func() ([]ServiceDistribution, error) { return GetServiceDistribution(q) })
+}
+
+// GetTimeSeries 返回 [From, To) 内按粒度汇总的调用次数与流量，没有数据的时间段补 0。
+// 小时粒度从 hourly_summary 汇总，其余粒度从 daily_summary 汇总。
+func GetTimeSeries(q StatsQuery) ([]DailyStat, error) {
+	if mem != nil {
+		return mem.timeSeries(q), nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	src := sourceFor(q)
+	cond, args := src.where(q)
+	query := fmt.Sprintf(`SELECT %s, SUM(request_count), SUM(request_bytes), SUM(response_bytes) FROM %s WHERE %s GROUP BY %s`,
+		src.keyCol, src.table, cond, src.keyCol)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("查询时间序列统计时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make(map[time.Time]DailyStat)
+	for rows.Next() {
+		var key string
+		var s DailyStat
+		if err := rows.Scan(&key, &s.RequestCount, &s.RequestBytes, &s.ResponseBytes); err != nil {
+			log.Printf("扫描时间序列统计行时出错: %v", err)
+			continue
+		}
+		if t, ok := src.bucketOf(key, q); ok {
+			b := buckets[t]
+			b.RequestCount += s.RequestCount
+			b.RequestBytes += s.RequestBytes
+			b.ResponseBytes += s.ResponseBytes
+			buckets[t] = b
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("迭代时间序列统计行时出错: %v", err)
+		return nil, err
+	}
+
+	series := []DailyStat{}
+	for t := bucketStart(q.From, q.Granularity); t.Before(q.To); t = nextBucket(t, q.Granularity) {
+		s := buckets[t]
+		s.Date = bucketLabel(t, q.Granularity)
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// GetServiceDistribution 返回 [From, To) 所覆盖日期内各服务的调用次数与流量，按天精度从 daily_summary 汇总。
+func GetServiceDistribution(q StatsQuery) ([]ServiceDistribution, error) {
+	if mem != nil {
+		return mem.distribution(q), nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	from, to := dayRange(q)
+	query := `
+	SELECT service_name, SUM(request_count) AS request_count,
+		SUM(request_bytes), SUM(response_bytes)
+	FROM daily_summary
+	WHERE date >= ? AND date < ?`
+	args := []any{from, to}
+	if q.Service != "" {
+		query += ` AND service_name = ?`
+		args = append(args, q.Service)
+	}
+	query += `
+	GROUP BY service_name
+	ORDER BY request_count DESC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("查询服务调用分布时出错: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []ServiceDistribution{}
+	for rows.Next() {
+		var s ServiceDistribution
+		if err := rows.Scan(&s.ServiceName, &s.RequestCount, &s.RequestBytes, &s.ResponseBytes); err != nil {
+			log.Printf("扫描服务调用分布行时出错: %v", err)
+			continue
+		}
+		stats = append(stats, s)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("迭代服务调用分布行时出错: %v", err)
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ========================================
+// 存储后端
+// ========================================
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory" // 不使用数据库，见 memory.go
+)
+
+// Store 封装不同数据库之间的差异。包内的 SQL 统一使用 SQLite 写法（? 占位符、SQLite 建表语句），
+// 由 Store 负责打开连接并在执行前转换为对应方言。
+type Store interface {
+	// Driver 返回驱动名称（DriverSQLite 或 DriverPostgres）。
+	Driver() string
+	// Open 打开并配置数据库连接。
+	Open(dsn string) (*sql.DB, error)
+	// Rebind 将 ? 占位符转换为本方言的写法。
+	Rebind(query string) string
+	// DDL 将 SQLite 建表、建索引语句转换为本方言。
+	DDL(stmt string) string
+	// HasColumn 检查表中是否已有指定的列。
+	HasColumn(ctx context.Context, q queryRower, table, column string) (bool, error)
+	// LockMigrations 在迁移事务中加锁，避免多个实例同时迁移同一个数据库。
+	LockMigrations(ctx context.Context, tx *sql.Tx) error
+	// LocalDate 返回把 UTC 时间列 expr 转换为本地日期（YYYY-MM-DD）的表达式。
+	LocalDate(expr string) string
+	// LocalHour 返回把 UTC 时间列 expr 转换为本地整点（YYYY-MM-DD HH:00:00）的表达式。
+	LocalHour(expr string) string
+	// Vacuum 回收已删除数据占用的空间。
+	Vacuum(ctx context.Context, db *sql.DB) error
+	// Backup 在不停止服务的情况下把数据库的一致性快照写入文件 path，不支持时返回 ErrBackupUnsupported。
+	Backup(ctx context.Context, db *sql.DB, path string) error
+}
+
+// queryRower 是 *sql.DB 与 *sql.Tx 共有的单行查询方法。
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// newStore 按驱动名称创建存储后端，timezone 为 PostgreSQL 会话使用的时区。
+func newStore(driver, timezone string) (Store, error) {
+	switch driver {
+	case "", DriverSQLite:
+		return sqliteStore{}, nil
+	case DriverPostgres, "postgresql", "pgx":
+		return postgresStore{timezone: timezone}, nil
+	case DriverMemory:
+		return nil, fmt.Errorf("database.driver 为 memory 时没有数据库，统计数据只保存在服务进程的内存中")
+	}
+	return nil, fmt.Errorf("不支持的数据库驱动 %q，可选 sqlite、postgres", driver)
+}
+
+// Driver 返回当前使用的数据库驱动，内存模式返回 DriverMemory，数据库未初始化时返回空字符串。
+func Driver() string {
+	if mem != nil {
+		return DriverMemory
+	}
+	if store == nil {
+		return ""
+	}
+	return store.Driver()
+}
+
+// conn 包装 *sql.DB，执行前按 store 转换占位符，包内其余代码可以像使用 *sql.DB 一样使用它。
+type conn struct {
+	*sql.DB
+	store Store
+}
+
+func (c *conn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.DB.Exec(c.store.Rebind(query), args...)
+}
+
+func (c *conn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.DB.Query(c.store.Rebind(query), args...)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.DB.QueryContext(ctx, c.store.Rebind(query), args...)
+}
+
+func (c *conn) QueryRow(query string, args ...any) *sql.Row {
+	return c.DB.QueryRow(c.store.Rebind(query), args...)
+}
+
+func (c *conn) Begin() (*txConn, error) {
+	t, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txConn{Tx: t, store: c.store}, nil
+}
+
+// txConn 包装 *sql.Tx，作用与 conn 相同。
+type txConn struct {
+	*sql.Tx
+	store Store
+}
+
+func (t *txConn) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.Exec(t.store.Rebind(query), args...)
+}
+
+func (t *txConn) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.Query(t.store.Rebind(query), args...)
+}
+
+func (t *txConn) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRow(t.store.Rebind(query), args...)
+}
+
+func (t *txConn) Prepare(query string) (*sql.Stmt, error) {
+	return t.Tx.Prepare(t.store.Rebind(query))
+}
+
+// rebindNumbered 将 ? 占位符依次替换为 $1、$2……
+// 单引号字符串、双引号标识符与注释中的问号保持原样，?? 输出为字面量 ?（例如 jsonb 的 ? 运算符）。
+func rebindNumbered(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			// 原样复制到配对的引号为止，字符串中的 '' 与标识符中的 "" 是转义，连续两次配对后结果不变
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case ch == '?' && strings.HasPrefix(query[i:], "??"):
+			b.WriteByte('?')
+			i++
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// boolInt 将布尔值转换为整数，写入 INTEGER 列时使用（PostgreSQL 不会自动转换）。
+func boolInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
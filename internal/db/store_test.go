@@ -0,0 +1,74 @@
+package db
+
+import "testing"
+
+func TestRebindNumbered(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "no placeholders",
+			query: "SELECT 1",
+			want:  "SELECT 1",
+		},
+		{
+			name:  "sequential placeholders",
+			query: "SELECT * FROM request_logs WHERE service_name = ? AND status_code >= ? LIMIT ?",
+			want:  "SELECT * FROM request_logs WHERE service_name = $1 AND status_code >= $2 LIMIT $3",
+		},
+		{
+			name:  "question mark in string literal",
+			query: "SELECT '?' AS q, request_uri FROM request_logs WHERE id = ?",
+			want:  "SELECT '?' AS q, request_uri FROM request_logs WHERE id = $1",
+		},
+		{
+			name:  "escaped quote in string literal",
+			query: "SELECT 'it''s ?' WHERE a = ? AND b = 'x?'",
+			want:  "SELECT 'it''s ?' WHERE a = $1 AND b = 'x?'",
+		},
+		{
+			name:  "question mark in quoted identifier",
+			query: `SELECT "col?" FROM t WHERE a = ?`,
+			want:  `SELECT "col?" FROM t WHERE a = $1`,
+		},
+		{
+			name:  "double question mark is a literal",
+			query: "SELECT data ?? 'key' FROM t WHERE id = ?",
+			want:  "SELECT data ? 'key' FROM t WHERE id = $1",
+		},
+		{
+			name:  "line comment",
+			query: "SELECT a -- why?\nFROM t WHERE a = ?",
+			want:  "SELECT a -- why?\nFROM t WHERE a = $1",
+		},
+		{
+			name:  "line comment at end",
+			query: "SELECT a FROM t WHERE a = ? -- trailing?",
+			want:  "SELECT a FROM t WHERE a = $1 -- trailing?",
+		},
+		{
+			name:  "block comment",
+			query: "SELECT /* ? 'unbalanced */ a FROM t WHERE a = ? AND b = ?",
+			want:  "SELECT /* ? 'unbalanced */ a FROM t WHERE a = $1 AND b = $2",
+		},
+		{
+			name:  "minus and slash operators",
+			query: "SELECT a - ?, b / ? FROM t",
+			want:  "SELECT a - $1, b / $2 FROM t",
+		},
+		{
+			name:  "unterminated string",
+			query: "SELECT ? WHERE a = 'x?",
+			want:  "SELECT $1 WHERE a = 'x?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebindNumbered(tt.query); got != tt.want {
+				t.Errorf("rebindNumbered(%q)\n got: %q\nwant: %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
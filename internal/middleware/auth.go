@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// ClientKeyContextKey 是鉴权通过后保存在 echo.Context 中的 *types.ClientKey 的键
+	ClientKeyContextKey = "client_key"
+	// clientKeyPrefix 是管理接口生成的密钥前缀
+	clientKeyPrefix = "gp-"
+	// keyPrefixLen 是保存用于识别的密钥前缀长度
+	keyPrefixLen = 7
+	// keyCacheTTL 是数据库密钥查询结果的缓存时间，多实例部署时其他实例最迟在这段时间后看到管理接口的修改
+	keyCacheTTL = 30 * time.Second
+)
+
+// HashClientKey 返回客户端密钥的 SHA-256 十六进制摘要，SQLite 中只保存该摘要。
+func HashClientKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateClientKey 生成一个新的随机客户端密钥。
+func GenerateClientKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return clientKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// KeyPrefix 返回用于展示的密钥前缀。
+func KeyPrefix(key string) string {
+	if len(key) <= keyPrefixLen {
+		return key
+	}
+	return key[:keyPrefixLen]
+}
+
+// cachedKey 是缓存的数据库密钥及其过期时间。
+type cachedKey struct {
+	key     *types.ClientKey
+	expires time.Time
+}
+
+// KeyStore 合并配置文件与 SQLite 中的客户端密钥，数据库中的密钥会短暂缓存。
+type KeyStore struct {
+	header string
+	keys   map[string]*types.ClientKey // 配置文件中的密钥，键为哈希
+
+	mu    sync.Mutex
+	cache map[string]cachedKey // 数据库中查到的密钥，键为哈希
+}
+
+// NewKeyStore 根据鉴权配置创建密钥存储。
+func NewKeyStore(cfg config.AuthConfig) *KeyStore {
+	s := &KeyStore{
+		header: cfg.Header,
+		keys:   make(map[string]*types.ClientKey, len(cfg.Keys)),
+		cache:  make(map[string]cachedKey),
+	}
+	for _, k := range cfg.Keys {
+		if k.Key == "" {
+			log.Printf("客户端密钥 %q 未设置 key，已忽略", k.Name)
+			continue
+		}
+		s.keys[HashClientKey(k.Key)] = &types.ClientKey{
+			Name:         k.Name,
+			KeyPrefix:    KeyPrefix(k.Key),
+			AllowedPaths: k.AllowedPaths,
+			ExpiresAt:    k.ExpiresAt,
+			Disabled:     k.Disabled,
+			Source:       "config",
+			Team:         k.Team,
+
+			RateLimitRPM:        k.RateLimit.RPM,
+			RateLimitTPM:        k.RateLimit.TPM,
+			RateLimitConcurrent: k.RateLimit.Concurrent,
+		}
+	}
+	return s
+}
+
+// Keys 返回配置文件中声明的密钥（不含完整密钥）。
+func (s *KeyStore) Keys() []types.ClientKey {
+	keys := make([]types.ClientKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	return keys
+}
+
+// Lookup 查找密钥，先查配置文件，再查缓存与 SQLite。只缓存查到的密钥，
+// 不存在的密钥与查询错误不缓存，避免随机密钥撑大缓存。
+func (s *KeyStore) Lookup(key string) (*types.ClientKey, error) {
+	hash := HashClientKey(key)
+	if k, ok := s.keys[hash]; ok {
+		return k, nil
+	}
+	if !db.IsInitialized() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	entry, ok := s.cache[hash]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.key, nil
+	}
+
+	k, err := db.GetClientKeyByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if k != nil {
+		s.cache[hash] = cachedKey{key: k, expires: now.Add(keyCacheTTL)}
+	} else {
+		delete(s.cache, hash)
+	}
+	s.mu.Unlock()
+	return k, nil
+}
+
+// Invalidate 清空数据库密钥缓存，管理接口修改或删除密钥后调用，使修改立即生效。
+func (s *KeyStore) Invalidate() {
+	s.mu.Lock()
+	s.cache = make(map[string]cachedKey)
+	s.mu.Unlock()
+}
+
+// extractKey 依次从自定义请求头、Authorization、x-api-key 中读取客户端密钥，返回密钥及其所在的请求头。
+func (s *KeyStore) extractKey(req *http.Request) (key, header string) {
+	if s.header != "" {
+		if v := strings.TrimSpace(req.Header.Get(s.header)); v != "" {
+			return strings.TrimPrefix(v, "Bearer "), s.header
+		}
+	}
+	if v := req.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(v, "Bearer ")), "Authorization"
+	}
+	if v := strings.TrimSpace(req.Header.Get("x-api-key")); v != "" {
+		return v, "x-api-key"
+	}
+	return "", ""
+}
+
+// Authenticate 校验请求携带的客户端密钥，成功后移除该请求头，避免客户端密钥被转发到上游。
+func (s *KeyStore) Authenticate(req *http.Request) (*types.ClientKey, errorKind, string) {
+	key, header := s.extractKey(req)
+	if key == "" {
+		return nil, errAuthentication, "缺少客户端密钥"
+	}
+	clientKey, err := s.Lookup(key)
+	if err != nil {
+		// 数据库故障不代表密钥无效，返回 503 以便客户端重试（错误已由 db 包记录）
+		return nil, errUnavailable, "暂时无法校验客户端密钥，请稍后重试"
+	}
+	if clientKey == nil {
+		return nil, errAuthentication, "无效的客户端密钥"
+	}
+	if clientKey.Disabled {
+		return nil, errPermission, "客户端密钥已被禁用"
+	}
+	if clientKey.Expired(time.Now()) {
+		return nil, errAuthentication, "客户端密钥已过期"
+	}
+	req.Header.Del(header)
+	return clientKey, 0, ""
+}
+
+// AuthMiddleware 创建代理路由的客户端鉴权中间件，需位于 StatsMiddleware 之后以便统计被拒绝的请求。
+func AuthMiddleware(proxyCfg config.ProxyConfig, store *KeyStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// CORS 预检请求不携带凭据
+			if c.Request().Method == http.MethodOptions {
+				return next(c)
+			}
+
+			// 模型路由统一入口已经校验过密钥，此时只需检查代理路径权限
+			clientKey := ClientKeyFromContext(c)
+			if clientKey == nil {
+				k, kind, message := store.Authenticate(c.Request())
+				if k == nil {
+					if kind != errUnavailable {
+						log.Printf("拒绝未授权请求: %s %s (%s)", c.Request().Method, proxyCfg.Path, message)
+					}
+					return writeVendorError(c, proxyCfg, statusForKind(kind), kind, message)
+				}
+				clientKey = k
+			}
+			if !clientKey.AllowsPath(proxyCfg.Path) {
+				log.Printf("客户端密钥 %s 无权访问 %s", clientKey.Name, proxyCfg.Path)
+				return writeVendorError(c, proxyCfg, http.StatusForbidden, errPermission, "该客户端密钥无权访问此代理")
+			}
+
+			c.Set(ClientKeyContextKey, clientKey)
+			return next(c)
+		}
+	}
+}
+
+// ClientKeyFromContext 返回鉴权中间件保存的客户端密钥，未启用鉴权时返回 nil。
+func ClientKeyFromContext(c echo.Context) *types.ClientKey {
+	k, _ := c.Get(ClientKeyContextKey).(*types.ClientKey)
+	return k
+}
+
+// RequireClientKey 创建只校验密钥有效性、不检查代理路径的中间件，用于聚合类端点与模型路由统一入口。
+func RequireClientKey(store *KeyStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method == http.MethodOptions {
+				return next(c)
+			}
+			clientKey, kind, message := store.Authenticate(c.Request())
+			if clientKey == nil {
+				return writeVendorError(c, config.ProxyConfig{}, statusForKind(kind), kind, message)
+			}
+			c.Set(ClientKeyContextKey, clientKey)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/types"
+)
+
+// 数据库中的密钥在缓存有效期内不再查询数据库，Invalidate 或过期后重新查询。
+func TestKeyStoreLookupCache(t *testing.T) {
+	if err := db.InitDB(config.DatabaseConfig{Driver: db.DriverSQLite, DSN: filepath.Join(t.TempDir(), "stats.db")}, false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseDB)
+
+	key, err := GenerateClientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.CreateClientKey(types.ClientKey{Name: "ci", KeyPrefix: KeyPrefix(key)}, HashClientKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewKeyStore(config.AuthConfig{})
+
+	lookup := func() *types.ClientKey {
+		t.Helper()
+		k, err := store.Lookup(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	if k := lookup(); k == nil || k.ID != id || k.Source != "db" {
+		t.Fatalf("Lookup = %+v", k)
+	}
+
+	// 绕过管理接口直接修改数据库，缓存期内仍返回旧值
+	disabled := true
+	if err := db.UpdateClientKey(id, db.ClientKeyUpdate{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if k := lookup(); k == nil || k.Disabled {
+		t.Fatalf("缓存期内 Lookup = %+v, want 缓存的启用状态", k)
+	}
+
+	store.Invalidate()
+	if k := lookup(); k == nil || !k.Disabled {
+		t.Fatalf("Invalidate 后 Lookup = %+v, want disabled", k)
+	}
+
+	// 缓存过期后重新查询，删除的密钥不再有效
+	if err := db.DeleteClientKey(id); err != nil {
+		t.Fatal(err)
+	}
+	hash := HashClientKey(key)
+	store.mu.Lock()
+	entry := store.cache[hash]
+	entry.expires = time.Now().Add(-time.Second)
+	store.cache[hash] = entry
+	store.mu.Unlock()
+	if k := lookup(); k != nil {
+		t.Fatalf("过期后 Lookup = %+v, want nil", k)
+	}
+	store.mu.Lock()
+	_, cached := store.cache[hash]
+	store.mu.Unlock()
+	if cached {
+		t.Error("不存在的密钥不应留在缓存中")
+	}
+}
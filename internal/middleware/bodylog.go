@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/redact"
+	"go-proxy/pkg/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// BodyLogContextKey 是采样记录的请求/响应体保存在 echo.Context 中的键，值为 *types.RequestBody
+	BodyLogContextKey = "body_log"
+
+	defaultBodyLogMaxBytes      = 64 << 10
+	defaultBodyLogRetentionDays = 7
+
+	// bodyOmitted 在配置了脱敏路径但内容无法解析时代替原文保存
+	bodyOmitted = "[body omitted: cannot apply redaction]"
+)
+
+// limitedBuffer 最多保留 limit 字节，超出部分丢弃并标记为截断。
+type limitedBuffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) write(p []byte) {
+	if room := b.limit - len(b.data); len(p) > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.data = append(b.data, p...)
+}
+
+// teeBody 在上游读取请求体的同时保留副本，不影响流式转发。
+type teeBody struct {
+	io.ReadCloser
+	buf *limitedBuffer
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.buf.write(p[:n])
+	return n, err
+}
+
+// bodyLogWriter 在透传响应的同时保留响应体的前 limit 字节。
+type bodyLogWriter struct {
+	http.ResponseWriter
+	buf *limitedBuffer
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.buf.write(b[:n])
+	return n, err
+}
+
+// Flush 支持流式响应。
+func (w *bodyLogWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter。
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// BodyLogMiddleware 按采样率记录请求与响应体，脱敏、压缩后保存到 BodyLogContextKey，
+// 由统计中间件随请求日志一起写入数据库。脱敏路径配置有误时不记录该代理的任何内容。
+func BodyLogMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
+	cfg := proxyCfg.BodyLog
+	paths, err := redact.CompilePaths(cfg.Redact)
+	if err != nil {
+		log.Printf("代理 %s 的 body_log.redact 配置无效，已禁用请求体记录: %v", proxyCfg.Path, err)
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+	sampleRate := cfg.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 1
+	}
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultBodyLogMaxBytes
+	}
+	retention := cfg.RetentionDays
+	if retention <= 0 {
+		retention = defaultBodyLogRetentionDays
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method == http.MethodOptions || (sampleRate < 1 && rand.Float64() >= sampleRate) {
+				return next(c)
+			}
+
+			reqBuf := &limitedBuffer{limit: maxBytes}
+			if req.Body != nil && req.Body != http.NoBody {
+				req.Body = &teeBody{ReadCloser: req.Body, buf: reqBuf}
+			}
+			res := c.Response()
+			respBuf := &limitedBuffer{limit: maxBytes}
+			bw := &bodyLogWriter{ResponseWriter: res.Writer, buf: respBuf}
+			res.Writer = bw
+			err := next(c)
+			res.Writer = bw.ResponseWriter
+
+			record := &types.RequestBody{
+				ContentType:       res.Header().Get("Content-Type"),
+				RequestTruncated:  reqBuf.truncated,
+				ResponseTruncated: respBuf.truncated,
+				Compressed:        cfg.Compress,
+				ExpiresAt:         time.Now().AddDate(0, 0, retention),
+			}
+			if headers, err := json.Marshal(redact.Header(req.Header)); err == nil {
+				record.RequestHeaders = string(headers)
+			}
+			record.Request = prepareBody(reqBuf.data, req.Header.Get("Content-Encoding"), req.Header.Get("Content-Type"), paths, cfg.Compress)
+			record.Response = prepareBody(respBuf.data, res.Header().Get("Content-Encoding"), record.ContentType, paths, cfg.Compress)
+			c.Set(BodyLogContextKey, record)
+			return err
+		}
+	}
+}
+
+// prepareBody 解压、脱敏并按需压缩捕获的内容。
+func prepareBody(data []byte, encoding, contentType string, paths []redact.JSONPath, compress bool) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	if encoding == "gzip" {
+		// 被截断的 gzip 数据仍可解出截断点之前的内容
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return []byte(bodyOmitted)
+		}
+		data, _ = io.ReadAll(io.LimitReader(zr, 16<<20))
+		zr.Close()
+	}
+
+	redacted, err := redact.Body(data, contentType, paths)
+	if err != nil {
+		redacted = []byte(bodyOmitted)
+	}
+	if !compress {
+		return redacted
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(redacted)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// BodyLogFromContext 返回 BodyLogMiddleware 记录的请求/响应体，未记录时返回 nil。
+func BodyLogFromContext(c echo.Context) *types.RequestBody {
+	b, _ := c.Get(BodyLogContextKey).(*types.RequestBody)
+	return b
+}
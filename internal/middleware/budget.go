@@ -0,0 +1,345 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/notify"
+	"go-proxy/pkg/types"
+	"go-proxy/pkg/usage"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	budgetScopeKey  = "key"
+	budgetScopeTeam = "team"
+
+	budgetPeriodDay   = "day"
+	budgetPeriodMonth = "month"
+
+	budgetLevelSoft      = "soft"
+	budgetLevelExhausted = "exhausted"
+
+	defaultSoftThreshold = 0.8
+)
+
+// BudgetStatus 是某条预算规则在当前周期内的使用情况。
+type BudgetStatus struct {
+	Scope       string    `json:"scope"`
+	Subject     string    `json:"subject"`
+	Period      string    `json:"period"`
+	PeriodStart time.Time `json:"period_start"`
+	ResetAt     time.Time `json:"reset_at"`
+	TokensUsed  int64     `json:"tokens_used"`
+	TokensLimit int64     `json:"tokens_limit"`
+	CostUsed    float64   `json:"cost_used"`
+	CostLimit   float64   `json:"cost_limit"`
+	Currency    string    `json:"currency"`
+	Ratio       float64   `json:"ratio"` // 已用比例，取 token 与费用中较高者
+}
+
+// budgetEntry 是某条规则对某个密钥/团队在一个周期内的累计用量。
+type budgetEntry struct {
+	rule    config.BudgetRule
+	subject string
+	start   time.Time
+	used    db.UsageTotals
+	alerts  map[string]bool // 本周期内已发送的通知级别
+
+	once   sync.Once // 首次访问时在锁外加载已记录的用量
+	loaded bool
+}
+
+// ratio 返回已用比例。
+func (e *budgetEntry) ratio() float64 {
+	var r float64
+	if e.rule.Tokens > 0 {
+		r = float64(e.used.Tokens) / float64(e.rule.Tokens)
+	}
+	if e.rule.Cost > 0 {
+		r = math.Max(r, e.used.Cost/e.rule.Cost)
+	}
+	return r
+}
+
+// BudgetTracker 按客户端密钥/团队统计每日、每月的 token 与费用。
+// 每个周期首次访问时从 request_logs 汇总已记录的用量，之后在内存中累加，因此重启后预算不会清零。
+// 汇总查询在锁外执行，同一条目的并发请求只查询一次，其他条目不受影响。
+type BudgetTracker struct {
+	cfg config.BudgetConfig
+
+	mu      sync.Mutex // 保护 entries 以及条目的 used、alerts、loaded
+	entries map[string]*budgetEntry
+}
+
+// NewBudgetTracker 根据配置创建预算跟踪器。
+func NewBudgetTracker(cfg config.BudgetConfig) *BudgetTracker {
+	if cfg.Currency == "" {
+		cfg.Currency = "USD"
+	}
+	for i := range cfg.Rules {
+		if cfg.Rules[i].Scope == "" {
+			cfg.Rules[i].Scope = budgetScopeKey
+		}
+		if cfg.Rules[i].Period != budgetPeriodMonth {
+			cfg.Rules[i].Period = budgetPeriodDay
+		}
+		if cfg.Rules[i].SoftThreshold <= 0 || cfg.Rules[i].SoftThreshold >= 1 {
+			cfg.Rules[i].SoftThreshold = defaultSoftThreshold
+		}
+	}
+	return &BudgetTracker{cfg: cfg, entries: make(map[string]*budgetEntry)}
+}
+
+// periodBounds 返回 now 所在周期的起止时间（服务器本地时间）。
+func periodBounds(period string, now time.Time) (start, end time.Time) {
+	y, m, d := now.Date()
+	if period == budgetPeriodMonth {
+		start = time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// subjectFor 返回规则作用于该密钥时的对象名称，不适用时返回空字符串。
+func subjectFor(rule config.BudgetRule, k *types.ClientKey) string {
+	subject := k.Name
+	if rule.Scope == budgetScopeTeam {
+		subject = k.Team
+	}
+	if subject == "" || (rule.Name != "" && rule.Name != "*" && rule.Name != subject) {
+		return ""
+	}
+	return subject
+}
+
+// entriesFor 返回适用于该密钥的当前周期条目，并确保条目已加载。调用方不能持有锁。
+func (t *BudgetTracker) entriesFor(k *types.ClientKey, now time.Time) []*budgetEntry {
+	var result []*budgetEntry
+	t.mu.Lock()
+	for i, rule := range t.cfg.Rules {
+		subject := subjectFor(rule, k)
+		if subject == "" {
+			continue
+		}
+		start, _ := periodBounds(rule.Period, now)
+		id := fmt.Sprintf("%d|%s|%d", i, subject, start.Unix())
+		e, ok := t.entries[id]
+		if !ok {
+			e = &budgetEntry{rule: rule, subject: subject, start: start, alerts: map[string]bool{}}
+			t.prune(now)
+			t.entries[id] = e
+		}
+		result = append(result, e)
+	}
+	t.mu.Unlock()
+
+	for _, e := range result {
+		e.once.Do(func() { t.load(e) })
+	}
+	return result
+}
+
+// load 从数据库汇总周期内已记录的用量与已发送的通知，查询在锁外进行，结果在锁内合并。
+func (t *BudgetTracker) load(e *budgetEntry) {
+	var used db.UsageTotals
+	var alerts map[string]bool
+	if db.IsInitialized() {
+		column := "client_key"
+		if e.rule.Scope == budgetScopeTeam {
+			column = "team"
+		}
+		if u, err := db.SumUsageSince(column, e.subject, e.start); err == nil {
+			used = u
+		}
+		if a, err := db.GetBudgetAlerts(e.rule.Scope, e.subject, e.rule.Period, e.start.Format(time.DateOnly)); err == nil {
+			alerts = a
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.used = used
+	for level := range alerts {
+		e.alerts[level] = true
+	}
+	// 耗尽通知隐含已跨过软阈值
+	if e.alerts[budgetLevelExhausted] {
+		e.alerts[budgetLevelSoft] = true
+	}
+	e.loaded = true
+}
+
+// prune 删除已过期周期的条目，调用方需持有锁。
+func (t *BudgetTracker) prune(now time.Time) {
+	for id, e := range t.entries {
+		if start, _ := periodBounds(e.rule.Period, now); e.start.Before(start) {
+			delete(t.entries, id)
+		}
+	}
+}
+
+// status 生成条目的状态快照。
+func (t *BudgetTracker) status(e *budgetEntry) BudgetStatus {
+	_, end := periodBounds(e.rule.Period, e.start)
+	return BudgetStatus{
+		Scope:       e.rule.Scope,
+		Subject:     e.subject,
+		Period:      e.rule.Period,
+		PeriodStart: e.start,
+		ResetAt:     end,
+		TokensUsed:  e.used.Tokens,
+		TokensLimit: e.rule.Tokens,
+		CostUsed:    e.used.Cost,
+		CostLimit:   e.rule.Cost,
+		Currency:    t.cfg.Currency,
+		Ratio:       e.ratio(),
+	}
+}
+
+// Check 返回该密钥已耗尽的第一条预算，未耗尽时返回 nil。
+func (t *BudgetTracker) Check(k *types.ClientKey, now time.Time) *BudgetStatus {
+	entries := t.entriesFor(k, now)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range entries {
+		if e.ratio() >= 1 {
+			s := t.status(e)
+			return &s
+		}
+	}
+	return nil
+}
+
+// Record 累加一次请求的用量，跨过软阈值或耗尽预算时发送 webhook 通知（每个周期每个级别一次）。
+func (t *BudgetTracker) Record(k *types.ClientKey, u usage.Usage, now time.Time) {
+	entries := t.entriesFor(k, now)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range entries {
+		e.used.Tokens += int64(u.TotalTokens())
+		e.used.Cost += u.Cost
+
+		// 一次请求同时跨过软阈值和上限时只发送耗尽通知
+		ratio := e.ratio()
+		switch {
+		case ratio >= 1 && !e.alerts[budgetLevelExhausted]:
+			e.alerts[budgetLevelSoft] = true
+			e.alerts[budgetLevelExhausted] = true
+			t.alert(budgetLevelExhausted, t.status(e))
+		case ratio >= e.rule.SoftThreshold && !e.alerts[budgetLevelSoft]:
+			e.alerts[budgetLevelSoft] = true
+			t.alert(budgetLevelSoft, t.status(e))
+		}
+	}
+}
+
+// alert 记录并发送一条预算通知。
+func (t *BudgetTracker) alert(level string, s BudgetStatus) {
+	message := fmt.Sprintf("%s %s 的%s预算已使用 %.0f%%", scopeLabel(s.Scope), s.Subject, periodLabel(s.Period), s.Ratio*100)
+	if level == budgetLevelExhausted {
+		message = fmt.Sprintf("%s %s 的%s预算已用尽，将于 %s 重置", scopeLabel(s.Scope), s.Subject, periodLabel(s.Period), s.ResetAt.Format("2006-01-02 15:04"))
+	}
+	log.Print(message)
+	metrics.BudgetAlertsTotal.WithLabelValues(s.Scope, level).Inc()
+
+	if db.IsInitialized() {
+		go db.MarkBudgetAlert(s.Scope, s.Subject, s.Period, s.PeriodStart.Format(time.DateOnly), level)
+	}
+	eventType := "budget.soft_limit"
+	if level == budgetLevelExhausted {
+		eventType = "budget.exhausted"
+	}
+	notify.Send(t.cfg.Webhook, notify.Event{Type: eventType, Message: message, Data: s})
+}
+
+// Snapshot 返回当前周期内所有已访问过的预算状态。
+func (t *BudgetTracker) Snapshot() []BudgetStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(time.Now())
+	list := make([]BudgetStatus, 0, len(t.entries))
+	for _, e := range t.entries {
+		if !e.loaded {
+			continue // 仍在加载，用量尚不完整
+		}
+		list = append(list, t.status(e))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Scope != list[j].Scope {
+			return list[i].Scope < list[j].Scope
+		}
+		if list[i].Subject != list[j].Subject {
+			return list[i].Subject < list[j].Subject
+		}
+		return list[i].Period < list[j].Period
+	})
+	return list
+}
+
+func scopeLabel(scope string) string {
+	if scope == budgetScopeTeam {
+		return "团队"
+	}
+	return "客户端密钥"
+}
+
+func periodLabel(period string) string {
+	if period == budgetPeriodMonth {
+		return "每月"
+	}
+	return "每日"
+}
+
+// describeUsage 返回预算用量的简要描述，例如 "tokens 1000/1000, 费用 1.2/1 USD"。
+func describeUsage(s BudgetStatus) string {
+	var parts string
+	if s.TokensLimit > 0 {
+		parts = fmt.Sprintf("tokens %d/%d", s.TokensUsed, s.TokensLimit)
+	}
+	if s.CostLimit > 0 {
+		if parts != "" {
+			parts += ", "
+		}
+		parts += fmt.Sprintf("费用 %.6g/%.6g %s", s.CostUsed, s.CostLimit, s.Currency)
+	}
+	return parts
+}
+
+// BudgetMiddleware 创建按客户端密钥/团队执行预算的中间件，需位于 AuthMiddleware 之后、UsageMiddleware 之前。
+func BudgetMiddleware(proxyCfg config.ProxyConfig, tracker *BudgetTracker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			clientKey := ClientKeyFromContext(c)
+			if clientKey == nil || c.Request().Method == http.MethodOptions {
+				return next(c)
+			}
+
+			if exhausted := tracker.Check(clientKey, time.Now()); exhausted != nil {
+				metrics.RateLimitRejectedTotal.WithLabelValues(proxyCfg.Path, exhausted.Scope+"_budget").Inc()
+				log.Printf("预算已用尽，拒绝请求: %s %s (%s)", clientKey.Name, proxyCfg.Path, describeUsage(*exhausted))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(time.Until(exhausted.ResetAt))))
+				return writeVendorError(c, proxyCfg, http.StatusTooManyRequests, errQuotaExceeded,
+					fmt.Sprintf("%s %s 的%s预算已用尽（%s），将于 %s 重置", scopeLabel(exhausted.Scope), exhausted.Subject,
+						periodLabel(exhausted.Period), describeUsage(*exhausted), exhausted.ResetAt.Format("2006-01-02 15:04")))
+			}
+
+			err := next(c)
+			if u, ok := UsageFromContext(c); ok {
+				tracker.Record(clientKey, u, time.Now())
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/translate"
+
+	"github.com/labstack/echo/v4"
+)
+
+// errorKind 是与厂商无关的错误类别，写出时映射为各厂商自己的错误类型。
+type errorKind int
+
+const (
+	errAuthentication errorKind = iota
+	errPermission
+	errRateLimit
+	errInvalidRequest
+	errQuotaExceeded
+	errUnavailable // 代理自身的依赖（例如数据库）暂时不可用
+)
+
+// openAIErrorTypes / anthropicErrorTypes / googleErrorStatus 分别对应各厂商错误体中的类型字段
+var (
+	openAIErrorTypes = map[errorKind]string{
+		errAuthentication: "invalid_request_error",
+		errPermission:     "permission_error",
+		errRateLimit:      "rate_limit_exceeded",
+		errInvalidRequest: "invalid_request_error",
+		errQuotaExceeded:  "insufficient_quota",
+		errUnavailable:    "server_error",
+	}
+	openAIErrorCodes = map[errorKind]string{
+		errAuthentication: "invalid_api_key",
+		errPermission:     "permission_denied",
+		errRateLimit:      "rate_limit_exceeded",
+		errInvalidRequest: "invalid_request",
+		errQuotaExceeded:  "insufficient_quota",
+		errUnavailable:    "service_unavailable",
+	}
+	anthropicErrorTypes = map[errorKind]string{
+		errAuthentication: "authentication_error",
+		errPermission:     "permission_error",
+		errRateLimit:      "rate_limit_error",
+		errInvalidRequest: "invalid_request_error",
+		errQuotaExceeded:  "rate_limit_error",
+		errUnavailable:    "api_error",
+	}
+	googleErrorStatus = map[errorKind]string{
+		errAuthentication: "UNAUTHENTICATED",
+		errPermission:     "PERMISSION_DENIED",
+		errRateLimit:      "RESOURCE_EXHAUSTED",
+		errInvalidRequest: "INVALID_ARGUMENT",
+		errQuotaExceeded:  "RESOURCE_EXHAUSTED",
+		errUnavailable:    "UNAVAILABLE",
+	}
+)
+
+// writeVendorError 按代理对外暴露的协议写出错误响应，使客户端 SDK 能正确识别错误类型。
+func writeVendorError(c echo.Context, proxyCfg config.ProxyConfig, status int, kind errorKind, message string) error {
+	// 启用协议转换的代理对外是 OpenAI 格式
+	vendor := strings.ToLower(proxyCfg.Vendor)
+	if proxyCfg.Translate == translate.ModeOpenAI {
+		vendor = "openai"
+	}
+
+	switch vendor {
+	case "anthropic":
+		return c.JSON(status, map[string]any{
+			"type": "error",
+			"error": map[string]string{
+				"type":    anthropicErrorTypes[kind],
+				"message": message,
+			},
+		})
+	case "google", "gemini":
+		return c.JSON(status, map[string]any{
+			"error": map[string]any{
+				"code":    status,
+				"message": message,
+				"status":  googleErrorStatus[kind],
+			},
+		})
+	default:
+		return c.JSON(status, map[string]any{
+			"error": map[string]any{
+				"message": message,
+				"type":    openAIErrorTypes[kind],
+				"param":   nil,
+				"code":    openAIErrorCodes[kind],
+			},
+		})
+	}
+}
+
+// statusForKind 返回错误类别默认的 HTTP 状态码。
+func statusForKind(kind errorKind) int {
+	switch kind {
+	case errAuthentication:
+		return http.StatusUnauthorized
+	case errPermission:
+		return http.StatusForbidden
+	case errRateLimit, errQuotaExceeded:
+		return http.StatusTooManyRequests
+	case errUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/pii"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxPIIScanBytes 超过该大小的请求体不做检测，直接按配置的默认处理方式决定是否放行
+const maxPIIScanBytes = 8 << 20
+
+// PIIMiddleware 检测 JSON 聊天请求中的个人信息，按规则替换、拒绝或记录。
+// 日志与指标只记录命中的规则和次数，不记录命中的内容。规则配置有误时拒绝该代理的所有请求，
+// 避免个人信息在策略失效时被发出。
+func PIIMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
+	detector, err := pii.NewDetector(proxyCfg.PII)
+	if err != nil {
+		log.Printf("代理 %s 的 pii 配置无效，该代理的请求将被拒绝: %v", proxyCfg.Path, err)
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return writeVendorError(c, proxyCfg, http.StatusInternalServerError, errInvalidRequest, "PII 策略配置无效")
+			}
+		}
+	}
+	blockUnscannable := strings.EqualFold(proxyCfg.PII.Action, string(pii.ActionBlock))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodPost || req.Body == nil ||
+				!strings.Contains(req.Header.Get("Content-Type"), "json") {
+				return next(c)
+			}
+
+			body, err := io.ReadAll(io.LimitReader(req.Body, maxPIIScanBytes+1))
+			if err != nil {
+				return writeVendorError(c, proxyCfg, http.StatusBadRequest, errInvalidRequest, "读取请求体失败")
+			}
+			if len(body) > maxPIIScanBytes || req.Header.Get("Content-Encoding") != "" {
+				if blockUnscannable {
+					return writeVendorError(c, proxyCfg, http.StatusRequestEntityTooLarge, errInvalidRequest, "请求体无法进行 PII 检测")
+				}
+				req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+				return next(c)
+			}
+
+			res, err := detector.Scan(body)
+			if err != nil {
+				// 非法 JSON 交给上游返回错误
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				return next(c)
+			}
+
+			for _, f := range res.Findings {
+				metrics.PIIFindingsTotal.WithLabelValues(proxyCfg.Path, f.Rule, string(f.Action)).Add(float64(f.Count))
+			}
+			if len(res.Findings) > 0 {
+				log.Printf("检测到个人信息 (service=%s): %s", proxyCfg.Path, describeFindings(res.Findings))
+			}
+			if res.Blocked {
+				return writeVendorError(c, proxyCfg, http.StatusBadRequest, errInvalidRequest,
+					"请求包含不允许发送的个人信息: "+blockedRules(res.Findings))
+			}
+
+			if res.Masked {
+				body = res.Body
+				req.ContentLength = int64(len(body))
+				req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			return next(c)
+		}
+	}
+}
+
+func describeFindings(findings []pii.Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = fmt.Sprintf("%s×%d(%s)", f.Rule, f.Count, f.Action)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func blockedRules(findings []pii.Finding) string {
+	var names []string
+	for _, f := range findings {
+		if f.Action == pii.ActionBlock {
+			names = append(names, f.Rule)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// limiters 是全局共享的限流器注册表，使模型路由入口与代理路由共用同一份配额
+var limiters = ratelimit.NewRegistry()
+
+// RateLimitMiddleware 创建按代理路径与客户端密钥限流的中间件，需位于 AuthMiddleware 之后。
+func RateLimitMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method == http.MethodOptions {
+				return next(c)
+			}
+			now := time.Now()
+			clientKey := ClientKeyFromContext(c)
+
+			// 每分钟请求数：代理与密钥两个维度都要满足。
+			// 只在拒绝时写出 x-ratelimit-* 头，放行的请求保留上游自己的限流头
+			take := func(scope string, bucket *ratelimit.TokenBucket) *ratelimit.Result {
+				res := bucket.Take(now)
+				if res.Allowed {
+					return nil
+				}
+				metrics.RateLimitRejectedTotal.WithLabelValues(proxyCfg.Path, scope).Inc()
+				return &res
+			}
+
+			// 每分钟 token 数：用量只有在响应结束后才知道，因此请求前只检查余量，响应后再扣减
+			checkTokens := func(scope, name string, tpm int) *ratelimit.Result {
+				if tpm <= 0 {
+					return nil
+				}
+				res := limiters.Bucket(name, tpm).Check(now)
+				if res.Allowed {
+					return nil
+				}
+				metrics.RateLimitRejectedTotal.WithLabelValues(proxyCfg.Path, scope).Inc()
+				return &res
+			}
+			tokensRejected := checkTokens("service_tpm", "tpm:svc:"+proxyCfg.Path, proxyCfg.RateLimit.TPM)
+			if tokensRejected == nil && clientKey != nil {
+				tokensRejected = checkTokens("key_tpm", "tpm:key:"+clientKey.Identity(), clientKey.RateLimitTPM)
+			}
+			if tokensRejected != nil {
+				log.Printf("token 用量超限: %s (service=%s)", c.Request().URL.Path, proxyCfg.Path)
+				setRateLimitHeaders(c, *tokensRejected, "tokens")
+				return writeVendorError(c, proxyCfg, http.StatusTooManyRequests, errRateLimit,
+					fmt.Sprintf("每分钟 token 用量超过限制，请在 %d 秒后重试", retryAfterSeconds(tokensRejected.RetryAfter)))
+			}
+
+			// 先扣密钥自身的配额：超限的密钥不能消耗代理共享的配额；
+			// 共享配额不足时再把已取出的密钥令牌归还
+			var keyBucket *ratelimit.TokenBucket
+			var rejected *ratelimit.Result
+			if clientKey != nil && clientKey.RateLimitRPM > 0 {
+				keyBucket = limiters.Bucket("key:"+clientKey.Identity(), clientKey.RateLimitRPM)
+				rejected = take("key_rpm", keyBucket)
+			}
+			if rejected == nil && proxyCfg.RateLimit.RPM > 0 {
+				rejected = take("service_rpm", limiters.Bucket("svc:"+proxyCfg.Path, proxyCfg.RateLimit.RPM))
+				if rejected != nil && keyBucket != nil {
+					keyBucket.Refund(now, 1)
+				}
+			}
+			if rejected != nil {
+				log.Printf("请求频率超限: %s (service=%s)", c.Request().URL.Path, proxyCfg.Path)
+				setRateLimitHeaders(c, *rejected, "requests")
+				return writeVendorError(c, proxyCfg, http.StatusTooManyRequests, errRateLimit,
+					fmt.Sprintf("请求频率超过限制，请在 %d 秒后重试", retryAfterSeconds(rejected.RetryAfter)))
+			}
+
+			// 并发数
+			var acquired []*ratelimit.Semaphore
+			defer func() {
+				for _, s := range acquired {
+					s.Release()
+				}
+			}()
+			acquire := func(scope, name string, limit int) bool {
+				if limit <= 0 {
+					return true
+				}
+				s := limiters.Semaphore(name, limit)
+				if !s.TryAcquire() {
+					metrics.RateLimitRejectedTotal.WithLabelValues(proxyCfg.Path, scope).Inc()
+					return false
+				}
+				acquired = append(acquired, s)
+				return true
+			}
+			if !acquire("service_concurrent", "svc:"+proxyCfg.Path, proxyCfg.RateLimit.Concurrent) ||
+				(clientKey != nil && !acquire("key_concurrent", "key:"+clientKey.Identity(), clientKey.RateLimitConcurrent)) {
+				log.Printf("并发请求数超限: %s (service=%s)", c.Request().URL.Path, proxyCfg.Path)
+				c.Response().Header().Set("Retry-After", "1")
+				return writeVendorError(c, proxyCfg, http.StatusTooManyRequests, errRateLimit, "并发请求数超过限制，请稍后重试")
+			}
+
+			err := next(c)
+
+			// 按上游返回的实际用量扣减 token 配额
+			if u, ok := UsageFromContext(c); ok {
+				tokens := float64(u.TotalTokens())
+				if proxyCfg.RateLimit.TPM > 0 {
+					limiters.Bucket("tpm:svc:"+proxyCfg.Path, proxyCfg.RateLimit.TPM).Consume(time.Now(), tokens)
+				}
+				if clientKey != nil && clientKey.RateLimitTPM > 0 {
+					limiters.Bucket("tpm:key:"+clientKey.Identity(), clientKey.RateLimitTPM).Consume(time.Now(), tokens)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// setRateLimitHeaders 写出与 OpenAI 一致的 x-ratelimit-* 响应头以及 Retry-After，unit 为 requests 或 tokens。
+func setRateLimitHeaders(c echo.Context, res ratelimit.Result, unit string) {
+	h := c.Response().Header()
+	h.Set("x-ratelimit-limit-"+unit, strconv.Itoa(res.Limit))
+	h.Set("x-ratelimit-remaining-"+unit, strconv.Itoa(res.Remaining))
+	h.Set("x-ratelimit-reset-"+unit, formatResetDuration(res.Reset))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(retryAfterSeconds(res.RetryAfter)))
+	}
+}
+
+// retryAfterSeconds 将等待时长向上取整为秒，至少为 1。
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// formatResetDuration 将时长格式化为 OpenAI 使用的形式，例如 1s、6m0s。
+func formatResetDuration(d time.Duration) string {
+	if d < time.Second {
+		return strconv.Itoa(int(d.Milliseconds())) + "ms"
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// serveRateLimited 以指定客户端密钥经过限流中间件发送一次请求，返回响应状态码。
+func serveRateLimited(t *testing.T, mw echo.MiddlewareFunc, key *types.ClientKey) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil), rec)
+	if key != nil {
+		c.Set(ClientKeyContextKey, key)
+	}
+	h := mw(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	if err := h(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+// 超过自身配额的密钥不应消耗代理共享的配额。
+func TestRateLimitKeyRejectionKeepsServiceQuota(t *testing.T) {
+	proxyCfg := config.ProxyConfig{Path: "/test-key-first", Vendor: "openai", RateLimit: config.RateLimitConfig{RPM: 5}}
+	mw := RateLimitMiddleware(proxyCfg)
+	abusive := &types.ClientKey{Name: "abusive-key-first", Source: "config", RateLimitRPM: 1}
+	other := &types.ClientKey{Name: "other-key-first", Source: "config"}
+
+	if rec := serveRateLimited(t, mw, abusive); rec.Code != http.StatusOK {
+		t.Fatalf("首个请求状态码 = %d", rec.Code)
+	}
+	for range 10 {
+		if rec := serveRateLimited(t, mw, abusive); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("超限密钥状态码 = %d, want 429", rec.Code)
+		}
+	}
+	// 代理配额还剩 4 个
+	for i := range 4 {
+		if rec := serveRateLimited(t, mw, other); rec.Code != http.StatusOK {
+			t.Fatalf("其他密钥第 %d 个请求状态码 = %d, want 200", i+1, rec.Code)
+		}
+	}
+	rec := serveRateLimited(t, mw, other)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("代理配额耗尽后状态码 = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("x-ratelimit-limit-requests"); got != "5" {
+		t.Errorf("x-ratelimit-limit-requests = %q, want 5", got)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("缺少 Retry-After")
+	}
+}
+
+// 代理配额不足而被拒绝时，密钥已取出的令牌应当归还。
+func TestRateLimitServiceRejectionRefundsKey(t *testing.T) {
+	proxyCfg := config.ProxyConfig{Path: "/test-refund", Vendor: "openai", RateLimit: config.RateLimitConfig{RPM: 1}}
+	mw := RateLimitMiddleware(proxyCfg)
+	key := &types.ClientKey{Name: "refund-key", Source: "config", RateLimitRPM: 2}
+
+	if rec := serveRateLimited(t, mw, key); rec.Code != http.StatusOK {
+		t.Fatalf("首个请求状态码 = %d", rec.Code)
+	}
+	if rec := serveRateLimited(t, mw, key); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("代理超限状态码 = %d, want 429", rec.Code)
+	}
+	if res := limiters.Bucket("key:"+key.Identity(), key.RateLimitRPM).Check(time.Now()); res.Remaining != 1 {
+		t.Errorf("密钥剩余配额 = %d, want 1", res.Remaining)
+	}
+}
+
+func TestRateLimitConcurrent(t *testing.T) {
+	proxyCfg := config.ProxyConfig{Path: "/test-concurrent", Vendor: "openai", RateLimit: config.RateLimitConfig{Concurrent: 1}}
+	mw := RateLimitMiddleware(proxyCfg)
+
+	e := echo.New()
+	var inner *httptest.ResponseRecorder
+	h := mw(func(c echo.Context) error {
+		// 第一个请求进行中时，第二个请求应被拒绝
+		inner = serveRateLimited(t, mw, nil)
+		return c.NoContent(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	if err := h(e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || inner.Code != http.StatusTooManyRequests {
+		t.Errorf("状态码 = %d / %d, want 200 / 429", rec.Code, inner.Code)
+	}
+	// 请求结束后名额归还
+	if rec := serveRateLimited(t, mw, nil); rec.Code != http.StatusOK {
+		t.Errorf("归还名额后状态码 = %d, want 200", rec.Code)
+	}
+}
+
+func TestFormatResetDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 250 * time.Millisecond, want: "250ms"},
+		{d: 1400 * time.Millisecond, want: "1s"},
+		{d: 6 * time.Minute, want: "6m0s"},
+	}
+	for _, tt := range tests {
+		if got := formatResetDuration(tt.d); got != tt.want {
+			t.Errorf("formatResetDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
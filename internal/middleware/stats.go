@@ -1,15 +1,21 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"go-proxy/internal/db"
 	"go-proxy/pkg/config"
 	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/redact"
 	"go-proxy/pkg/types"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/labstack/echo/v4"
 )
@@ -29,8 +35,8 @@ func InitStatsChannel(bufferSize int) {
 	StatsChannel = make(chan types.RequestStat, bufferSize) // 使用 types.RequestStat
 }
 
-// ProcessStats 异步处理统计的函数。retentionDays 指定数据保留天数。
-func ProcessStats(retentionDays int) {
+// ProcessStats 异步处理统计的函数。retentionDays 指定数据保留天数，hourlyRetentionDays 指定小时汇总保留天数。
+func ProcessStats(retentionDays, hourlyRetentionDays int) {
 	batchStats := make([]types.RequestStat, 0, DefaultBatchSize)
 	batchCounts := make(map[string]int)
 	ticker := time.NewTicker(DefaultBatchTimeout)
@@ -124,19 +130,29 @@ func ProcessStats(retentionDays int) {
 			processBatch()
 
 		case <-batchTicker.C:
-			// 每5分钟：聚合每日数据、清理过期数据（不执行 VACUUM）
+			// 每5分钟：聚合每日与小时数据、清理过期数据（不执行 VACUUM）
 			log.Printf("统计处理状态：当前批次大小=%d, 计数映射大小=%d",
 				len(batchStats), len(batchCounts))
 
-			if err := db.AggregateDaily(1); err != nil {
-				log.Printf("定时聚合每日统计失败: %v", err)
-			} else {
+			dailyErr := db.AggregateDaily(1)
+			if dailyErr != nil {
+				log.Printf("定时聚合每日统计失败: %v", dailyErr)
+			}
+			// 覆盖当前小时与上一个小时，整点前后写入的数据不会遗漏
+			hourlyErr := db.AggregateHourly(2)
+			if hourlyErr != nil {
+				log.Printf("定时聚合小时统计失败: %v", hourlyErr)
+			}
+			if dailyErr == nil || hourlyErr == nil {
 				db.InvalidateCache()
 			}
 
 			if err := db.CleanupOldData(retentionDays, false); err != nil {
 				log.Printf("定时清理历史数据失败: %v", err)
 			}
+			if err := db.CleanupHourlySummary(hourlyRetentionDays); err != nil {
+				log.Printf("定时清理小时汇总失败: %v", err)
+			}
 
 		case <-vacuumTicker.C:
 			// 每24小时：执行带 VACUUM 的清理，压缩数据库文件
@@ -149,7 +165,9 @@ func ProcessStats(retentionDays int) {
 }
 
 // StatsMiddleware 创建一个Echo中间件函数，用于增加特定代理路径配置的请求计数。
+// 同时为每个请求分配请求 ID：沿用客户端传入的 X-Request-Id，否则生成新的，并写入响应头和转发给上游的请求头。
 func StatsMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
+	upstream := upstreamLabel(proxyCfg.Target)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			path := c.Request().URL.Path
@@ -174,6 +192,20 @@ func StatsMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
 			start := time.Now()
 			var err error
 
+			req := c.Request()
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+				req.Header.Set(echo.HeaderXRequestID, requestID)
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+			// 统计客户端实际发送的请求体大小，内层中间件替换请求体不影响计数
+			var reqBody *countingBody
+			if req.Body != nil && req.Body != http.NoBody {
+				reqBody = &countingBody{ReadCloser: req.Body}
+				req.Body = reqBody
+			}
+
 			// 使用 defer 来确保即使发生 panic 也能记录响应时间
 			defer func() {
 				if r := recover(); r != nil {
@@ -218,12 +250,33 @@ func StatsMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
 				if shouldCount {
 					if StatsChannel != nil {
 						stat := types.RequestStat{
-							ServiceName:  proxyCfg.Path,
-							Host:         c.Request().Host,
-							RequestURI:   c.Request().URL.RequestURI(),
-							StatusCode:   statusCode,
-							ResponseTime: responseTime,
+							ServiceName:   proxyCfg.Path,
+							Host:          c.Request().Host,
+							RequestURI:    redact.Text(c.Request().URL.RequestURI()),
+							StatusCode:    statusCode,
+							ResponseTime:  responseTime,
+							Method:        req.Method,
+							ClientIP:      c.RealIP(),
+							UserAgent:     truncate(req.UserAgent(), maxUserAgentLength),
+							RequestBytes:  max(req.ContentLength, 0),
+							ResponseBytes: c.Response().Size,
+							Upstream:      upstream,
+							RequestID:     requestID,
+						}
+						if reqBody != nil {
+							stat.RequestBytes = max(stat.RequestBytes, reqBody.n)
 						}
+						if clientKey := ClientKeyFromContext(c); clientKey != nil {
+							stat.ClientKey = clientKey.Name
+							stat.Team = clientKey.Team
+						}
+						if u, ok := UsageFromContext(c); ok {
+							stat.Model = u.Model
+							stat.PromptTokens = u.PromptTokens
+							stat.CompletionTokens = u.CompletionTokens
+							stat.Cost = u.Cost
+						}
+						stat.Body = BodyLogFromContext(c)
 
 						// 使用非阻塞发送
 						select {
@@ -246,3 +299,61 @@ func StatsMiddleware(proxyCfg config.ProxyConfig) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// maxUserAgentLength 是记录到请求日志中的 User-Agent 的最大长度
+const maxUserAgentLength = 512
+
+// countingBody 统计从请求体中读取的字节数。
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// validRequestID 判断客户端传入的请求 ID 是否可以沿用：长度不超过 128，且只包含字母、数字和 -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '_' || ch == '.' || ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成 32 位十六进制的随机请求 ID。
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// upstreamLabel 返回记录到请求日志中的上游地址，去掉其中可能包含的用户信息与查询参数。
+func upstreamLabel(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return target
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// truncate 将 s 截断到最多 n 个字节，不截断多字节字符。
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
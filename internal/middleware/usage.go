@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/usage"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// UsageContextKey 是响应用量保存在 echo.Context 中的键，值为 usage.Usage
+	UsageContextKey = "usage"
+
+	// 用量字段在非流式响应中可能位于任意位置，在流式响应中位于开头（Anthropic 输入 token）
+	// 或结尾，因此只保留响应的头部与尾部，避免长响应占用过多内存
+	captureHeadLimit = 256 << 10
+	captureTailLimit = 256 << 10
+)
+
+// captureWriter 在透传响应的同时保留响应体的头部与尾部。
+type captureWriter struct {
+	http.ResponseWriter
+	head      []byte
+	tail      []byte
+	truncated bool
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.capture(b[:n])
+	return n, err
+}
+
+func (w *captureWriter) capture(b []byte) {
+	if room := captureHeadLimit - len(w.head); room > 0 {
+		take := min(room, len(b))
+		w.head = append(w.head, b[:take]...)
+		b = b[take:]
+	}
+	if len(b) == 0 {
+		return
+	}
+	w.tail = append(w.tail, b...)
+	if over := len(w.tail) - captureTailLimit; over > 0 {
+		w.tail = append(w.tail[:0], w.tail[over:]...)
+		w.truncated = true
+	}
+}
+
+// body 返回捕获的内容，complete 表示是否为完整响应体。
+func (w *captureWriter) body() (data []byte, complete bool) {
+	if !w.truncated {
+		return append(w.head, w.tail...), true
+	}
+	// 截断时头尾之间插入换行，使 SSE 行边界保持可解析
+	data = append(append([]byte{}, w.head...), '\n')
+	return append(data, w.tail...), false
+}
+
+// Flush 支持流式响应。
+func (w *captureWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter。
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// UsageMiddleware 从上游响应中提取 token 用量并按价格表计算费用，结果保存到 UsageContextKey，
+// 供统计、TPM 限流与预算使用。需位于处理链最内层。
+func UsageMiddleware(proxyCfg config.ProxyConfig, prices *usage.PriceTable) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method != http.MethodPost {
+				return next(c)
+			}
+
+			res := c.Response()
+			cw := &captureWriter{ResponseWriter: res.Writer}
+			res.Writer = cw
+			err := next(c)
+			res.Writer = cw.ResponseWriter
+
+			if res.Status >= http.StatusBadRequest {
+				return err
+			}
+			data, complete := cw.body()
+			if len(data) == 0 {
+				return err
+			}
+			if complete && res.Header().Get("Content-Encoding") == "gzip" {
+				zr, gzErr := gzip.NewReader(bytes.NewReader(data))
+				if gzErr != nil {
+					return err
+				}
+				data, gzErr = io.ReadAll(io.LimitReader(zr, 16<<20))
+				zr.Close()
+				if gzErr != nil {
+					return err
+				}
+			}
+
+			u := usage.Extract(data, res.Header().Get("Content-Type"), complete)
+			if u.IsZero() {
+				return err
+			}
+			u.Cost = prices.Cost(u)
+			c.Set(UsageContextKey, u)
+
+			metrics.TokensTotal.WithLabelValues(proxyCfg.Path, "prompt").Add(float64(u.PromptTokens))
+			metrics.TokensTotal.WithLabelValues(proxyCfg.Path, "completion").Add(float64(u.CompletionTokens))
+			if u.Cost > 0 {
+				metrics.CostTotal.WithLabelValues(proxyCfg.Path).Add(u.Cost)
+			}
+			return err
+		}
+	}
+}
+
+// UsageFromContext 返回 UsageMiddleware 提取的用量，未提取到时返回零值与 false。
+func UsageFromContext(c echo.Context) (usage.Usage, bool) {
+	u, ok := c.Get(UsageContextKey).(usage.Usage)
+	return u, ok
+}
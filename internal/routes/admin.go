@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// createKeyRequest 是创建客户端密钥的请求体。
+type createKeyRequest struct {
+	Name                string    `json:"name"`
+	AllowedPaths        []string  `json:"allowed_paths"`
+	ExpiresAt           time.Time `json:"expires_at"`
+	Team                string    `json:"team"`
+	RateLimitRPM        int       `json:"rate_limit_rpm"`
+	RateLimitTPM        int       `json:"rate_limit_tpm"`
+	RateLimitConcurrent int       `json:"rate_limit_concurrent"`
+}
+
+// registerKeyAdminRoutes 注册客户端密钥管理接口，调用方负责传入带鉴权的分组。
+func registerKeyAdminRoutes(admin *echo.Group, store *middleware.KeyStore) {
+	// 列出所有密钥（配置文件 + SQLite），不返回完整密钥
+	admin.GET("/keys", func(c echo.Context) error {
+		keys := store.Keys()
+		if db.IsInitialized() {
+			dbKeys, err := db.ListClientKeys()
+			if err != nil {
+				c.Logger().Errorf("获取客户端密钥列表时出错: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list client keys"})
+			}
+			keys = append(keys, dbKeys...)
+		}
+		return c.JSON(http.StatusOK, keys)
+	})
+
+	// 生成新密钥，完整密钥只在响应中返回这一次
+	admin.POST("/keys", func(c echo.Context) error {
+		if !db.IsInitialized() {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Database is not available"})
+		}
+		var req createKeyRequest
+		if err := c.Bind(&req); err != nil || req.Name == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+		}
+		key, err := middleware.GenerateClientKey()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate key"})
+		}
+		clientKey := types.ClientKey{
+			Name:         req.Name,
+			KeyPrefix:    middleware.KeyPrefix(key),
+			AllowedPaths: req.AllowedPaths,
+			ExpiresAt:    req.ExpiresAt,
+			Team:         req.Team,
+
+			RateLimitRPM:        req.RateLimitRPM,
+			RateLimitTPM:        req.RateLimitTPM,
+			RateLimitConcurrent: req.RateLimitConcurrent,
+		}
+		id, err := db.CreateClientKey(clientKey, middleware.HashClientKey(key))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create client key"})
+		}
+		clientKey.ID = id
+		clientKey.Source = "db"
+		store.Invalidate()
+		log.Printf("已创建客户端密钥 %s (id=%d)", req.Name, id)
+		return c.JSON(http.StatusCreated, map[string]any{"key": key, "client_key": clientKey})
+	})
+
+	// 部分更新：禁用/启用、允许路径、过期时间、团队与限流
+	admin.PATCH("/keys/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		var update db.ClientKeyUpdate
+		if err := c.Bind(&update); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		}
+		if err := db.UpdateClientKey(id, update); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "client key not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update client key"})
+		}
+		// 缓存按哈希索引，这里只知道 id，直接清空
+		store.Invalidate()
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	admin.DELETE("/keys/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if err := db.DeleteClientKey(id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "client key not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete client key"})
+		}
+		store.Invalidate()
+		log.Printf("已删除客户端密钥 id=%d", id)
+		return c.NoContent(http.StatusNoContent)
+	})
+}
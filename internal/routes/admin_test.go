@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-proxy/internal/db"
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 管理接口修改或删除密钥后，代理鉴权应立即看到变化，而不是等缓存过期。
+func TestKeyAdminInvalidatesCache(t *testing.T) {
+	if err := db.InitDB(config.DatabaseConfig{Driver: db.DriverSQLite, DSN: filepath.Join(t.TempDir(), "stats.db")}, false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseDB)
+
+	store := middleware.NewKeyStore(config.AuthConfig{})
+	e := echo.New()
+	registerKeyAdminRoutes(e.Group("/api/admin"), store)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/admin/keys", `{"name":"ci"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST = %d %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Key       string `json:"key"`
+		ClientKey struct {
+			ID int64 `json:"id"`
+		} `json:"client_key"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if k, err := store.Lookup(created.Key); err != nil || k == nil || k.Disabled {
+		t.Fatalf("Lookup = %+v, %v", k, err)
+	}
+
+	path := fmt.Sprintf("/api/admin/keys/%d", created.ClientKey.ID)
+	if rec := do(http.MethodPatch, path, `{"disabled":true}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("PATCH = %d %s", rec.Code, rec.Body)
+	}
+	if k, err := store.Lookup(created.Key); err != nil || k == nil || !k.Disabled {
+		t.Fatalf("PATCH 后 Lookup = %+v, %v, want disabled", k, err)
+	}
+
+	if rec := do(http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s", rec.Code, rec.Body)
+	}
+	if k, err := store.Lookup(created.Key); err != nil || k != nil {
+		t.Fatalf("DELETE 后 Lookup = %+v, %v, want nil", k, err)
+	}
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerBackupRoutes 注册数据库备份管理接口，调用方负责传入带鉴权的分组。
+func registerBackupRoutes(admin *echo.Group, cfg config.BackupConfig) {
+	// 列出备份目录中的备份，从新到旧
+	admin.GET("/backups", func(c echo.Context) error {
+		backups, err := db.ListBackups(cfg.Dir)
+		if err != nil {
+			c.Logger().Errorf("获取备份列表时出错: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list backups"})
+		}
+		return c.JSON(http.StatusOK, backups)
+	})
+
+	// 立即创建一个备份，服务不中断
+	admin.POST("/backups", func(c echo.Context) error {
+		info, err := db.CreateBackup(c.Request().Context(), cfg.Dir, cfg.Keep)
+		if errors.Is(err, db.ErrBackupUnsupported) {
+			return c.JSON(http.StatusNotImplemented, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create backup"})
+		}
+		return c.JSON(http.StatusCreated, info)
+	})
+
+	// 下载备份文件
+	admin.GET("/backups/:name", func(c echo.Context) error {
+		path, err := db.BackupPath(cfg.Dir, c.Param("name"))
+		if errors.Is(err, os.ErrNotExist) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "backup not found"})
+		}
+		if err != nil {
+			c.Logger().Errorf("查找备份文件时出错: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read backup"})
+		}
+		return c.Attachment(path, c.Param("name"))
+	})
+}
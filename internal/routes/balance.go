@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/balance"
+	"go-proxy/pkg/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerBalanceRoutes 注册余额查询接口，使用管理员 Basic Auth 保护。
+// 余额与账户信息属于管理数据，未配置管理员凭据时不注册。
+func registerBalanceRoutes(e *echo.Echo, cfg *config.Config) {
+	if cfg.Admin.Username == "" || cfg.Admin.Password == "" {
+		log.Println("未配置管理员凭据，余额查询 API (/api/balance) 已禁用。")
+		return
+	}
+	manager := balance.NewManager(cfg.Balance, cfg.Proxies)
+	group := e.Group("/api/balance", middleware.BasicAuth(cfg.Admin.Username, cfg.Admin.Password, "admin"))
+
+	// 查询服务端配置的所有账户，?refresh=true 忽略缓存
+	group.GET("", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, manager.FetchAll(c.Request().Context(), c.QueryParam("refresh") == "true"))
+	})
+
+	group.GET("/providers", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, balance.Providers())
+	})
+
+	// 余额历史与消耗预测，供图表使用，?days= 指定范围（默认 7，最多 90）
+	if db.IsInitialized() {
+		group.GET("/history/:name", func(c echo.Context) error {
+			days, err := strconv.Atoi(c.QueryParam("days"))
+			if err != nil || days <= 0 {
+				days = 7
+			}
+			days = min(days, 90)
+
+			name := c.Param("name")
+			points, err := db.GetBalanceHistory(name, time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				c.Logger().Errorf("获取余额历史时出错: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve balance history"})
+			}
+			recent, err := db.GetBalanceHistory(name, time.Now().Add(-balance.ForecastWindow))
+			if err != nil {
+				recent = nil
+			}
+			return c.JSON(http.StatusOK, map[string]any{
+				"account":  name,
+				"points":   points,
+				"forecast": balance.Estimate(recent),
+			})
+		})
+	}
+
+	group.GET("/:name", func(c echo.Context) error {
+		b, ok := manager.Fetch(c.Request().Context(), c.Param("name"), c.QueryParam("refresh") == "true")
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "balance account not found"})
+		}
+		return c.JSON(http.StatusOK, b)
+	})
+
+	log.Printf("余额查询 API (/api/balance) 已启用（服务端账户 %d 个）。", manager.Len())
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"log"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// clientIPExtractor 根据可信代理列表决定 c.RealIP() 的取值方式。
+// 未配置可信代理时只使用连接的来源地址，客户端无法通过 X-Forwarded-For 伪造 IP；
+// 配置后仅当请求来自可信代理时才采信 X-Forwarded-For，并取其中最后一个不可信的地址。
+func clientIPExtractor(trusted []string) echo.IPExtractor {
+	if len(trusted) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// 关闭 Echo 默认信任的回环、链路本地与私有网段，只信任显式配置的地址
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, entry := range trusted {
+		ipNet, err := parseTrustedProxy(entry)
+		if err != nil {
+			log.Printf("可信代理 %q 无效，已忽略: %v", entry, err)
+			continue
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+// parseTrustedProxy 解析 CIDR 或单个 IP 地址，单个地址视为 /32 或 /128。
+func parseTrustedProxy(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		return ipNet, err
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: entry}
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPExtractor(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{name: "direct ignores forwarded header", remoteAddr: "203.0.113.7:4000", xff: "1.2.3.4", want: "203.0.113.7"},
+		{name: "untrusted peer", trusted: []string{"10.0.0.0/8"}, remoteAddr: "203.0.113.7:4000", xff: "1.2.3.4", want: "203.0.113.7"},
+		{name: "private peer not trusted by default", trusted: []string{"10.0.0.0/8"}, remoteAddr: "192.168.1.5:4000", xff: "1.2.3.4", want: "192.168.1.5"},
+		{name: "trusted cidr", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:4000", xff: "198.51.100.9", want: "198.51.100.9"},
+		// 客户端自带的 X-Forwarded-For 位于左侧，取最右侧的不可信地址
+		{name: "spoofed prefix", trusted: []string{"10.0.0.1"}, remoteAddr: "10.0.0.1:4000", xff: "1.2.3.4, 198.51.100.9", want: "198.51.100.9"},
+		{name: "chained proxies", trusted: []string{"10.0.0.1", "10.0.0.2"}, remoteAddr: "10.0.0.1:4000", xff: "198.51.100.9, 10.0.0.2", want: "198.51.100.9"},
+		{name: "invalid entry ignored", trusted: []string{"not-an-ip"}, remoteAddr: "10.0.0.1:4000", xff: "1.2.3.4", want: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			if got := clientIPExtractor(tt.trusted)(req); got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
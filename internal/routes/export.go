@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/export"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerExportRoutes 注册数据导出接口。配置了管理员凭据时使用 Basic Auth 保护。
+func registerExportRoutes(e *echo.Echo, cfg *config.Config) {
+	var mws []echo.MiddlewareFunc
+	if cfg.Admin.Username != "" && cfg.Admin.Password != "" {
+		mws = append(mws, middleware.BasicAuth(cfg.Admin.Username, cfg.Admin.Password, "admin"))
+	}
+
+	// 流式导出数据集，参数 format（csv、ndjson、parquet，默认 csv）以及 from、to、service（同统计接口）
+	e.GET("/api/export/:dataset", func(c echo.Context) error {
+		dataset := c.Param("dataset")
+		cols, err := db.ExportColumns(dataset)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown dataset %q", dataset)})
+		}
+		format := c.QueryParam("format")
+		if format == "" {
+			format = export.FormatCSV
+		}
+		if format != export.FormatCSV && format != export.FormatNDJSON && format != export.FormatParquet {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be one of csv, ndjson, parquet"})
+		}
+		sq, err := parseStatsQuery(c, time.Now(), db.GranularityDay)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		q := db.ExportQuery{Dataset: dataset, From: sq.From, To: sq.To, Service: sq.Service}
+
+		resp := c.Response()
+		resp.Header().Set(echo.HeaderContentType, export.ContentType(format))
+		resp.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", exportFileName(q, format)))
+		resp.WriteHeader(http.StatusOK)
+
+		// 响应头已发出，之后的错误只能记录日志并中断输出
+		w, err := export.NewWriter(format, resp, cols)
+		if err != nil {
+			c.Logger().Errorf("创建导出写出器时出错: %v", err)
+			return nil
+		}
+		n, err := db.ExportRows(c.Request().Context(), q, w.Write)
+		if err != nil {
+			c.Logger().Errorf("导出 %s 时出错（已写出 %d 行）: %v", dataset, n, err)
+			return nil
+		}
+		if err := w.Close(); err != nil {
+			c.Logger().Errorf("导出 %s 时出错: %v", dataset, err)
+		}
+		return nil
+	}, mws...)
+}
+
+// exportFileName 返回导出文件名，例如 requests_2025-01-01_2025-01-31.csv（终点为包含的最后一天）。
+func exportFileName(q db.ExportQuery, format string) string {
+	last := q.To.Add(-time.Nanosecond)
+	return fmt.Sprintf("%s_%s_%s.%s", q.Dataset, q.From.Format(time.DateOnly), last.Format(time.DateOnly), format)
+}
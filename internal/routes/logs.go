@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultLogPageSize = 50
+	maxLogPageSize     = 500
+)
+
+// registerLogRoutes 注册请求日志查询接口，使用管理员 Basic Auth 保护。
+// 日志包含客户端 IP、User-Agent 与请求路径，未配置管理员凭据时不注册。
+func registerLogRoutes(e *echo.Echo, cfg *config.Config) {
+	if cfg.Admin.Username == "" || cfg.Admin.Password == "" {
+		log.Println("未配置管理员凭据，请求日志 API (/api/logs) 已禁用。")
+		return
+	}
+
+	e.GET("/api/logs", func(c echo.Context) error {
+		q, err := parseLogQuery(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		page, err := db.SearchRequestLogs(q)
+		if errors.Is(err, db.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			c.Logger().Errorf("查询请求日志时出错: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve request logs"})
+		}
+		return c.JSON(http.StatusOK, page)
+	}, middleware.BasicAuth(cfg.Admin.Username, cfg.Admin.Password, "admin"))
+}
+
+// parseLogQuery 解析请求日志的查询参数：
+// service、host 精确匹配；status_min、status_max 为状态码范围；from、to 格式同统计接口；
+// min_latency 为最小响应时间（毫秒）；uri 为路径子串；client_ip、request_id 精确匹配；sort 为 id、response_time 或 status_code，
+// order 为 asc 或 desc（默认）；cursor 为上一页返回的 next_cursor；limit 默认 50，最多 500。
+func parseLogQuery(c echo.Context) (db.LogQuery, error) {
+	q := db.LogQuery{
+		Service:     c.QueryParam("service"),
+		Host:        c.QueryParam("host"),
+		URIContains: c.QueryParam("uri"),
+		ClientIP:    c.QueryParam("client_ip"),
+		RequestID:   c.QueryParam("request_id"),
+		Sort:        c.QueryParam("sort"),
+		Cursor:      c.QueryParam("cursor"),
+		Limit:       defaultLogPageSize,
+	}
+
+	intParam := func(name string, dst *int) error {
+		v := c.QueryParam(name)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s", name)
+		}
+		*dst = n
+		return nil
+	}
+	if err := intParam("status_min", &q.StatusMin); err != nil {
+		return q, err
+	}
+	if err := intParam("status_max", &q.StatusMax); err != nil {
+		return q, err
+	}
+	if q.StatusMin > 0 && q.StatusMax > 0 && q.StatusMin > q.StatusMax {
+		return q, fmt.Errorf("status_min must not be greater than status_max")
+	}
+	var minLatency int
+	if err := intParam("min_latency", &minLatency); err != nil {
+		return q, err
+	}
+	q.MinLatency = int64(minLatency)
+	if err := intParam("limit", &q.Limit); err != nil {
+		return q, err
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultLogPageSize
+	}
+	q.Limit = min(q.Limit, maxLogPageSize)
+
+	switch q.Sort {
+	case "", db.LogSortID, db.LogSortResponseTime, db.LogSortStatusCode:
+	default:
+		return q, fmt.Errorf("sort must be one of id, response_time, status_code")
+	}
+	switch c.QueryParam("order") {
+	case "", "desc":
+	case "asc":
+		q.Asc = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	loc := time.Now().Location()
+	if v := c.QueryParam("from"); v != "" {
+		t, _, err := parseStatsTime(v, loc)
+		if err != nil {
+			return q, fmt.Errorf("invalid from: %v", err)
+		}
+		q.From = t
+	}
+	if v := c.QueryParam("to"); v != "" {
+		t, dateOnly, err := parseStatsTime(v, loc)
+		if err != nil {
+			return q, fmt.Errorf("invalid to: %v", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.To = t
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, fmt.Errorf("from must be earlier than to")
+	}
+	return q, nil
+}
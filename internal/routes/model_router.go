@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/proxy"
+	"go-proxy/pkg/translate"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxRoutedBodySize 限制统一入口读取的请求体大小
+const maxRoutedBodySize = 32 << 20
+
+// registerModelRouter 注册统一入口：解析 POST JSON 请求体中的 model 字段并转交给对应代理的处理链，
+// 其他请求（例如 GET /v1/models、multipart 上传）转交给默认代理。
+// 启用客户端鉴权时（store 非 nil）先校验客户端密钥，再读取请求体并解析路由，
+// 避免未授权的客户端探测路由配置或让服务端缓冲大请求体。
+func registerModelRouter(e *echo.Echo, appCfg *config.Config, proxies []config.ProxyConfig, handlers map[string]echo.HandlerFunc, store *middleware.KeyStore) {
+	cfg, admin := appCfg.Router, appCfg.Admin
+	prefix := strings.TrimSuffix(cfg.Path, "/")
+	if prefix == "" {
+		prefix = "/v1"
+	}
+	for _, p := range proxies {
+		if p.Path == prefix {
+			log.Printf("模型路由入口 %s 与已有代理路径冲突，跳过注册", prefix)
+			return
+		}
+	}
+
+	var mws []echo.MiddlewareFunc
+	if store != nil {
+		mws = append(mws, middleware.RequireClientKey(store))
+	}
+
+	router := proxy.NewModelRouter(cfg, proxies, appCfg.Models.Enabled && appCfg.Models.Prefix)
+	e.Any(prefix+"/*", func(c echo.Context) error {
+		req := c.Request()
+		if !isJSONPost(req) {
+			return forwardToDefault(c, cfg.Default, handlers)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxRoutedBodySize+1))
+		req.Body.Close()
+		if err != nil {
+			return c.JSONBlob(http.StatusBadRequest, translate.NewError("读取请求体失败: "+err.Error(), "invalid_request_error"))
+		}
+		if len(body) > maxRoutedBodySize {
+			return c.JSONBlob(http.StatusRequestEntityTooLarge, translate.NewError("请求体过大", "invalid_request_error"))
+		}
+
+		// 使用 RawMessage 保留其余字段原样
+		var payload map[string]json.RawMessage
+		var model string
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return c.JSONBlob(http.StatusBadRequest, translate.NewError("无法解析请求体: "+err.Error(), "invalid_request_error"))
+			}
+			_ = json.Unmarshal(payload["model"], &model)
+		}
+		if model == "" {
+			return c.JSONBlob(http.StatusBadRequest, translate.NewError("请求体缺少 model 字段，无法路由", "invalid_request_error"))
+		}
+
+		decision, ok := router.Resolve(model)
+		if !ok {
+			metrics.ModelRouteTotal.WithLabelValues("unmatched", "").Inc()
+			log.Printf("模型路由: %s 未匹配任何规则", model)
+			return c.JSONBlob(http.StatusNotFound, translate.NewError("没有可处理模型 "+model+" 的上游", "model_not_found"))
+		}
+		handler, ok := handlers[decision.Proxy]
+		if !ok {
+			return c.JSONBlob(http.StatusBadGateway, translate.NewError("目标代理 "+decision.Proxy+" 未注册", "upstream_error"))
+		}
+
+		if decision.Model != decision.RequestedModel {
+			payload["model"], _ = json.Marshal(decision.Model)
+			if body, err = json.Marshal(payload); err != nil {
+				return c.JSONBlob(http.StatusInternalServerError, translate.NewError("重写请求体失败: "+err.Error(), "internal_error"))
+			}
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+		// 保留 /v1 之后的完整路径，交给目标代理按自身前缀转发
+		req.URL.Path = decision.Proxy + req.URL.Path
+		req.URL.RawPath = ""
+
+		router.Record(decision)
+		metrics.ModelRouteTotal.WithLabelValues(decision.Proxy, decision.Model).Inc()
+		log.Printf("模型路由: %s -> %s (model=%s, rule=%s)", decision.RequestedModel, decision.Proxy, decision.Model, decision.Rule)
+
+		return handler(c)
+	}, mws...)
+
+	// 路由统计属于管理数据，与其他管理接口一样使用 Basic Auth 保护，未配置管理员凭据时不注册
+	if admin.Username != "" && admin.Password != "" {
+		e.GET("/api/stats/routes", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, router.Stats())
+		}, middleware.BasicAuth(admin.Username, admin.Password, "admin"))
+	}
+
+	log.Printf("模型路由统一入口已启用: %s/*", prefix)
+}
+
+// isJSONPost 判断请求是否为携带 JSON 请求体的 POST，只有这类请求按 model 字段路由。
+func isJSONPost(req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// forwardToDefault 把无法按模型路由的请求原样交给默认代理，未配置默认代理时返回 404。
+func forwardToDefault(c echo.Context, target string, handlers map[string]echo.HandlerFunc) error {
+	req := c.Request()
+	if target == "" {
+		return c.JSONBlob(http.StatusNotFound, translate.NewError("未配置默认代理，无法路由 "+req.Method+" "+req.URL.Path, "invalid_request_error"))
+	}
+	handler, ok := handlers[target]
+	if !ok {
+		return c.JSONBlob(http.StatusBadGateway, translate.NewError("目标代理 "+target+" 未注册", "upstream_error"))
+	}
+	req.URL.Path = target + req.URL.Path
+	req.URL.RawPath = ""
+	metrics.ModelRouteTotal.WithLabelValues(target, "").Inc()
+	return handler(c)
+}
+
+// chainMiddleware 按注册顺序包装处理器，第一个中间件位于最外层。
+func chainMiddleware(h echo.HandlerFunc, mws ...echo.MiddlewareFunc) echo.HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
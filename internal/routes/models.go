@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"go-proxy/internal/middleware"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/proxy"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerModelsEndpoint 注册聚合模型列表端点，部分上游失败时仍返回其余上游的结果。
+// 启用客户端鉴权时（store 非 nil）要求携带有效的客户端密钥。
+func registerModelsEndpoint(e *echo.Echo, cfg config.ModelsConfig, proxies []config.ProxyConfig, store *middleware.KeyStore) {
+	path := cfg.Path
+	if path == "" {
+		path = "/v1/models"
+	}
+
+	var mws []echo.MiddlewareFunc
+	if store != nil {
+		mws = append(mws, middleware.RequireClientKey(store))
+	}
+
+	aggregator := proxy.NewModelAggregator(cfg, proxies)
+	e.GET(path, func(c echo.Context) error {
+		list := aggregator.List(c.Request().Context(), c.Request().Header.Get("Authorization"))
+		if len(list.Errors) > 0 {
+			c.Response().Header().Set("X-Models-Partial", "true")
+		}
+		return c.JSON(http.StatusOK, list)
+	}, mws...)
+	log.Printf("聚合模型列表端点已启用: %s", path)
+}
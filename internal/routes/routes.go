@@ -5,18 +5,27 @@ import (
 	"go-proxy/internal/middleware"
 	"go-proxy/pkg/config"
 	"go-proxy/pkg/proxy"
+	"go-proxy/pkg/usage"
 	"io/fs"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterRoutes(e *echo.Echo, proxies []config.ProxyConfig, staticFS fs.FS) {
+func RegisterRoutes(e *echo.Echo, cfg *config.Config, staticFS fs.FS) {
+	proxies := cfg.Proxies
+
+	// 请求日志中的客户端 IP 取自 c.RealIP()，只有可信代理转发的 X-Forwarded-For 才被采信
+	e.IPExtractor = clientIPExtractor(cfg.Server.TrustedProxies)
+
 	// 检查数据库和统计功能是否应该被启用
-	// db.IsInitialized() 检查数据库是否已成功初始化
+	// db.StatsEnabled() 检查数据库是否已成功初始化或处于内存模式
 	// middleware.StatsChannel != nil 检查统计通道是否已初始化
-	enableStatsFeatures := db.IsInitialized() && middleware.StatsChannel != nil
+	// 请求体记录、导出与备份需要数据库，另由 db.IsInitialized() 判断
+	enableStatsFeatures := db.StatsEnabled() && middleware.StatsChannel != nil
 
 	if enableStatsFeatures {
 		// 更新代理配置到数据库
@@ -27,21 +36,122 @@ func RegisterRoutes(e *echo.Echo, proxies []config.ProxyConfig, staticFS fs.FS)
 		log.Println("数据库或统计通道未初始化，跳过数据库中的代理配置更新。")
 	}
 
+	// 客户端鉴权
+	var keyStore *middleware.KeyStore
+	if cfg.Auth.Enabled {
+		keyStore = middleware.NewKeyStore(cfg.Auth)
+		log.Printf("代理路由客户端鉴权已启用（配置文件密钥 %d 个）。", len(cfg.Auth.Keys))
+	}
+
+	// 用量与预算
+	prices := usage.NewPriceTable(cfg.Pricing)
+	var budgets *middleware.BudgetTracker
+	if cfg.Budgets.Enabled {
+		if keyStore == nil {
+			log.Println("警告: 预算按客户端密钥统计，未启用客户端鉴权时预算不会生效。")
+		}
+		if !db.IsInitialized() {
+			log.Println("警告: 数据库未初始化，预算用量不会持久化，重启后将重新计算。")
+		}
+		budgets = middleware.NewBudgetTracker(cfg.Budgets)
+		log.Printf("预算控制已启用（规则 %d 条）。", len(cfg.Budgets.Rules))
+	}
+
 	// 注册代理路由
+	// handlers 保存每个代理完整的处理链（含中间件），供模型路由统一入口复用
+	handlers := make(map[string]echo.HandlerFunc, len(proxies))
 	for _, p := range proxies {
 		// 需要捕获循环变量 p 以供闭包使用
 		proxyCfg := p
 		reverseProxy := proxy.NewReverseProxy(proxyCfg)
 		group := e.Group(proxyCfg.Path)
 
+		var mws []echo.MiddlewareFunc
 		if enableStatsFeatures {
 			// 为这个特定的代理配置应用统计中间件
-			group.Use(middleware.StatsMiddleware(proxyCfg))
+			mws = append(mws, middleware.StatsMiddleware(proxyCfg))
+		}
+		if keyStore != nil {
+			mws = append(mws, middleware.AuthMiddleware(proxyCfg, keyStore))
+			if cfg.Auth.Header == "" && proxyCfg.APIKey == "" {
+				log.Printf("警告: 代理 %s 的客户端密钥通过 Authorization/x-api-key 传递且未配置 api_key，上游将收不到凭据", proxyCfg.Path)
+			}
+		}
+		// 限流在鉴权之后执行，以便按客户端密钥计数
+		mws = append(mws, middleware.RateLimitMiddleware(proxyCfg))
+		if budgets != nil {
+			mws = append(mws, middleware.BudgetMiddleware(proxyCfg, budgets))
+		}
+		if proxyCfg.PII.Enabled {
+			// 位于请求体记录之前，被替换的内容不会写入数据库，被拒绝的请求不会被记录
+			mws = append(mws, middleware.PIIMiddleware(proxyCfg))
 		}
+		if proxyCfg.BodyLog.Enabled {
+			if enableStatsFeatures && db.IsInitialized() {
+				mws = append(mws, middleware.BodyLogMiddleware(proxyCfg))
+			} else {
+				log.Printf("警告: 数据库或统计通道未初始化，代理 %s 的请求体记录不会生效", proxyCfg.Path)
+			}
+		}
+		// 用量提取位于最内层，供外层的统计、TPM 限流与预算读取
+		mws = append(mws, middleware.UsageMiddleware(proxyCfg, prices))
+		group.Use(mws...)
 		group.Any("/*", reverseProxy.Handler)
+		handlers[proxyCfg.Path] = chainMiddleware(reverseProxy.Handler, mws...)
+	}
+
+	// 注册模型路由统一入口
+	if cfg.Router.Enabled {
+		registerModelRouter(e, cfg, proxies, handlers, keyStore)
+	}
+
+	// 注册聚合模型列表端点（静态路径优先于统一入口的通配路由）
+	if cfg.Models.Enabled {
+		registerModelsEndpoint(e, cfg.Models, proxies, keyStore)
+	}
+
+	// 管理接口（Basic Auth 保护）
+	if cfg.Admin.Username != "" && cfg.Admin.Password != "" {
+		admin := e.Group("/api/admin", middleware.BasicAuth(cfg.Admin.Username, cfg.Admin.Password, "admin"))
+		if keyStore != nil {
+			registerKeyAdminRoutes(admin, keyStore)
+		}
+		if budgets != nil {
+			admin.GET("/budgets", func(c echo.Context) error {
+				return c.JSON(http.StatusOK, budgets.Snapshot())
+			})
+		}
+		if enableStatsFeatures && db.IsInitialized() {
+			// 查看采样记录的请求/响应体，id 为 request_logs.id
+			admin.GET("/requests/:id/body", func(c echo.Context) error {
+				id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+				if err != nil {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request id"})
+				}
+				body, err := db.GetRequestBody(id)
+				if err != nil {
+					c.Logger().Errorf("获取请求体时出错: %v", err)
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve request body"})
+				}
+				if body == nil {
+					return c.JSON(http.StatusNotFound, map[string]string{"error": "request body not found"})
+				}
+				return c.JSON(http.StatusOK, body)
+			})
+			registerBackupRoutes(admin, cfg.Backup)
+		}
+		log.Println("管理接口 (/api/admin) 已启用。")
 	}
 
+	// 余额查询
+	registerBalanceRoutes(e, cfg)
+
 	if enableStatsFeatures {
+		// 统计数据的存储方式，内存模式下 Web 界面据此提示数据不会持久化
+		e.GET("/api/stats/info", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, db.GetStorageInfo())
+		})
+
 		// 修改获取统计信息的路由
 		e.GET("/api/stats", func(c echo.Context) error {
 			stats, err := db.GetStatsWithCache()
@@ -52,9 +162,13 @@ func RegisterRoutes(e *echo.Echo, proxies []config.ProxyConfig, staticFS fs.FS)
 			return c.JSON(http.StatusOK, stats)
 		})
 
-		// 最近7天每日调用次数
+		// 按时间段汇总的调用次数，支持 from、to、granularity、service 参数，默认最近7天按天汇总
 		e.GET("/api/stats/daily", func(c echo.Context) error {
-			daily, err := db.GetDailyStatsWithCache()
+			q, err := parseStatsQuery(c, time.Now(), db.GranularityDay)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			daily, err := db.GetTimeSeriesWithCache(q)
 			if err != nil {
 				c.Logger().Errorf("获取每日统计信息时出错: %v", err)
 				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve daily statistics"})
@@ -62,16 +176,61 @@ func RegisterRoutes(e *echo.Echo, proxies []config.ProxyConfig, staticFS fs.FS)
 			return c.JSON(http.StatusOK, daily)
 		})
 
-		// 最近7天服务调用分布
+		// 时间范围内的服务调用分布，参数同上（granularity 不影响结果）
 		e.GET("/api/stats/distribution", func(c echo.Context) error {
-			dist, err := db.GetDistributionWithCache()
+			q, err := parseStatsQuery(c, time.Now(), db.GranularityDay)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			dist, err := db.GetDistributionWithCache(q)
 			if err != nil {
 				c.Logger().Errorf("获取服务分布统计信息时出错: %v", err)
 				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve service distribution"})
 			}
 			return c.JSON(http.StatusOK, dist)
 		})
-		log.Println("统计 API (/api/stats) 和中间件已启用。")
+
+		// 响应时间分位数，参数同上，service 为空时统计全部服务
+		e.GET("/api/stats/latency", func(c echo.Context) error {
+			q, err := parseStatsQuery(c, time.Now(), db.GranularityDay)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			report, err := db.GetLatencyWithCache(q)
+			if err != nil {
+				c.Logger().Errorf("获取响应时间分位数时出错: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve latency percentiles"})
+			}
+			return c.JSON(http.StatusOK, report)
+		})
+
+		// 每小时的流量、错误率与响应时间，默认最近24小时，参数同上（只支持小时粒度）
+		e.GET("/api/stats/hourly", func(c echo.Context) error {
+			q, err := parseStatsQuery(c, time.Now(), db.GranularityHour)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			if q.Granularity != db.GranularityHour {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "granularity must be hour"})
+			}
+			hourly, err := db.GetHourlyStatsWithCache(q)
+			if err != nil {
+				c.Logger().Errorf("获取小时统计信息时出错: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve hourly statistics"})
+			}
+			return c.JSON(http.StatusOK, hourly)
+		})
+
+		// 请求日志查询
+		registerLogRoutes(e, cfg)
+
+		// 数据导出（内存模式不提供）
+		if db.IsInitialized() {
+			registerExportRoutes(e, cfg)
+			log.Println("统计 API (/api/stats) 和中间件已启用。")
+		} else {
+			log.Println("统计 API (/api/stats) 和中间件已启用（内存模式，数据不会持久化）。")
+		}
 	} else {
 		log.Println("统计 API (/api/stats) 和中间件已禁用。")
 	}
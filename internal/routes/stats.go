@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"fmt"
+	"time"
+
+	"go-proxy/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultStatsDays 未指定 from 时的默认天数（含今天）
+	defaultStatsDays = 7
+	// maxHourlyStatsRange 小时粒度的最大查询范围，超出 server.hourly_retention_days 的部分没有数据
+	maxHourlyStatsRange = 31 * 24 * time.Hour
+	// maxStatsRange 其他粒度的最大查询范围
+	maxStatsRange = 3 * 366 * 24 * time.Hour
+)
+
+// parseStatsQuery 解析并校验统计查询参数：
+// from、to 为 YYYY-MM-DD（本地日期，均包含）或 RFC3339 时间；granularity 为 hour/day/week/month；
+// service 为代理路径。
+// 未指定 granularity 时使用 defaultGranularity。
+func parseStatsQuery(c echo.Context, now time.Time, defaultGranularity string) (db.StatsQuery, error) {
+	q := db.StatsQuery{
+		Granularity: c.QueryParam("granularity"),
+		Service:     c.QueryParam("service"),
+	}
+	switch q.Granularity {
+	case "":
+		q.Granularity = defaultGranularity
+	case db.GranularityHour, db.GranularityDay, db.GranularityWeek, db.GranularityMonth:
+	default:
+		return q, fmt.Errorf("granularity must be one of hour, day, week, month")
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	q.To = today.AddDate(0, 0, 1)
+	if q.Granularity == db.GranularityHour {
+		// 小时粒度默认截至当前小时
+		q.To = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).Add(time.Hour)
+	}
+	if v := c.QueryParam("to"); v != "" {
+		t, dateOnly, err := parseStatsTime(v, now.Location())
+		if err != nil {
+			return q, fmt.Errorf("invalid to: %v", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.To = t
+	}
+
+	q.From = today.AddDate(0, 0, 1-defaultStatsDays)
+	if q.Granularity == db.GranularityHour {
+		q.From = q.To.Add(-24 * time.Hour)
+	}
+	if v := c.QueryParam("from"); v != "" {
+		t, _, err := parseStatsTime(v, now.Location())
+		if err != nil {
+			return q, fmt.Errorf("invalid from: %v", err)
+		}
+		q.From = t
+	}
+
+	if !q.From.Before(q.To) {
+		return q, fmt.Errorf("from must be earlier than to")
+	}
+	limit := maxStatsRange
+	if q.Granularity == db.GranularityHour {
+		limit = maxHourlyStatsRange
+	}
+	if q.To.Sub(q.From) > limit {
+		return q, fmt.Errorf("time range too large for granularity %s (max %d days)", q.Granularity, int(limit.Hours()/24))
+	}
+	return q, nil
+}
+
+// parseStatsTime 解析日期或 RFC3339 时间，dateOnly 表示输入只有日期。
+func parseStatsTime(v string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.ParseInLocation(time.DateOnly, v, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err = time.Parse(time.RFC3339, v); err == nil {
+		return t.In(loc), false, nil
+	}
+	return t, false, fmt.Errorf("expected YYYY-MM-DD or RFC3339")
+}
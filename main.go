@@ -21,6 +21,43 @@ const defaultPort = "8080" // Define a default port - 移至 bootstrap 或由 bo
 // const statsChannelBufferSize = 1000 // 定义统计通道的缓冲区大小 - 移至 bootstrap
 
 func main() {
+	// go-proxy scrub-logs：按脱敏配置清理已保存的请求日志后退出
+	if len(os.Args) > 1 && os.Args[1] == "scrub-logs" {
+		if err := bootstrap.ScrubLogs(); err != nil {
+			log.Fatalf("清理请求日志失败: %v", err)
+		}
+		return
+	}
+	// go-proxy export：导出统计与日志数据后退出
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := bootstrap.Export(os.Args[2:]); err != nil {
+			log.Fatalf("导出数据失败: %v", err)
+		}
+		return
+	}
+
+	// go-proxy migrate：查看或执行数据库结构迁移后退出
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := bootstrap.Migrate(os.Args[2:]); err != nil {
+			log.Fatalf("数据库迁移失败: %v", err)
+		}
+		return
+	}
+
+	// go-proxy backup / restore：备份或恢复 SQLite 数据库后退出
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		if err := bootstrap.Backup(os.Args[2:]); err != nil {
+			log.Fatalf("备份数据库失败: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := bootstrap.Restore(os.Args[2:]); err != nil {
+			log.Fatalf("恢复数据库失败: %v", err)
+		}
+		return
+	}
+
 	var wg sync.WaitGroup
 	// 调用 bootstrap.SetupApp 进行应用设置
 	// false 表示非 Vercel 环境
@@ -38,10 +75,24 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		middleware.ProcessStats(cfg.Server.RetentionDays)
+		middleware.ProcessStats(cfg.Server.RetentionDays, cfg.Server.HourlyRetentionDays)
 		log.Println("ProcessStats goroutine 已退出。")
 	}()
 
+	// 余额轮询（未配置 balance.poll_interval 时立即返回）
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bootstrap.ProcessBalances(cfg)
+	}()
+
+	// 定时备份（未配置 backup.interval 时立即返回）
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bootstrap.ProcessBackups(cfg)
+	}()
+
 	// Determine the server port from config returned by SetupApp
 	serverPort := cfg.Server.Port
 	if serverPort == "" {
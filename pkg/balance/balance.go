@@ -0,0 +1,110 @@
+package balance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 15 * time.Second
+
+// Balance 是一次余额查询的结果，金额单位为 Currency。
+type Balance struct {
+	Name      string         `json:"name"`
+	Provider  string         `json:"provider"`
+	Currency  string         `json:"currency"`
+	Balance   float64        `json:"balance"`           // 剩余可用额度
+	Total     float64        `json:"total,omitempty"`   // 总额度，厂商未提供时为 0
+	Used      float64        `json:"used,omitempty"`    // 已用额度，厂商未提供时为 0
+	Details   map[string]any `json:"details,omitempty"` // 厂商返回的其他信息，例如用户名、赠送余额
+	Error     string         `json:"error,omitempty"`
+	FetchedAt time.Time      `json:"fetched_at"`
+}
+
+// BalanceProvider 查询某个厂商账户的余额。
+type BalanceProvider interface {
+	// Name 返回厂商标识，例如 siliconflow。
+	Name() string
+	// Fetch 使用 apiKey 查询余额，返回的 Balance 不含 Name 与 FetchedAt。
+	Fetch(ctx context.Context, apiKey string) (*Balance, error)
+}
+
+// factory 根据 baseURL 创建厂商实现，baseURL 为空时使用厂商默认地址。
+type factory func(baseURL string, client *http.Client) BalanceProvider
+
+var providers = map[string]factory{}
+
+// register 注册一个厂商实现，在各实现文件的 init 中调用。
+func register(name string, f factory) {
+	providers[name] = f
+}
+
+// NewProvider 按厂商标识创建余额查询实现。
+func NewProvider(name, baseURL string) (BalanceProvider, error) {
+	f, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的余额查询厂商: %s", name)
+	}
+	return f(strings.TrimSuffix(baseURL, "/"), &http.Client{Timeout: defaultTimeout}), nil
+}
+
+// Providers 返回所有支持的厂商标识。
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getJSON 发起带 Bearer 鉴权的 GET 请求并解析 JSON 响应。
+func getJSON(ctx context.Context, client *http.Client, url, apiKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求失败，状态码：%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("返回数据格式异常: %w", err)
+	}
+	return nil
+}
+
+// flexFloat 兼容以字符串或数字表示的金额。
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f = flexFloat(v)
+	return nil
+}
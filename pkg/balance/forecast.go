@@ -0,0 +1,46 @@
+package balance
+
+import "time"
+
+// Point 是余额时间序列中的一个点。
+type Point struct {
+	Timestamp time.Time `json:"timestamp"`
+	Balance   float64   `json:"balance"`
+}
+
+// Forecast 是根据历史余额估算的消耗速度。
+type Forecast struct {
+	BurnPerDay     float64 `json:"burn_per_day"`
+	DaysUntilEmpty float64 `json:"days_until_empty"` // -1 表示无法估算（数据不足或没有消耗）
+}
+
+// ForecastWindow 是估算消耗速度使用的历史窗口
+const ForecastWindow = 72 * time.Hour
+
+// minForecastSpan 是估算所需的最短时间跨度，过短的样本会被单次大额调用放大
+const minForecastSpan = time.Hour
+
+// Estimate 根据按时间升序排列的余额点估算每日消耗与预计耗尽天数。
+// 只累计余额下降的部分，充值引起的上涨不会抵消消耗。
+func Estimate(points []Point) Forecast {
+	f := Forecast{DaysUntilEmpty: -1}
+	if len(points) < 2 {
+		return f
+	}
+	span := points[len(points)-1].Timestamp.Sub(points[0].Timestamp)
+	if span < minForecastSpan {
+		return f
+	}
+
+	var consumed float64
+	for i := 1; i < len(points); i++ {
+		if d := points[i-1].Balance - points[i].Balance; d > 0 {
+			consumed += d
+		}
+	}
+	f.BurnPerDay = consumed / span.Hours() * 24
+	if f.BurnPerDay > 0 {
+		f.DaysUntilEmpty = max(0, points[len(points)-1].Balance) / f.BurnPerDay
+	}
+	return f
+}
@@ -0,0 +1,37 @@
+package balance
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimate(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours float64, balance float64) Point {
+		return Point{Timestamp: start.Add(time.Duration(hours * float64(time.Hour))), Balance: balance}
+	}
+
+	tests := []struct {
+		name   string
+		points []Point
+		want   Forecast
+	}{
+		{name: "no data", want: Forecast{DaysUntilEmpty: -1}},
+		{name: "single point", points: []Point{at(0, 100)}, want: Forecast{DaysUntilEmpty: -1}},
+		{name: "span too short", points: []Point{at(0, 100), at(0.5, 90)}, want: Forecast{DaysUntilEmpty: -1}},
+		{name: "no consumption", points: []Point{at(0, 100), at(24, 100)}, want: Forecast{DaysUntilEmpty: -1}},
+		{name: "steady burn", points: []Point{at(0, 100), at(12, 95), at(24, 90)}, want: Forecast{BurnPerDay: 10, DaysUntilEmpty: 9}},
+		// 充值引起的上涨不抵消消耗
+		{name: "top up", points: []Point{at(0, 20), at(12, 10), at(13, 110), at(24, 100)}, want: Forecast{BurnPerDay: 20, DaysUntilEmpty: 5}},
+		{name: "overdrawn", points: []Point{at(0, 5), at(24, -1)}, want: Forecast{BurnPerDay: 6, DaysUntilEmpty: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Estimate(tt.points)
+			if math.Abs(got.BurnPerDay-tt.want.BurnPerDay) > 1e-9 || math.Abs(got.DaysUntilEmpty-tt.want.DaysUntilEmpty) > 1e-9 {
+				t.Errorf("Estimate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
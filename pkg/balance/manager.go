@@ -0,0 +1,151 @@
+package balance
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go-proxy/pkg/config"
+)
+
+const defaultCacheTTL = 60 * time.Second
+
+type account struct {
+	name     string
+	provider BalanceProvider
+	apiKey   string
+	info     AccountInfo
+}
+
+// AccountInfo 是账户的公开信息与告警阈值。
+type AccountInfo struct {
+	Name       string  `json:"name"`
+	Provider   string  `json:"provider"`
+	MinBalance float64 `json:"min_balance"`
+	MinDays    float64 `json:"min_days"`
+}
+
+// Manager 管理配置文件中声明的账户，并发查询余额并短暂缓存结果。
+type Manager struct {
+	accounts []account
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*Balance
+}
+
+// NewManager 根据配置创建余额管理器，引用的代理不存在或厂商不受支持的账户会被忽略。
+func NewManager(cfg config.BalanceConfig, proxies []config.ProxyConfig) *Manager {
+	byPath := make(map[string]config.ProxyConfig, len(proxies))
+	for _, p := range proxies {
+		byPath[p.Path] = p
+	}
+
+	m := &Manager{ttl: defaultCacheTTL, cache: make(map[string]*Balance)}
+	if cfg.CacheTTL > 0 {
+		m.ttl = time.Duration(cfg.CacheTTL) * time.Second
+	}
+	for _, a := range cfg.Accounts {
+		if a.Proxy != "" {
+			p, ok := byPath[a.Proxy]
+			if !ok {
+				log.Printf("余额账户 %s 引用的代理 %s 不存在，已忽略", a.Name, a.Proxy)
+				continue
+			}
+			if a.Provider == "" {
+				a.Provider = strings.ToLower(p.Vendor)
+			}
+			if a.APIKey == "" {
+				a.APIKey = p.APIKey
+			}
+			if a.BaseURL == "" {
+				a.BaseURL = p.Target
+			}
+		}
+		if a.Name == "" {
+			a.Name = a.Provider
+		}
+		if a.APIKey == "" {
+			log.Printf("余额账户 %s 未配置 api_key，已忽略", a.Name)
+			continue
+		}
+		provider, err := NewProvider(a.Provider, a.BaseURL)
+		if err != nil {
+			log.Printf("余额账户 %s: %v，已忽略", a.Name, err)
+			continue
+		}
+		m.accounts = append(m.accounts, account{
+			name:     a.Name,
+			provider: provider,
+			apiKey:   a.APIKey,
+			info:     AccountInfo{Name: a.Name, Provider: provider.Name(), MinBalance: a.MinBalance, MinDays: a.MinDays},
+		})
+	}
+	return m
+}
+
+// Len 返回有效账户数量。
+func (m *Manager) Len() int {
+	return len(m.accounts)
+}
+
+// Accounts 返回所有有效账户的信息。
+func (m *Manager) Accounts() []AccountInfo {
+	infos := make([]AccountInfo, len(m.accounts))
+	for i, a := range m.accounts {
+		infos[i] = a.info
+	}
+	return infos
+}
+
+// FetchAll 并发查询所有账户，force 为 true 时忽略缓存。查询失败的账户在 Error 字段中说明原因。
+func (m *Manager) FetchAll(ctx context.Context, force bool) []Balance {
+	results := make([]Balance, len(m.accounts))
+	var wg sync.WaitGroup
+	for i, a := range m.accounts {
+		wg.Add(1)
+		go func(i int, a account) {
+			defer wg.Done()
+			results[i] = *m.fetch(ctx, a, force)
+		}(i, a)
+	}
+	wg.Wait()
+	return results
+}
+
+// Fetch 查询指定名称的账户，账户不存在时返回 false。
+func (m *Manager) Fetch(ctx context.Context, name string, force bool) (*Balance, bool) {
+	for _, a := range m.accounts {
+		if a.name == name {
+			return m.fetch(ctx, a, force), true
+		}
+	}
+	return nil, false
+}
+
+func (m *Manager) fetch(ctx context.Context, a account, force bool) *Balance {
+	if !force {
+		m.mu.Lock()
+		cached, ok := m.cache[a.name]
+		m.mu.Unlock()
+		if ok && time.Since(cached.FetchedAt) < m.ttl {
+			return cached
+		}
+	}
+
+	b, err := a.provider.Fetch(ctx, a.apiKey)
+	if err != nil {
+		log.Printf("查询 %s 余额失败: %v", a.name, err)
+		// 失败结果不缓存，便于尽快恢复
+		return &Balance{Name: a.name, Provider: a.provider.Name(), Error: err.Error(), FetchedAt: time.Now()}
+	}
+	b.Name = a.name
+	b.FetchedAt = time.Now()
+
+	m.mu.Lock()
+	m.cache[a.name] = b
+	m.mu.Unlock()
+	return b
+}
@@ -0,0 +1,212 @@
+package balance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	register("siliconflow", func(baseURL string, client *http.Client) BalanceProvider {
+		return &siliconFlow{baseURL: orDefault(baseURL, "https://api.siliconflow.cn"), client: client}
+	})
+	register("deepseek", func(baseURL string, client *http.Client) BalanceProvider {
+		return &deepSeek{baseURL: orDefault(baseURL, "https://api.deepseek.com"), client: client}
+	})
+	register("openrouter", func(baseURL string, client *http.Client) BalanceProvider {
+		return &openRouter{baseURL: orDefault(baseURL, "https://openrouter.ai/api"), client: client}
+	})
+	register("openai", func(baseURL string, client *http.Client) BalanceProvider {
+		return &openAICredit{baseURL: orDefault(baseURL, "https://api.openai.com"), client: client}
+	})
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// ========================================
+// 硅基流动
+// ========================================
+
+type siliconFlow struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *siliconFlow) Name() string { return "siliconflow" }
+
+// Fetch 查询 /v1/user/info，totalBalance 为赠送余额与充值余额之和。
+func (p *siliconFlow) Fetch(ctx context.Context, apiKey string) (*Balance, error) {
+	var resp struct {
+		Data *struct {
+			ID            string    `json:"id"`
+			Name          string    `json:"name"`
+			Email         string    `json:"email"`
+			Balance       flexFloat `json:"balance"`
+			ChargeBalance flexFloat `json:"chargeBalance"`
+			TotalBalance  flexFloat `json:"totalBalance"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.client, p.baseURL+"/v1/user/info", apiKey, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("返回数据格式异常")
+	}
+	d := resp.Data
+	total := float64(d.TotalBalance)
+	if total == 0 {
+		total = float64(d.Balance + d.ChargeBalance)
+	}
+	return &Balance{
+		Provider: p.Name(),
+		Currency: "CNY",
+		Balance:  total,
+		Details: map[string]any{
+			"id":             d.ID,
+			"user":           d.Name,
+			"email":          d.Email,
+			"granted":        float64(d.Balance),
+			"charge_balance": float64(d.ChargeBalance),
+		},
+	}, nil
+}
+
+// ========================================
+// DeepSeek
+// ========================================
+
+type deepSeek struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *deepSeek) Name() string { return "deepseek" }
+
+// Fetch 查询 /user/balance，账户有多种货币时优先返回人民币余额。
+func (p *deepSeek) Fetch(ctx context.Context, apiKey string) (*Balance, error) {
+	var resp struct {
+		IsAvailable  bool `json:"is_available"`
+		BalanceInfos []struct {
+			Currency        string    `json:"currency"`
+			TotalBalance    flexFloat `json:"total_balance"`
+			GrantedBalance  flexFloat `json:"granted_balance"`
+			ToppedUpBalance flexFloat `json:"topped_up_balance"`
+		} `json:"balance_infos"`
+	}
+	if err := getJSON(ctx, p.client, p.baseURL+"/user/balance", apiKey, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.BalanceInfos) == 0 {
+		return &Balance{Provider: p.Name(), Currency: "CNY", Details: map[string]any{"is_available": resp.IsAvailable}}, nil
+	}
+	info := resp.BalanceInfos[0]
+	for _, bi := range resp.BalanceInfos {
+		if bi.Currency == "CNY" {
+			info = bi
+			break
+		}
+	}
+	return &Balance{
+		Provider: p.Name(),
+		Currency: info.Currency,
+		Balance:  float64(info.TotalBalance),
+		Details: map[string]any{
+			"is_available": resp.IsAvailable,
+			"granted":      float64(info.GrantedBalance),
+			"topped_up":    float64(info.ToppedUpBalance),
+		},
+	}, nil
+}
+
+// ========================================
+// OpenRouter
+// ========================================
+
+type openRouter struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *openRouter) Name() string { return "openrouter" }
+
+// Fetch 查询 /v1/credits，余额为购买额度减去已用额度。
+func (p *openRouter) Fetch(ctx context.Context, apiKey string) (*Balance, error) {
+	var resp struct {
+		Data *struct {
+			TotalCredits flexFloat `json:"total_credits"`
+			TotalUsage   flexFloat `json:"total_usage"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.client, p.baseURL+"/v1/credits", apiKey, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("返回数据格式异常")
+	}
+	return &Balance{
+		Provider: p.Name(),
+		Currency: "USD",
+		Balance:  float64(resp.Data.TotalCredits - resp.Data.TotalUsage),
+		Total:    float64(resp.Data.TotalCredits),
+		Used:     float64(resp.Data.TotalUsage),
+	}, nil
+}
+
+// ========================================
+// OpenAI 兼容额度接口
+// ========================================
+
+// openAICredit 使用 /v1/dashboard/billing/subscription 与 /v1/dashboard/billing/usage，
+// 多数 OpenAI 兼容的中转服务（one-api、new-api 等）都实现了这两个接口。
+type openAICredit struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *openAICredit) Name() string { return "openai" }
+
+func (p *openAICredit) Fetch(ctx context.Context, apiKey string) (*Balance, error) {
+	base := p.baseURL
+	if !strings.HasSuffix(base, "/v1") {
+		base += "/v1"
+	}
+
+	var sub struct {
+		HardLimitUSD flexFloat `json:"hard_limit_usd"`
+		AccessUntil  int64     `json:"access_until"`
+	}
+	if err := getJSON(ctx, p.client, base+"/dashboard/billing/subscription", apiKey, &sub); err != nil {
+		return nil, err
+	}
+
+	// usage 接口的查询范围最长 100 天，total_usage 单位为美分
+	now := time.Now()
+	usageURL := fmt.Sprintf("%s/dashboard/billing/usage?start_date=%s&end_date=%s",
+		base, now.AddDate(0, 0, -99).Format(time.DateOnly), now.AddDate(0, 0, 1).Format(time.DateOnly))
+	var usage struct {
+		TotalUsage flexFloat `json:"total_usage"`
+	}
+	if err := getJSON(ctx, p.client, usageURL, apiKey, &usage); err != nil {
+		return nil, err
+	}
+
+	used := float64(usage.TotalUsage) / 100
+	b := &Balance{
+		Provider: p.Name(),
+		Currency: "USD",
+		Balance:  float64(sub.HardLimitUSD) - used,
+		Total:    float64(sub.HardLimitUSD),
+		Used:     used,
+	}
+	if sub.AccessUntil > 0 {
+		b.Details = map[string]any{"access_until": time.Unix(sub.AccessUntil, 0)}
+	}
+	return b, nil
+}
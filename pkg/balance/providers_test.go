@@ -0,0 +1,122 @@
+package balance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeUpstream 按路径返回固定的 JSON，并检查 Bearer 鉴权。
+func fakeUpstream(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProviders(t *testing.T) {
+	tests := []struct {
+		provider   string
+		baseSuffix string
+		routes     map[string]string
+		want       Balance
+	}{
+		{
+			provider: "siliconflow",
+			routes: map[string]string{
+				"/v1/user/info": `{"data":{"id":"u1","name":"alice","email":"a@example.com","balance":"1.5","chargeBalance":"10","totalBalance":"11.5"}}`,
+			},
+			want: Balance{Provider: "siliconflow", Currency: "CNY", Balance: 11.5, Details: map[string]any{
+				"id": "u1", "user": "alice", "email": "a@example.com", "granted": 1.5, "charge_balance": 10.0,
+			}},
+		},
+		{
+			provider: "deepseek",
+			routes: map[string]string{
+				"/user/balance": `{"is_available":true,"balance_infos":[` +
+					`{"currency":"USD","total_balance":"2.00","granted_balance":"0","topped_up_balance":"2.00"},` +
+					`{"currency":"CNY","total_balance":"110.00","granted_balance":"10.00","topped_up_balance":"100.00"}]}`,
+			},
+			want: Balance{Provider: "deepseek", Currency: "CNY", Balance: 110, Details: map[string]any{
+				"is_available": true, "granted": 10.0, "topped_up": 100.0,
+			}},
+		},
+		{
+			provider: "openrouter",
+			routes: map[string]string{
+				"/v1/credits": `{"data":{"total_credits":25,"total_usage":7.5}}`,
+			},
+			want: Balance{Provider: "openrouter", Currency: "USD", Balance: 17.5, Total: 25, Used: 7.5},
+		},
+		{
+			// 中转服务的地址通常已带 /v1
+			provider:   "openai",
+			baseSuffix: "/v1",
+			routes: map[string]string{
+				"/v1/dashboard/billing/subscription": `{"hard_limit_usd":100,"access_until":0}`,
+				"/v1/dashboard/billing/usage":        `{"total_usage":1234}`,
+			},
+			want: Balance{Provider: "openai", Currency: "USD", Balance: 87.66, Total: 100, Used: 12.34},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			srv := fakeUpstream(t, tt.routes)
+			p, err := NewProvider(strings.ToUpper(tt.provider), srv.URL+tt.baseSuffix+"/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Fetch(context.Background(), "sk-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Fetch =\n  %+v\nwant\n  %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	srv := fakeUpstream(t, map[string]string{"/v1/user/info": `{"code":20000}`, "/v1/credits": `not json`})
+	tests := []struct {
+		provider string
+		wantErr  string
+	}{
+		{provider: "siliconflow", wantErr: "返回数据格式异常"},
+		{provider: "openrouter", wantErr: "返回数据格式异常"},
+		{provider: "deepseek", wantErr: "状态码：404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			p, err := NewProvider(tt.provider, srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Fetch(context.Background(), "sk-test"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Fetch error = %v, want 包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewProvider("unknown", ""); err == nil {
+		t.Error("未知厂商应返回错误")
+	}
+	if got := Providers(); !reflect.DeepEqual(got, []string{"deepseek", "openai", "openrouter", "siliconflow"}) {
+		t.Errorf("Providers = %v", got)
+	}
+}
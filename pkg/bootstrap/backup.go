@@ -0,0 +1,104 @@
+package bootstrap
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/config"
+)
+
+// minBackupInterval 是定时备份的最短间隔
+const minBackupInterval = 5 * time.Minute
+
+// Backup 生成数据库快照，服务运行期间也可以执行，供 `go-proxy backup` 命令使用：
+//
+//	go-proxy backup [-out 文件]
+//
+// 未指定 -out 时写入 backup.dir 并按 backup.keep 清理旧备份。
+func Backup(args []string) error {
+	fset := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fset.String("out", "", "备份文件路径，默认写入 backup.dir 并按 backup.keep 轮转")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	// 只打开连接，不执行迁移，避免与正在运行的服务争用
+	if err := db.OpenDB(cfg.Database); err != nil {
+		return fmt.Errorf("打开数据库失败: %w", err)
+	}
+	defer db.CloseDB()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *out != "" {
+		if err := db.BackupTo(ctx, *out); err != nil {
+			return err
+		}
+		log.Printf("已创建数据库备份 %s。", *out)
+		return nil
+	}
+	_, err = db.CreateBackup(ctx, cfg.Backup.Dir, cfg.Backup.Keep)
+	return err
+}
+
+// Restore 用备份文件替换 SQLite 数据库，供 `go-proxy restore <文件>` 命令使用。
+// 执行前必须停止 go-proxy 服务；原数据库会保留为 <数据库>.before-restore-<时间>。
+func Restore(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: go-proxy restore <备份文件>（执行前请先停止服务）")
+	}
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Database.Driver != "" && cfg.Database.Driver != db.DriverSQLite {
+		return db.ErrBackupUnsupported
+	}
+
+	saved, err := db.RestoreBackup(args[0], cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	if saved != "" {
+		log.Printf("原数据库已保存为 %s。", saved)
+	}
+	log.Printf("已从 %s 恢复数据库，重新启动服务后生效。", args[0])
+	return nil
+}
+
+// ProcessBackups 按 backup.interval 定时备份数据库并清理旧备份。
+// 未配置间隔、数据库未初始化或当前数据库不支持在线备份时直接返回。
+func ProcessBackups(cfg *config.Config) {
+	if cfg.Backup.Interval <= 0 || !db.IsInitialized() {
+		return
+	}
+	if db.Driver() != db.DriverSQLite {
+		log.Printf("定时备份仅支持 SQLite，当前数据库为 %s，跳过。", db.Driver())
+		return
+	}
+	interval := max(time.Duration(cfg.Backup.Interval)*time.Second, minBackupInterval)
+	keep := cfg.Backup.Keep
+	if keep <= 0 {
+		keep = db.DefaultBackupKeep
+	}
+
+	log.Printf("定时备份已启动，间隔 %s，保留 %d 个。", interval, keep)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		db.CreateBackup(ctx, cfg.Backup.Dir, keep)
+		cancel()
+	}
+}
@@ -0,0 +1,123 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/balance"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/notify"
+)
+
+const (
+	// minBalancePollInterval 是余额轮询的最短间隔，避免触发厂商接口限流
+	minBalancePollInterval = time.Minute
+	// balanceAlertCooldown 是同一告警持续存在时重复通知的间隔
+	balanceAlertCooldown = 24 * time.Hour
+)
+
+// ProcessBalances 定时查询服务端账户余额，写入 balance_history，
+// 并在余额低于阈值或按近期消耗速度预计即将耗尽时发送 webhook 通知。未配置 poll_interval 时直接返回。
+// 告警的最近发送时间保存在 balance_alerts 中，重启后冷却期内不会重复通知。
+func ProcessBalances(cfg *config.Config) {
+	if cfg.Balance.PollInterval <= 0 {
+		return
+	}
+	manager := balance.NewManager(cfg.Balance, cfg.Proxies)
+	if manager.Len() == 0 {
+		log.Println("未配置有效的余额账户，跳过余额轮询。")
+		return
+	}
+	interval := max(time.Duration(cfg.Balance.PollInterval)*time.Second, minBalancePollInterval)
+
+	infos := make(map[string]balance.AccountInfo)
+	for _, info := range manager.Accounts() {
+		infos[info.Name] = info
+	}
+	// lastAlerts 记录每种告警最近一次发送时间，条件解除后删除以便再次触发时立即通知
+	lastAlerts := make(map[string]time.Time)
+	if db.IsInitialized() {
+		if saved, err := db.GetBalanceAlerts(); err == nil {
+			lastAlerts = saved
+		}
+	}
+
+	alert := func(key, eventType, message string, data any) {
+		if last, ok := lastAlerts[key]; ok && time.Since(last) < balanceAlertCooldown {
+			return
+		}
+		now := time.Now()
+		lastAlerts[key] = now
+		if db.IsInitialized() {
+			db.MarkBalanceAlert(key, now)
+		}
+		log.Print(message)
+		notify.Send(cfg.Balance.Webhook, notify.Event{Type: eventType, Message: message, Data: data})
+	}
+	resolve := func(key string) {
+		if _, ok := lastAlerts[key]; !ok {
+			return
+		}
+		delete(lastAlerts, key)
+		if db.IsInitialized() {
+			db.ClearBalanceAlert(key)
+		}
+	}
+
+	poll := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+
+		for _, b := range manager.FetchAll(ctx, true) {
+			if b.Error != "" {
+				metrics.UpstreamErrorsTotal.WithLabelValues(b.Name, "balance_fetch").Inc()
+				continue
+			}
+			metrics.Balance.WithLabelValues(b.Name, b.Currency).Set(b.Balance)
+
+			forecast := balance.Forecast{DaysUntilEmpty: -1}
+			if db.IsInitialized() {
+				if err := db.InsertBalanceSnapshot(b); err != nil {
+					metrics.DbErrorsTotal.WithLabelValues("insert_balance").Inc()
+				}
+				if points, err := db.GetBalanceHistory(b.Name, time.Now().Add(-balance.ForecastWindow)); err == nil {
+					forecast = balance.Estimate(points)
+				}
+			}
+			if forecast.DaysUntilEmpty >= 0 {
+				metrics.BalanceDaysUntilEmpty.WithLabelValues(b.Name).Set(forecast.DaysUntilEmpty)
+			}
+
+			info := infos[b.Name]
+			data := map[string]any{"balance": b, "forecast": forecast, "min_balance": info.MinBalance, "min_days": info.MinDays}
+
+			lowKey := b.Name + "|low"
+			if info.MinBalance > 0 && b.Balance < info.MinBalance {
+				alert(lowKey, "balance.low",
+					fmt.Sprintf("账户 %s 余额 %.2f %s 低于阈值 %.2f", b.Name, b.Balance, b.Currency, info.MinBalance), data)
+			} else {
+				resolve(lowKey)
+			}
+
+			burnKey := b.Name + "|burn"
+			if info.MinDays > 0 && forecast.DaysUntilEmpty >= 0 && forecast.DaysUntilEmpty < info.MinDays {
+				alert(burnKey, "balance.burn_rate",
+					fmt.Sprintf("账户 %s 按近期每日消耗 %.2f %s 估算，余额约 %.1f 天后耗尽", b.Name, forecast.BurnPerDay, b.Currency, forecast.DaysUntilEmpty), data)
+			} else {
+				resolve(burnKey)
+			}
+		}
+	}
+
+	log.Printf("余额轮询已启动，间隔 %s，账户 %d 个。", interval, manager.Len())
+	poll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		poll()
+	}
+}
@@ -1,12 +1,10 @@
 package bootstrap
 
 import (
-	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
-	"net/http"
 	"os"
 	"sync"
 
@@ -15,20 +13,26 @@ import (
 	"go-proxy/internal/routes"
 	"go-proxy/pkg/config"
 	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/redact"
 
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
-	dbPath                 = "data/stats.db"
+	configPath             = "data/config.yaml"
 	defaultPort            = "8080"
 	statsChannelBufferSize = 1000
+	// 数据保留天数的默认值
+	defaultRetentionDays       = 90
+	defaultHourlyRetentionDays = 7
+	// 按月预算的用量从 request_logs 重建，日志至少要覆盖一个完整的自然月
+	minMonthlyBudgetRetentionDays = 31
 )
 
 // SetupApp 配置并返回一个 Echo 实例。
 // isVercelEnv: 指示是否在 Vercel 环境中运行。
-// wg: 用于等待后台 goroutine（如 ProcessStats）完成。如果为 nil 且需要后台任务，则会内部创建。
+// wg: 用于等待后台 goroutine（如 ProcessStats）完成。Vercel 环境传 nil，ProcessStats 由 SetupApp 内部启动。
 // staticFS: 可选的静态文件系统（通常为 go:embed 生成），nil 时回退到磁盘目录。
 func SetupApp(isVercelEnv bool, wg *sync.WaitGroup, staticFS fs.FS) (*echo.Echo, *config.Config, error) {
 	e := echo.New()
@@ -52,31 +56,41 @@ func SetupApp(isVercelEnv bool, wg *sync.WaitGroup, staticFS fs.FS) (*echo.Echo,
 		}
 		// 对于 Vercel，我们可能没有完整的 Config 对象，或者可以创建一个简化的
 		cfg = &config.Config{
-			Server:  config.ServerConfig{Port: defaultPort}, // Vercel 通常自己处理端口
+			Server: config.ServerConfig{
+				Port:                defaultPort, // Vercel 通常自己处理端口
+				RetentionDays:       defaultRetentionDays,
+				HourlyRetentionDays: defaultHourlyRetentionDays,
+			},
 			Proxies: proxies,
 		}
 
-		// 在 Vercel 环境中跳过本地数据库初始化
-		if initErr := db.InitDB(dbPath, true); initErr != nil {
-			// 即使 InitDB(true) 应该返回 nil，也处理以防万一
+		// Vercel 环境没有可用的本地数据库，统计数据保存在当前函数实例的内存中
+		if initErr := db.InitDB(config.DatabaseConfig{Driver: db.DriverMemory}, true); initErr != nil {
+			// 内存模式不会出错，也处理以防万一
 			log.Printf("Vercel 环境下 db.InitDB 意外出错: %v", initErr)
-			// 不认为是致命错误，因为 Vercel 环境不应依赖本地 DB
 		}
-		// 在 Vercel 环境中不启动统计通道和处理器
-		log.Println("Vercel 环境：跳过统计通道和后台处理器初始化。")
+		middleware.InitStatsChannel(statsChannelBufferSize)
+		go middleware.ProcessStats(cfg.Server.RetentionDays, cfg.Server.HourlyRetentionDays)
+		log.Println("Vercel 环境：统计数据使用内存模式，实例回收后数据将丢失。")
 
 	} else {
 		log.Println("在非 Vercel (本地/服务器) 环境中设置应用...")
 		// 非 Vercel 环境: 从文件加载配置
-		cfg, err = config.LoadConfig("data/config.yaml")
+		cfg, err = config.LoadConfig(configPath)
 		if err != nil {
 			return nil, nil, fmt.Errorf("加载配置文件失败: %w", err)
 		}
 		proxies = cfg.Proxies
 
-		// 初始化数据库
-		if initErr := db.InitDB(dbPath, false); initErr != nil {
-			return nil, nil, fmt.Errorf("初始化数据库失败: %w", initErr)
+		// 日志与数据库中的敏感查询参数、请求头
+		redact.Configure(cfg.Redaction.QueryParams, cfg.Redaction.Headers)
+
+		// 初始化数据库，失败时回退到内存模式，统计与仪表盘仍可使用但数据不会持久化
+		if initErr := db.InitDB(cfg.Database, false); initErr != nil {
+			log.Printf("警告: 初始化数据库失败，统计数据改用内存模式: %v", initErr)
+			if memErr := db.InitDB(config.DatabaseConfig{Driver: db.DriverMemory, MemoryLogs: cfg.Database.MemoryLogs}, false); memErr != nil {
+				return nil, nil, fmt.Errorf("初始化数据库失败: %w", memErr)
+			}
 		}
 
 		// 初始化统计通道
@@ -85,20 +99,44 @@ func SetupApp(isVercelEnv bool, wg *sync.WaitGroup, staticFS fs.FS) (*echo.Echo,
 		// 确定数据保留天数（默认90天）
 		retentionDays := cfg.Server.RetentionDays
 		if retentionDays <= 0 {
-			retentionDays = 90
+			retentionDays = defaultRetentionDays
+		}
+		if retentionDays < minMonthlyBudgetRetentionDays && hasMonthlyBudget(cfg.Budgets) {
+			log.Printf("警告: 按月预算需要保留至少 %d 天的请求日志，retention_days 由 %d 调整为 %d",
+				minMonthlyBudgetRetentionDays, retentionDays, minMonthlyBudgetRetentionDays)
+			retentionDays = minMonthlyBudgetRetentionDays
 		}
 		cfg.Server.RetentionDays = retentionDays
-
-		// 启动时聚合：如果 daily_summary 为空则全量回溯，否则只刷新最近1天
-		aggDays := 1
-		if db.IsSummaryEmpty() {
-			aggDays = retentionDays
-			log.Printf("daily_summary 为空，首次全量聚合（回溯 %d 天）...", aggDays)
+		if cfg.Server.HourlyRetentionDays <= 0 {
+			cfg.Server.HourlyRetentionDays = defaultHourlyRetentionDays
 		}
-		if aggErr := db.AggregateDaily(aggDays); aggErr != nil {
-			log.Printf("启动时聚合每日统计失败（非致命）: %v", aggErr)
-		} else {
-			log.Println("启动时每日统计聚合完成。")
+
+		// 内存模式在写入时汇总，不需要从 request_logs 聚合
+		if db.IsInitialized() {
+			// 启动时聚合：如果 daily_summary 为空则全量回溯，否则只刷新最近1天
+			aggDays := 1
+			if db.IsSummaryEmpty() {
+				aggDays = retentionDays
+				log.Printf("daily_summary 为空，首次全量聚合（回溯 %d 天）...", aggDays)
+			} else if db.IsLatencyMissing() {
+				// 升级后首次启动，为历史数据补齐响应时间直方图
+				aggDays = retentionDays
+				log.Printf("daily_summary 缺少响应时间直方图，全量聚合（回溯 %d 天）...", aggDays)
+			}
+			if aggErr := db.AggregateDaily(aggDays); aggErr != nil {
+				log.Printf("启动时聚合每日统计失败（非致命）: %v", aggErr)
+			} else {
+				log.Println("启动时每日统计聚合完成。")
+			}
+
+			// 小时汇总为空时按其保留期限回溯
+			aggHours := 2
+			if db.IsHourlySummaryEmpty() {
+				aggHours = cfg.Server.HourlyRetentionDays * 24
+			}
+			if aggErr := db.AggregateHourly(aggHours); aggErr != nil {
+				log.Printf("启动时聚合小时统计失败（非致命）: %v", aggErr)
+			}
 		}
 
 		if wg == nil {
@@ -109,7 +147,7 @@ func SetupApp(isVercelEnv bool, wg *sync.WaitGroup, staticFS fs.FS) (*echo.Echo,
 	}
 
 	// 注册路由
-	routes.RegisterRoutes(e, proxies, staticFS)
+	routes.RegisterRoutes(e, cfg, staticFS)
 
 	// 初始化 Prometheus 指标（根据配置开关）
 	metricsEnabled := cfg.Metrics.Enabled
@@ -119,20 +157,8 @@ func SetupApp(isVercelEnv bool, wg *sync.WaitGroup, staticFS fs.FS) (*echo.Echo,
 
 		// 如果配置了 Basic Auth 认证
 		if cfg.Metrics.Username != "" && cfg.Metrics.Password != "" {
-			expectedUser := cfg.Metrics.Username
-			expectedPass := cfg.Metrics.Password
-			e.GET("/metrics", echo.WrapHandler(metricsHandler), func(next echo.HandlerFunc) echo.HandlerFunc {
-				return func(c echo.Context) error {
-					user, pass, ok := c.Request().BasicAuth()
-					if !ok ||
-						subtle.ConstantTimeCompare([]byte(user), []byte(expectedUser)) != 1 ||
-						subtle.ConstantTimeCompare([]byte(pass), []byte(expectedPass)) != 1 {
-						c.Response().Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
-						return c.String(http.StatusUnauthorized, "Unauthorized")
-					}
-					return next(c)
-				}
-			})
+			e.GET("/metrics", echo.WrapHandler(metricsHandler),
+				middleware.BasicAuth(cfg.Metrics.Username, cfg.Metrics.Password, "metrics"))
 			log.Println("Prometheus 指标端点已启用: /metrics (Basic Auth 保护)")
 		} else {
 			e.GET("/metrics", echo.WrapHandler(metricsHandler))
@@ -142,3 +168,16 @@ func SetupApp(isVercelEnv bool, wg *sync.WaitGroup, staticFS fs.FS) (*echo.Echo,
 
 	return e, cfg, nil
 }
+
+// hasMonthlyBudget 判断是否启用了按月统计的预算规则。
+func hasMonthlyBudget(cfg config.BudgetConfig) bool {
+	if !cfg.Enabled {
+		return false
+	}
+	for _, rule := range cfg.Rules {
+		if rule.Period == "month" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/export"
+)
+
+// Export 将数据集导出到文件或标准输出，供 `go-proxy export` 命令使用：
+//
+//	go-proxy export <requests|daily|usage> [-format csv|ndjson|parquet] [-from 日期] [-to 日期] [-service 路径] [-out 文件]
+//
+// from、to 为 YYYY-MM-DD（本地日期，均包含）或 RFC3339 时间，默认最近 7 天。
+func Export(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("用法: go-proxy export <requests|daily|usage> [-format csv|ndjson|parquet] [-from 日期] [-to 日期] [-service 路径] [-out 文件]")
+	}
+	dataset := args[0]
+	fset := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fset.String("format", export.FormatCSV, "导出格式：csv、ndjson 或 parquet")
+	fromStr := fset.String("from", "", "起始日期（YYYY-MM-DD 或 RFC3339），默认 7 天前")
+	toStr := fset.String("to", "", "结束日期（YYYY-MM-DD 含当天，或 RFC3339），默认今天")
+	service := fset.String("service", "", "只导出指定代理路径")
+	out := fset.String("out", "", "输出文件，默认标准输出")
+	if err := fset.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cols, err := db.ExportColumns(dataset)
+	if err != nil {
+		return err
+	}
+	if *format != export.FormatCSV && *format != export.FormatNDJSON && *format != export.FormatParquet {
+		return fmt.Errorf("不支持的导出格式 %q，可选 csv、ndjson、parquet", *format)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	q := db.ExportQuery{Dataset: dataset, From: today.AddDate(0, 0, -6), To: today.AddDate(0, 0, 1), Service: *service}
+	if *fromStr != "" {
+		if q.From, err = parseExportTime(*fromStr, false); err != nil {
+			return fmt.Errorf("无效的 -from: %w", err)
+		}
+	}
+	if *toStr != "" {
+		if q.To, err = parseExportTime(*toStr, true); err != nil {
+			return fmt.Errorf("无效的 -to: %w", err)
+		}
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("-from 必须早于 -to")
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer f.Close()
+		dst = f
+	}
+	bw := bufio.NewWriterSize(dst, 256<<10)
+
+	if err := db.InitDB(cfg.Database, false); err != nil {
+		return fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	defer db.CloseDB()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w, err := export.NewWriter(*format, bw, cols)
+	if err != nil {
+		return err
+	}
+	n, err := db.ExportRows(ctx, q, w.Write)
+	if err != nil {
+		return fmt.Errorf("导出失败（已写出 %d 行）: %w", n, err)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	log.Printf("已导出 %s 数据 %d 行（%s ~ %s）。", dataset, n, q.From.Format(time.DateTime), q.To.Format(time.DateTime))
+	return nil
+}
+
+// parseExportTime 解析 YYYY-MM-DD 或 RFC3339，end 为 true 时日期表示包含当天。
+func parseExportTime(s string, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("需要 YYYY-MM-DD 或 RFC3339 格式")
+	}
+	return t.Local(), nil
+}
@@ -0,0 +1,75 @@
+package bootstrap
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go-proxy/internal/db"
+)
+
+// Migrate 查看或执行数据库结构迁移，供 `go-proxy migrate` 命令使用：
+//
+//	go-proxy migrate status   列出各迁移版本及是否已应用（默认）
+//	go-proxy migrate up       应用所有尚未执行的迁移（服务启动时也会自动执行）
+func Migrate(args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action != "status" && action != "up" {
+		return fmt.Errorf("用法: go-proxy migrate [status|up]")
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	if err := db.OpenDB(cfg.Database); err != nil {
+		return fmt.Errorf("打开数据库失败: %w", err)
+	}
+	defer db.CloseDB()
+
+	if action == "up" {
+		n, err := db.Migrate()
+		if err != nil {
+			return err
+		}
+		log.Printf("已应用 %d 个迁移，当前结构版本 %d。", n, db.LatestSchemaVersion())
+		return nil
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	statuses, err := db.GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("数据库驱动: %s\n当前结构版本: %d（程序支持: %d）\n\n", db.Driver(), current, db.LatestSchemaVersion())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "版本\t名称\t状态\t应用时间")
+	for _, s := range statuses {
+		state, at := "未应用", ""
+		if s.Applied {
+			state = "已应用"
+			if !s.AppliedAt.IsZero() {
+				at = s.AppliedAt.Local().Format(time.DateTime)
+			}
+		}
+		if s.Unknown {
+			state = "未知（由更新版本的程序应用）"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if current > db.LatestSchemaVersion() {
+		return fmt.Errorf("%w，请升级 go-proxy", db.ErrSchemaTooNew)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"go-proxy/internal/db"
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/redact"
+)
+
+// ScrubLogs 按当前的脱敏配置重写数据库中已保存的请求 URI，供 `go-proxy scrub-logs` 命令使用。
+// 配置文件不存在时只使用内置的敏感参数列表。
+func ScrubLogs() error {
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	redact.Configure(cfg.Redaction.QueryParams, cfg.Redaction.Headers)
+
+	if err := db.InitDB(cfg.Database, false); err != nil {
+		return fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	defer db.CloseDB()
+
+	n, err := db.ScrubRequestURIs(redact.Text)
+	if err != nil {
+		return fmt.Errorf("清理 request_logs 失败（已处理 %d 条）: %w", n, err)
+	}
+	log.Printf("已清理 %d 条 request_logs 记录中的敏感查询参数。", n)
+	return nil
+}
+
+// loadCommandConfig 为命令行子命令加载配置文件，文件不存在时返回空配置（使用默认的 SQLite 数据库）。
+// 配置文件存在但无法读取或解析时返回错误，避免子命令误操作默认数据库。
+func loadCommandConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &config.Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	return cfg, nil
+}
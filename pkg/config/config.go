@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,6 +13,31 @@ import (
 type ServerConfig struct {
 	Port          string `yaml:"port"`
 	RetentionDays int    `yaml:"retention_days"` // 数据保留天数，默认90
+	// HourlyRetentionDays 小时汇总（hourly_summary）保留天数，默认7
+	HourlyRetentionDays int `yaml:"hourly_retention_days"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR。配置后，来自这些地址的请求按 X-Forwarded-For 确定客户端 IP；
+	// 留空时只使用连接的来源地址
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// DatabaseConfig 统计数据的存储后端。Driver 为 sqlite（默认）、postgres 或 memory；
+// DSN 对 SQLite 是数据库文件路径（默认 data/stats.db），对 PostgreSQL 是连接串。
+// memory 不使用数据库，统计数据只保存在进程内存中，重启后丢失。
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+	// Timezone PostgreSQL 会话时区（IANA 名称），决定按日、按小时聚合的边界，默认与进程本地时区一致
+	Timezone string `yaml:"timezone"`
+	// MemoryLogs memory 模式下保留的最近请求日志条数，默认 10000
+	MemoryLogs int `yaml:"memory_logs"`
+}
+
+// BackupConfig SQLite 数据库的在线备份。Interval 为定时备份间隔（秒），0 表示不定时备份；
+// 手动与定时备份都写入 Dir（默认 data/backups），并只保留最近 Keep 个（默认 7）。
+type BackupConfig struct {
+	Dir      string `yaml:"dir"`
+	Interval int    `yaml:"interval"`
+	Keep     int    `yaml:"keep"`
 }
 
 type MetricsConfig struct {
@@ -23,12 +50,223 @@ type ProxyConfig struct {
 	Path   string `yaml:"path"`
 	Target string `yaml:"target"`
 	Vendor string `yaml:"vendor"` // 添加厂商字段
+	// Translate 对外暴露的协议转换模式，目前支持 "openai"：
+	// 接收 OpenAI /v1/chat/completions 请求并转换为 Vendor 对应的原生 API
+	Translate string `yaml:"translate"`
+	// APIKey 服务端保存的上游密钥。配置后转发请求时会覆盖客户端携带的凭据，
+	// 聚合模型列表等由 go-proxy 主动发起的请求也使用该密钥
+	APIKey string `yaml:"api_key" json:"api_key"`
+	// ServiceAccount Google 服务账号 JSON 文件路径。配置后由 go-proxy 签发 JWT 换取访问令牌，
+	// 以 Authorization: Bearer 注入上游请求（用于 Vertex AI），优先于 APIKey
+	ServiceAccount string `yaml:"service_account" json:"service_account"`
+	// TokenURL 换取访问令牌的地址，默认使用服务账号文件中的 token_uri
+	TokenURL string `yaml:"token_url" json:"token_url"`
+	// Scopes 访问令牌的授权范围，默认 https://www.googleapis.com/auth/cloud-platform
+	Scopes []string `yaml:"scopes" json:"scopes"`
+	// Azure vendor 为 azure 时的部署映射与 API 版本
+	Azure AzureConfig `yaml:"azure" json:"azure"`
+	// AWS vendor 为 bedrock 时使用的签名凭据，客户端发送未签名的请求，由 go-proxy 使用 SigV4 签名
+	AWS AWSConfig `yaml:"aws" json:"aws"`
+	// RateLimit 该代理（所有客户端合计）的限流配置
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+	// BodyLog 请求/响应体记录，默认关闭
+	BodyLog BodyLogConfig `yaml:"body_log" json:"body_log"`
+	// PII 出站请求中的个人信息检测，默认关闭
+	PII PIIConfig `yaml:"pii" json:"pii"`
+}
+
+// PIIConfig 检测 JSON 聊天请求中的个人信息。Action 为命中后的默认处理方式：
+// mask（替换后转发）、block（拒绝请求）或 log（只记录，默认）。
+type PIIConfig struct {
+	Enabled bool      `yaml:"enabled" json:"enabled"`
+	Action  string    `yaml:"action" json:"action"`
+	Rules   []PIIRule `yaml:"rules" json:"rules"`
+}
+
+// PIIRule 是一条检测规则。只填 Name 时引用内置规则（email、phone、id_card、ssn），
+// 否则使用 Pattern 正则或 Words 词典（不区分大小写）。Action 为空时使用 PIIConfig.Action。
+type PIIRule struct {
+	Name    string   `yaml:"name" json:"name"`
+	Pattern string   `yaml:"pattern" json:"pattern"`
+	Words   []string `yaml:"words" json:"words"`
+	Action  string   `yaml:"action" json:"action"`
+}
+
+// BodyLogConfig 按代理采样记录请求与响应体，保存在 request_bodies 表并关联 request_logs.id。
+type BodyLogConfig struct {
+	Enabled    bool    `yaml:"enabled" json:"enabled"`
+	SampleRate float64 `yaml:"sample_rate" json:"sample_rate"` // 采样率 0~1，未配置时为 1（全部记录）
+	MaxBytes   int     `yaml:"max_bytes" json:"max_bytes"`     // 单个请求体或响应体最多保存的字节数，默认 64KB
+	Compress   bool    `yaml:"compress" json:"compress"`       // 使用 gzip 压缩后保存
+	// Redact 需要脱敏的 JSON 路径，例如 messages[*].content。
+	// 配置后无法解析的 JSON（例如超过 max_bytes 被截断）不会保存原文
+	Redact []string `yaml:"redact" json:"redact"`
+	// RetentionDays 保留天数，独立于 server.retention_days，默认 7 天
+	RetentionDays int `yaml:"retention_days" json:"retention_days"`
+}
+
+// AzureConfig 将 OpenAI 风格的请求映射到 Azure OpenAI 的部署。Deployments 的键为模型名
+// （不区分大小写，"*" 为默认部署），未匹配时直接以模型名作为部署名。
+type AzureConfig struct {
+	APIVersion  string            `yaml:"api_version" json:"api_version"` // 默认 2024-10-21
+	Deployments map[string]string `yaml:"deployments" json:"deployments"`
+}
+
+// AWSConfig 是 AWS SigV4 签名配置，凭据未填写时读取 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、
+// AWS_SESSION_TOKEN 与 AWS_REGION 环境变量。
+type AWSConfig struct {
+	Region          string `yaml:"region" json:"region"`
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"secret_access_key"`
+	SessionToken    string `yaml:"session_token" json:"session_token"`
+	Service         string `yaml:"service" json:"service"` // 签名服务名，默认 bedrock
+}
+
+// RateLimitConfig 令牌桶限流配置，0 表示不限制。
+type RateLimitConfig struct {
+	RPM        int `yaml:"rpm" json:"rpm"`               // 每分钟请求数
+	TPM        int `yaml:"tpm" json:"tpm"`               // 每分钟 token 数，按上游响应中的用量事后扣减
+	Concurrent int `yaml:"concurrent" json:"concurrent"` // 最大并发请求数
+}
+
+// openAICompatibleVendors 提供 OpenAI 兼容 /v1/models 接口的厂商
+var openAICompatibleVendors = map[string]bool{
+	"openai":      true,
+	"groq":        true,
+	"xai":         true,
+	"x":           true,
+	"deepseek":    true,
+	"siliconflow": true,
+	"openrouter":  true,
+	"mistral":     true,
+	"together":    true,
+}
+
+// IsOpenAICompatible 判断该代理的上游是否提供 OpenAI 兼容接口。
+func (p ProxyConfig) IsOpenAICompatible() bool {
+	return openAICompatibleVendors[strings.ToLower(p.Vendor)]
+}
+
+// RouteRule 将匹配 Model 模式（支持 * 和 ? 通配符）的请求路由到 Proxy 指定的代理路径。
+type RouteRule struct {
+	Model string `yaml:"model"`
+	Proxy string `yaml:"proxy"`
+}
+
+// RouterConfig 统一入口的模型路由配置。
+type RouterConfig struct {
+	Enabled bool              `yaml:"enabled"` // 是否启用模型路由
+	Path    string            `yaml:"path"`    // 统一入口路径前缀，默认 /v1
+	Default string            `yaml:"default"` // 未匹配任何规则时使用的代理路径，留空则返回 404
+	Aliases map[string]string `yaml:"aliases"` // 模型别名，例如 fast -> llama-3.1-8b-instant
+	Rules   []RouteRule       `yaml:"rules"`   // 按顺序匹配，命中第一条即停止
+}
+
+// ModelsConfig 聚合模型列表端点的配置。
+type ModelsConfig struct {
+	Enabled  bool              `yaml:"enabled"`   // 是否启用聚合模型列表
+	Path     string            `yaml:"path"`      // 端点路径，默认 /v1/models
+	CacheTTL int               `yaml:"cache_ttl"` // 缓存时间（秒），默认 300
+	Timeout  int               `yaml:"timeout"`   // 单个上游超时时间（秒），默认 10
+	Prefix   bool              `yaml:"prefix"`    // 是否为模型 ID 添加来源前缀，例如 openai/gpt-4o
+	Aliases  map[string]string `yaml:"aliases"`   // 模型 ID 重命名，键为（加前缀后的）原始 ID
+	Proxies  []string          `yaml:"proxies"`   // 参与聚合的代理路径，留空则使用所有 OpenAI 兼容代理
+}
+
+// ClientKeyConfig 在配置文件中声明的客户端密钥。
+type ClientKeyConfig struct {
+	Name         string    `yaml:"name"`
+	Key          string    `yaml:"key"`
+	AllowedPaths []string  `yaml:"allowed_paths"` // 允许访问的代理路径，留空表示全部
+	ExpiresAt    time.Time `yaml:"expires_at"`    // 过期时间，留空表示永不过期
+	Disabled     bool      `yaml:"disabled"`
+	Team         string    `yaml:"team"` // 所属团队，用于按团队汇总预算
+	// RateLimit 该密钥（所有代理合计）的限流配置
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+// AuthConfig 代理路由的客户端鉴权配置。
+type AuthConfig struct {
+	Enabled bool              `yaml:"enabled"` // 是否启用客户端鉴权
+	Header  string            `yaml:"header"`  // 自定义密钥请求头，例如 X-Proxy-Key；未设置时从 Authorization / x-api-key 读取
+	Keys    []ClientKeyConfig `yaml:"keys"`    // 配置文件中的密钥，另可通过管理接口存入 SQLite
+}
+
+// AdminConfig 管理接口的 Basic Auth 凭据，未配置时不注册管理接口。
+type AdminConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// ModelPrice 模型单价，单位为每百万 token 的金额。
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`  // 输入（prompt）token 单价
+	Output float64 `yaml:"output"` // 输出（completion）token 单价
+}
+
+// BudgetRule 一条预算规则，Tokens 与 Cost 任一达到上限即拒绝请求。
+type BudgetRule struct {
+	Scope         string  `yaml:"scope"`          // key 或 team
+	Name          string  `yaml:"name"`           // 密钥名称或团队名称，* 或留空表示对每个密钥/团队分别生效
+	Period        string  `yaml:"period"`         // day 或 month，按服务器本地时间切分
+	Tokens        int64   `yaml:"tokens"`         // token 上限，0 表示不限制
+	Cost          float64 `yaml:"cost"`           // 费用上限，0 表示不限制
+	SoftThreshold float64 `yaml:"soft_threshold"` // 软阈值（0~1），达到后发送 webhook 通知，默认 0.8
+}
+
+// BudgetConfig 按客户端密钥/团队的预算配置，依赖客户端鉴权与 SQLite 中记录的用量。
+type BudgetConfig struct {
+	Enabled  bool         `yaml:"enabled"`
+	Currency string       `yaml:"currency"` // 费用单位，仅用于提示与通知，默认 USD
+	Webhook  string       `yaml:"webhook"`  // 软阈值与预算耗尽时通知的 URL
+	Rules    []BudgetRule `yaml:"rules"`
+}
+
+// BalanceAccount 需要查询余额的上游账户，密钥保存在服务端。
+type BalanceAccount struct {
+	Name     string `yaml:"name"`
+	Provider string `yaml:"provider"` // siliconflow、deepseek、openrouter 或 openai（OpenAI 兼容的 billing 接口）
+	APIKey   string `yaml:"api_key"`
+	BaseURL  string `yaml:"base_url"` // 可选，覆盖厂商默认地址
+	// Proxy 可选，引用一个代理路径，未配置 provider / api_key / base_url 时
+	// 分别使用该代理的 vendor、api_key 与 target
+	Proxy string `yaml:"proxy"`
+	// 告警阈值，0 表示不告警，需要启用 poll_interval
+	MinBalance float64 `yaml:"min_balance"` // 余额低于该值时告警
+	MinDays    float64 `yaml:"min_days"`    // 按近期消耗速度预计不足该天数即耗尽时告警
+}
+
+// BalanceConfig 服务端余额查询配置。
+type BalanceConfig struct {
+	CacheTTL     int              `yaml:"cache_ttl"`     // 查询结果缓存时间（秒），默认 60
+	PollInterval int              `yaml:"poll_interval"` // 定时查询间隔（秒），0 表示不轮询，最小 60
+	Webhook      string           `yaml:"webhook"`       // 余额告警通知地址
+	Accounts     []BalanceAccount `yaml:"accounts"`
 }
 
 type Config struct {
-	Server  ServerConfig  `yaml:"server"`
-	Metrics MetricsConfig `yaml:"metrics"`
-	Proxies []ProxyConfig `yaml:"proxies"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Backup   BackupConfig   `yaml:"backup"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Admin    AdminConfig    `yaml:"admin"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Router   RouterConfig   `yaml:"router"`
+	Models   ModelsConfig   `yaml:"models"`
+	Budgets  BudgetConfig   `yaml:"budgets"`
+	Balance  BalanceConfig  `yaml:"balance"`
+	// Redaction 日志与数据库中需要隐藏的查询参数与请求头
+	Redaction RedactionConfig `yaml:"redaction"`
+	// Pricing 模型单价表，键为模型名，支持 * 和 ? 通配符，用于计算请求费用
+	Pricing map[string]ModelPrice `yaml:"pricing"`
+	Proxies []ProxyConfig         `yaml:"proxies"`
+}
+
+// RedactionConfig 在内置列表（key、api_key、Authorization、x-api-key 等）之外追加需要脱敏的
+// 查询参数与请求头名称，不区分大小写。
+type RedactionConfig struct {
+	QueryParams []string `yaml:"query_params" json:"query_params"`
+	Headers     []string `yaml:"headers" json:"headers"`
 }
 
 func LoadConfig(path string) (*Config, error) {
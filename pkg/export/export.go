@@ -0,0 +1,166 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// 导出格式
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// ColumnType 是导出列的数据类型。
+type ColumnType int
+
+const (
+	String ColumnType = iota
+	Int64
+	Float64
+	Timestamp // time.Time，CSV/NDJSON 中为 RFC3339（UTC），Parquet 中为毫秒时间戳
+)
+
+// Column 描述一列导出数据。
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Writer 按行写出数据。row 中的值按列顺序排列，类型须与列类型一致：
+// String 为 string，Int64 为 int64，Float64 为 float64，Timestamp 为 time.Time。
+// Close 写出缓冲的数据（Parquet 还会写出文件尾），不关闭底层的 io.Writer。
+type Writer interface {
+	Write(row []any) error
+	Close() error
+}
+
+// NewWriter 创建指定格式的写出器。
+func NewWriter(format string, w io.Writer, cols []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), cols: cols}, nil
+	case FormatParquet:
+		return newParquetWriter(w, cols), nil
+	}
+	return nil, fmt.Errorf("不支持的导出格式 %q，可选 csv、ndjson、parquet", format)
+}
+
+// ContentType 返回导出格式对应的 MIME 类型。
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/vnd.apache.parquet"
+}
+
+func checkRow(cols []Column, row []any) error {
+	if len(row) != len(cols) {
+		return fmt.Errorf("列数不匹配：需要 %d 列，实际 %d 列", len(cols), len(row))
+	}
+	for i, col := range cols {
+		ok := false
+		switch col.Type {
+		case String:
+			_, ok = row[i].(string)
+		case Int64:
+			_, ok = row[i].(int64)
+		case Float64:
+			_, ok = row[i].(float64)
+		case Timestamp:
+			_, ok = row[i].(time.Time)
+		}
+		if !ok {
+			return fmt.Errorf("列 %s 的值类型不正确: %T", col.Name, row[i])
+		}
+	}
+	return nil
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	cols   []Column
+	record []string
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols, record: make([]string, len(cols))}
+	for i, col := range cols {
+		cw.record[i] = col.Name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(row []any) error {
+	if err := checkRow(cw.cols, row); err != nil {
+		return err
+	}
+	for i, v := range row {
+		switch v := v.(type) {
+		case string:
+			cw.record[i] = v
+		case int64:
+			cw.record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			cw.record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			cw.record[i] = v.UTC().Format(time.RFC3339)
+		}
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	cols []Column
+}
+
+func (nw *ndjsonWriter) Write(row []any) error {
+	if err := checkRow(nw.cols, row); err != nil {
+		return err
+	}
+	// 手动拼接以保持列顺序
+	nw.w.WriteByte('{')
+	for i, col := range nw.cols {
+		if i > 0 {
+			nw.w.WriteByte(',')
+		}
+		name, _ := json.Marshal(col.Name)
+		nw.w.Write(name)
+		nw.w.WriteByte(':')
+		v := row[i]
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		nw.w.Write(data)
+	}
+	nw.w.WriteByte('}')
+	return nw.w.WriteByte('\n')
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.w.Flush()
+}
@@ -0,0 +1,89 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testColumns = []Column{
+	{Name: "timestamp", Type: Timestamp},
+	{Name: "service", Type: String},
+	{Name: "status", Type: Int64},
+	{Name: "cost", Type: Float64},
+}
+
+func testRow(i int) []any {
+	ts := time.Date(2026, 10, 19, 8, 0, 0, 0, time.FixedZone("CST", 8*3600)).Add(time.Duration(i) * time.Second)
+	return []any{ts, []string{"/openai", `/claude,"quoted"`, "/gemini\n"}[i%3], int64(200 + i%3*100), float64(i) / 4}
+}
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: FormatCSV,
+			want: "timestamp,service,status,cost\n" +
+				"2026-10-19T00:00:00Z,/openai,200,0\n" +
+				"2026-10-19T00:00:01Z,\"/claude,\"\"quoted\"\"\",300,0.25\n" +
+				"2026-10-19T00:00:02Z,\"/gemini\n\",400,0.5\n",
+		},
+		{
+			format: FormatNDJSON,
+			want: `{"timestamp":"2026-10-19T00:00:00Z","service":"/openai","status":200,"cost":0}` + "\n" +
+				`{"timestamp":"2026-10-19T00:00:01Z","service":"/claude,\"quoted\"","status":300,"cost":0.25}` + "\n" +
+				`{"timestamp":"2026-10-19T00:00:02Z","service":"/gemini\n","status":400,"cost":0.5}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			w, err := NewWriter(tt.format, &out, testColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range 3 {
+				if err := w.Write(testRow(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("输出 =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriterRejectsBadRows(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatNDJSON, FormatParquet} {
+		t.Run(format, func(t *testing.T) {
+			w, err := NewWriter(format, &bytes.Buffer{}, testColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write(testRow(0)[:3]); err == nil || !strings.Contains(err.Error(), "列数不匹配") {
+				t.Errorf("列数不足时 Write = %v", err)
+			}
+			row := testRow(0)
+			row[2] = 200 // int 而不是 int64
+			if err := w.Write(row); err == nil || !strings.Contains(err.Error(), "status") {
+				t.Errorf("类型错误时 Write = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xlsx", &bytes.Buffer{}, testColumns); err == nil {
+		t.Error("未知格式应返回错误")
+	}
+	if got := ContentType(FormatParquet); got != "application/vnd.apache.parquet" {
+		t.Errorf("ContentType = %q", got)
+	}
+}
@@ -0,0 +1,250 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	parquetMagic = "PAR1"
+
+	// 行组的最大行数与最大缓冲字节数，达到任一值时写出行组，导出时内存占用不随数据量增长
+	parquetRowGroupRows  = 65536
+	parquetRowGroupBytes = 32 << 20
+)
+
+// Parquet 枚举值
+const (
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecGzip = 2
+
+	parquetDataPage = 0
+)
+
+// countingWriter 记录已写出的字节数，用于计算列块在文件中的偏移。
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type parquetChunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type parquetRowGroup struct {
+	chunks    []parquetChunk
+	numRows   int64
+	totalSize int64
+}
+
+// parquetWriter 以 PLAIN 编码、GZIP 压缩写出 Parquet 文件，所有列均为 REQUIRED。
+// 每个行组的每列只有一个数据页，文件尾在 Close 时写出，因此可以写入不支持 Seek 的流。
+type parquetWriter struct {
+	w       *countingWriter
+	cols    []Column
+	bufs    []bytes.Buffer
+	rows    int64
+	started bool
+	groups  []parquetRowGroup
+	total   int64
+}
+
+func newParquetWriter(w io.Writer, cols []Column) *parquetWriter {
+	return &parquetWriter{w: &countingWriter{w: w}, cols: cols, bufs: make([]bytes.Buffer, len(cols))}
+}
+
+func (pw *parquetWriter) Write(row []any) error {
+	if err := checkRow(pw.cols, row); err != nil {
+		return err
+	}
+	var scratch [8]byte
+	size := 0
+	for i, v := range row {
+		buf := &pw.bufs[i]
+		switch v := v.(type) {
+		case string:
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(v)))
+			buf.Write(scratch[:4])
+			buf.WriteString(v)
+		case int64:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(v))
+			buf.Write(scratch[:])
+		case float64:
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v))
+			buf.Write(scratch[:])
+		case time.Time:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(v.UnixMilli()))
+			buf.Write(scratch[:])
+		}
+		size += buf.Len()
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupRows || size >= parquetRowGroupBytes {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) start() error {
+	if pw.started {
+		return nil
+	}
+	pw.started = true
+	_, err := io.WriteString(pw.w, parquetMagic)
+	return err
+}
+
+// flush 将缓冲的行写成一个行组。
+func (pw *parquetWriter) flush() error {
+	if err := pw.start(); err != nil {
+		return err
+	}
+	if pw.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{numRows: pw.rows}
+	var compressed bytes.Buffer
+	for i := range pw.cols {
+		data := pw.bufs[i].Bytes()
+		compressed.Reset()
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		var header thriftWriter
+		header.beginStruct(0)
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(compressed.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(pw.rows))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetChunk{
+			offset:           pw.w.n,
+			numValues:        pw.rows,
+			uncompressedSize: int64(header.buf.Len() + len(data)),
+			compressedSize:   int64(header.buf.Len() + compressed.Len()),
+		}
+		if _, err := pw.w.Write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := pw.w.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.totalSize += chunk.uncompressedSize
+		pw.bufs[i].Reset()
+	}
+	pw.groups = append(pw.groups, group)
+	pw.total += pw.rows
+	pw.rows = 0
+	return nil
+}
+
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var meta thriftWriter
+	meta.beginStruct(0)
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStruct, len(pw.cols)+1)
+	meta.beginStruct(0)
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(pw.cols)))
+	meta.endStruct()
+	for _, col := range pw.cols {
+		meta.beginStruct(0)
+		meta.i32(1, parquetPhysicalType(col.Type))
+		meta.i32(3, parquetRequired)
+		meta.str(4, col.Name)
+		switch col.Type {
+		case String:
+			meta.i32(6, parquetConvertedUTF8)
+		case Timestamp:
+			meta.i32(6, parquetConvertedTimestampMillis)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, pw.total)
+	meta.listHeader(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		meta.beginStruct(0)
+		meta.listHeader(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			meta.beginStruct(0)
+			meta.i64(2, c.offset)
+			meta.beginStruct(3)
+			meta.i32(1, parquetPhysicalType(pw.cols[i].Type))
+			meta.i32List(2, []int32{parquetEncodingPlain, parquetEncodingRLE})
+			meta.strList(3, []string{pw.cols[i].Name})
+			meta.i32(4, parquetCodecGzip)
+			meta.i64(5, c.numValues)
+			meta.i64(6, c.uncompressedSize)
+			meta.i64(7, c.compressedSize)
+			meta.i64(9, c.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, g.totalSize)
+		meta.i64(3, g.numRows)
+		meta.endStruct()
+	}
+	meta.str(6, "go-proxy")
+	meta.endStruct()
+
+	if _, err := pw.w.Write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], uint32(meta.buf.Len()))
+	if _, err := pw.w.Write(footer[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(pw.w, parquetMagic)
+	return err
+}
+
+func parquetPhysicalType(t ColumnType) int32 {
+	switch t {
+	case String:
+		return parquetTypeByteArray
+	case Float64:
+		return parquetTypeDouble
+	}
+	return parquetTypeInt64
+}
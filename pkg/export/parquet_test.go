@@ -0,0 +1,301 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// thriftReader 是测试用的 Thrift compact 协议解码器，结构体解码为字段 ID 到值的映射，
+// 与 thriftWriter 相互独立，用于校验写出的元数据。
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic("无效的 varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+		r.pos += 8
+		return v
+	case 8:
+		n := int(r.varint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case 9, 10:
+		h := r.byte()
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.varint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("不支持的 thrift 类型 %d", typ))
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return fields
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+// readParquet 按 Parquet 规范解析文件尾与各数据页，返回列名与按行排列的值，
+// 同时校验行组、列块与页头中的大小和偏移是否一致。
+func readParquet(t *testing.T, file []byte) ([]Column, [][]any, int) {
+	t.Helper()
+	if len(file) < 12 || string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatal("缺少 PAR1 魔数")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	metaStart := len(file) - 8 - metaLen
+	r := &thriftReader{b: file[:len(file)-8], pos: metaStart}
+	meta := r.structure()
+	if r.pos != len(file)-8 {
+		t.Fatalf("元数据长度 = %d，实际解析 %d 字节", metaLen, r.pos-metaStart)
+	}
+	if meta[1] != int64(1) {
+		t.Errorf("version = %v", meta[1])
+	}
+
+	schema := meta[2].([]any)
+	root := schema[0].(map[int16]any)
+	if root[4] != "schema" || root[5] != int64(len(schema)-1) {
+		t.Fatalf("根节点 = %v", root)
+	}
+	var cols []Column
+	for _, s := range schema[1:] {
+		el := s.(map[int16]any)
+		if el[3] != int64(parquetRequired) {
+			t.Errorf("列 %v repetition_type = %v", el[4], el[3])
+		}
+		col := Column{Name: el[4].(string)}
+		switch {
+		case el[1] == int64(parquetTypeByteArray) && el[6] == int64(parquetConvertedUTF8):
+			col.Type = String
+		case el[1] == int64(parquetTypeDouble):
+			col.Type = Float64
+		case el[1] == int64(parquetTypeInt64) && el[6] == int64(parquetConvertedTimestampMillis):
+			col.Type = Timestamp
+		case el[1] == int64(parquetTypeInt64) && el[6] == nil:
+			col.Type = Int64
+		default:
+			t.Fatalf("无法识别的列定义 %v", el)
+		}
+		cols = append(cols, col)
+	}
+
+	var rows [][]any
+	groups := meta[4].([]any)
+	for gi, g := range groups {
+		group := g.(map[int16]any)
+		numRows := int(group[3].(int64))
+		chunks := group[1].([]any)
+		if len(chunks) != len(cols) {
+			t.Fatalf("行组 %d 有 %d 个列块", gi, len(chunks))
+		}
+		groupRows := make([][]any, numRows)
+		for i := range groupRows {
+			groupRows[i] = make([]any, len(cols))
+		}
+		var totalSize int64
+		for ci, c := range chunks {
+			chunk := c.(map[int16]any)
+			cm := chunk[3].(map[int16]any)
+			if !reflect.DeepEqual(cm[3], []any{cols[ci].Name}) || cm[4] != int64(parquetCodecGzip) || cm[5] != int64(numRows) {
+				t.Fatalf("行组 %d 列块 %d 元数据 = %v", gi, ci, cm)
+			}
+			offset := int(cm[9].(int64))
+			if chunk[2] != int64(offset) {
+				t.Errorf("file_offset = %v, data_page_offset = %d", chunk[2], offset)
+			}
+
+			pr := &thriftReader{b: file, pos: offset}
+			page := pr.structure()
+			dph := page[5].(map[int16]any)
+			if page[1] != int64(parquetDataPage) || dph[1] != int64(numRows) || dph[2] != int64(parquetEncodingPlain) {
+				t.Fatalf("页头 = %v", page)
+			}
+			headerLen := pr.pos - offset
+			compressedLen := int(page[3].(int64))
+			if cm[7] != int64(headerLen+compressedLen) || cm[6] != int64(headerLen)+page[2].(int64) {
+				t.Errorf("列块大小 = %v/%v，页头 %d 字节，页大小 %v/%d", cm[6], cm[7], headerLen, page[2], compressedLen)
+			}
+			totalSize += cm[6].(int64)
+
+			zr, err := gzip.NewReader(bytes.NewReader(file[pr.pos : pr.pos+compressedLen]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(data)) != page[2].(int64) {
+				t.Errorf("解压后 %d 字节，页头记录 %v", len(data), page[2])
+			}
+			for i := range numRows {
+				switch cols[ci].Type {
+				case String:
+					n := int(binary.LittleEndian.Uint32(data))
+					groupRows[i][ci] = string(data[4 : 4+n])
+					data = data[4+n:]
+				case Int64:
+					groupRows[i][ci] = int64(binary.LittleEndian.Uint64(data))
+					data = data[8:]
+				case Float64:
+					groupRows[i][ci] = math.Float64frombits(binary.LittleEndian.Uint64(data))
+					data = data[8:]
+				case Timestamp:
+					groupRows[i][ci] = time.UnixMilli(int64(binary.LittleEndian.Uint64(data))).UTC()
+					data = data[8:]
+				}
+			}
+			if len(data) != 0 {
+				t.Errorf("行组 %d 列块 %d 剩余 %d 字节未解码", gi, ci, len(data))
+			}
+		}
+		if group[2] != totalSize {
+			t.Errorf("行组 %d total_byte_size = %v, want %d", gi, group[2], totalSize)
+		}
+		rows = append(rows, groupRows...)
+	}
+	if meta[3] != int64(len(rows)) {
+		t.Errorf("num_rows = %v, 实际 %d 行", meta[3], len(rows))
+	}
+	return cols, rows, len(groups)
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   int
+		groups int
+	}{
+		{name: "empty", rows: 0, groups: 0},
+		{name: "single row group", rows: 10, groups: 1},
+		// 超过行组行数上限时拆分为多个行组
+		{name: "multiple row groups", rows: 2*parquetRowGroupRows + 17, groups: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w, err := NewWriter(FormatParquet, &out, testColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([][]any, tt.rows)
+			for i := range want {
+				row := testRow(i)
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+				// 时间戳以 UTC 毫秒读回
+				row[0] = row[0].(time.Time).UTC()
+				want[i] = row
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			cols, got, groups := readParquet(t, out.Bytes())
+			if !reflect.DeepEqual(cols, testColumns) {
+				t.Errorf("schema = %v, want %v", cols, testColumns)
+			}
+			if groups != tt.groups {
+				t.Errorf("行组数 = %d, want %d", groups, tt.groups)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("读回 %d 行, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Fatalf("第 %d 行 = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// 超过 15 个元素的列表与字段 ID 跨度超过 15 时使用长格式头。
+func TestThriftWriterLongForms(t *testing.T) {
+	var w thriftWriter
+	w.beginStruct(0)
+	w.i32(1, -5)
+	w.i64(20, math.MaxInt64)
+	w.strList(21, make([]string, 16))
+	w.beginStruct(22)
+	w.str(1, "nested")
+	w.endStruct()
+	w.i32(23, 7)
+	w.endStruct()
+
+	r := &thriftReader{b: w.buf.Bytes()}
+	got := r.structure()
+	want := map[int16]any{
+		1:  int64(-5),
+		20: int64(math.MaxInt64),
+		21: make([]any, 16),
+		22: map[int16]any{1: "nested"},
+		23: int64(7),
+	}
+	for i := range want[21].([]any) {
+		want[21].([]any)[i] = ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("解码结果 = %v, want %v", got, want)
+	}
+	if r.pos != w.buf.Len() {
+		t.Errorf("解析 %d 字节, 共 %d 字节", r.pos, w.buf.Len())
+	}
+}
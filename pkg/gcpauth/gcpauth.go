@@ -0,0 +1,210 @@
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenURL = "https://oauth2.googleapis.com/token"
+	defaultScope    = "https://www.googleapis.com/auth/cloud-platform"
+
+	// tokenLifetime 申请的令牌有效期，Google 允许的最大值为 1 小时
+	tokenLifetime = time.Hour
+	// refreshMargin 令牌到期前多久开始刷新
+	refreshMargin = 5 * time.Minute
+)
+
+// serviceAccount 是服务账号 JSON 文件中用到的字段。
+type serviceAccount struct {
+	Type         string `json:"type"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// TokenSource 为一个服务账号提供访问令牌，令牌到期前自动刷新，可并发使用。
+type TokenSource struct {
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURL string
+	scope    string
+	client   *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewTokenSource 读取服务账号文件。tokenURL 为空时使用文件中的 token_uri，
+// scopes 为空时使用 cloud-platform。
+func NewTokenSource(path, tokenURL string, scopes []string) (*TokenSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取服务账号文件失败: %w", err)
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("解析服务账号文件失败: %w", err)
+	}
+	if sa.Type != "" && sa.Type != "service_account" {
+		return nil, fmt.Errorf("不支持的凭据类型 %q，需要 service_account", sa.Type)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("服务账号文件缺少 client_email 或 private_key")
+	}
+	key, err := parsePrivateKey(sa.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenURL == "" {
+		tokenURL = sa.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultTokenURL
+	}
+	if len(scopes) == 0 {
+		scopes = []string{defaultScope}
+	}
+	return &TokenSource{
+		email:    sa.ClientEmail,
+		keyID:    sa.PrivateKeyID,
+		key:      key,
+		tokenURL: tokenURL,
+		scope:    strings.Join(scopes, " "),
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func parsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("private_key 不是有效的 PEM")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private_key 不是 RSA 私钥")
+		}
+		return rsaKey, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析 private_key 失败: %w", err)
+	}
+	return key, nil
+}
+
+// Email 返回服务账号邮箱。
+func (ts *TokenSource) Email() string {
+	return ts.email
+}
+
+// Token 返回有效的访问令牌。临近到期时刷新，刷新失败但旧令牌尚未过期时继续使用旧令牌。
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	now := time.Now()
+	if ts.token != "" && now.Before(ts.expiry.Add(-refreshMargin)) {
+		return ts.token, nil
+	}
+	token, expiry, err := ts.fetch(ctx, now)
+	if err != nil {
+		if ts.token != "" && now.Before(ts.expiry) {
+			return ts.token, nil
+		}
+		return "", err
+	}
+	ts.token, ts.expiry = token, expiry
+	return token, nil
+}
+
+// fetch 签发 JWT 并通过 jwt-bearer 授权方式换取访问令牌。
+func (ts *TokenSource) fetch(ctx context.Context, now time.Time) (string, time.Time, error) {
+	assertion, err := ts.signJWT(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("请求访问令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("令牌接口返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("令牌接口返回数据格式异常")
+	}
+	lifetime := time.Duration(result.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = tokenLifetime
+	}
+	return result.AccessToken, now.Add(lifetime), nil
+}
+
+// signJWT 生成 RS256 签名的授权断言。
+func (ts *TokenSource) signJWT(now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if ts.keyID != "" {
+		header["kid"] = ts.keyID
+	}
+	claims := map[string]any{
+		"iss":   ts.email,
+		"scope": ts.scope,
+		"aud":   ts.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("签名 JWT 失败: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,334 @@
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func pkcs8PEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// writeServiceAccount 写出服务账号文件并返回路径，fields 覆盖默认字段。
+func writeServiceAccount(t *testing.T, fields map[string]any) string {
+	t.Helper()
+	sa := map[string]any{
+		"type":           "service_account",
+		"private_key_id": "kid-1",
+		"private_key":    pkcs8PEM(t, testKey),
+		"client_email":   "proxy@project.iam.gserviceaccount.com",
+	}
+	for k, v := range fields {
+		if v == nil {
+			delete(sa, k)
+		} else {
+			sa[k] = v
+		}
+	}
+	data, err := json.Marshal(sa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewTokenSourceErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		fields map[string]any
+		raw    string
+		want   string
+	}{
+		{name: "invalid json", raw: "{", want: "解析服务账号文件失败"},
+		{name: "authorized user", fields: map[string]any{"type": "authorized_user"}, want: "不支持的凭据类型"},
+		{name: "missing email", fields: map[string]any{"client_email": nil}, want: "缺少 client_email"},
+		{name: "missing key", fields: map[string]any{"private_key": ""}, want: "缺少 client_email 或 private_key"},
+		{name: "not pem", fields: map[string]any{"private_key": "not a key"}, want: "不是有效的 PEM"},
+		{name: "ecdsa key", fields: map[string]any{"private_key": pkcs8PEM(t, ecKey)}, want: "不是 RSA 私钥"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sa.json")
+			if tt.raw != "" {
+				if err := os.WriteFile(path, []byte(tt.raw), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				path = writeServiceAccount(t, tt.fields)
+			}
+			_, err := NewTokenSource(path, "", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewTokenSource(filepath.Join(t.TempDir(), "missing.json"), "", nil); err == nil || !strings.Contains(err.Error(), "读取服务账号文件失败") {
+		t.Errorf("missing file err = %v", err)
+	}
+}
+
+func TestNewTokenSource(t *testing.T) {
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)}))
+	tests := []struct {
+		name      string
+		fields    map[string]any
+		tokenURL  string
+		scopes    []string
+		wantURL   string
+		wantScope string
+	}{
+		{name: "defaults", wantURL: defaultTokenURL, wantScope: defaultScope},
+		{
+			name:      "token_uri from file",
+			fields:    map[string]any{"token_uri": "https://sts.example.com/token"},
+			wantURL:   "https://sts.example.com/token",
+			wantScope: defaultScope,
+		},
+		{
+			name:      "explicit url and scopes win",
+			fields:    map[string]any{"token_uri": "https://sts.example.com/token"},
+			tokenURL:  "http://127.0.0.1:9/token",
+			scopes:    []string{"a", "b"},
+			wantURL:   "http://127.0.0.1:9/token",
+			wantScope: "a b",
+		},
+		{
+			name:      "pkcs1 key without type",
+			fields:    map[string]any{"type": nil, "private_key": pkcs1},
+			wantURL:   defaultTokenURL,
+			wantScope: defaultScope,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := NewTokenSource(writeServiceAccount(t, tt.fields), tt.tokenURL, tt.scopes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts.tokenURL != tt.wantURL || ts.scope != tt.wantScope {
+				t.Errorf("tokenURL = %q, scope = %q", ts.tokenURL, ts.scope)
+			}
+			if ts.Email() != "proxy@project.iam.gserviceaccount.com" || !ts.key.Equal(testKey) {
+				t.Errorf("Email = %q", ts.Email())
+			}
+		})
+	}
+}
+
+// verifyAssertion 校验 JWT 签名并返回头部与声明。
+func verifyAssertion(t *testing.T, assertion string) (header map[string]string, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("assertion = %q", assertion)
+	}
+	enc := base64.RawURLEncoding
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("签名校验失败: %v", err)
+	}
+	for i, v := range []any{&header, &claims} {
+		data, err := enc.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return header, claims
+}
+
+func TestTokenMintsJWT(t *testing.T) {
+	var calls atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("%s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if got := r.FormValue("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q", got)
+		}
+		header, claims := verifyAssertion(t, r.FormValue("assertion"))
+		if header["alg"] != "RS256" || header["typ"] != "JWT" || header["kid"] != "kid-1" {
+			t.Errorf("header = %v", header)
+		}
+		if claims["iss"] != "proxy@project.iam.gserviceaccount.com" || claims["aud"] != srv.URL+"/token" || claims["scope"] != defaultScope {
+			t.Errorf("claims = %v", claims)
+		}
+		iat, _ := claims["iat"].(float64)
+		exp, _ := claims["exp"].(float64)
+		if exp-iat != tokenLifetime.Seconds() || time.Since(time.Unix(int64(iat), 0)) > time.Minute {
+			t.Errorf("iat = %v, exp = %v", iat, exp)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"ya29.test","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+
+	ts, err := NewTokenSource(writeServiceAccount(t, nil), srv.URL+"/token", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "ya29.test" {
+			t.Errorf("token = %q", token)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("令牌接口调用 %d 次，应缓存令牌", n)
+	}
+	if d := time.Until(ts.expiry); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expiry in %v", d)
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		cached    string
+		expiresIn time.Duration
+		status    int
+		body      string
+		want      string
+		wantErr   string
+		wantCalls int32
+		// wantLifetime 非零时检查新令牌的有效期
+		wantLifetime time.Duration
+	}{
+		{
+			name:      "fresh token is reused",
+			cached:    "old",
+			expiresIn: 30 * time.Minute,
+			want:      "old",
+		},
+		{
+			name:      "refresh inside margin",
+			cached:    "old",
+			expiresIn: time.Minute,
+			status:    http.StatusOK,
+			body:      `{"access_token":"new","expires_in":3600}`,
+			want:      "new",
+			wantCalls: 1,
+		},
+		{
+			name:      "refresh failure keeps unexpired token",
+			cached:    "old",
+			expiresIn: time.Minute,
+			status:    http.StatusServiceUnavailable,
+			body:      "unavailable",
+			want:      "old",
+			wantCalls: 1,
+		},
+		{
+			name:      "refresh failure after expiry",
+			cached:    "old",
+			expiresIn: -time.Minute,
+			status:    http.StatusBadRequest,
+			body:      `{"error":"invalid_grant"}` + "\n",
+			wantErr:   `令牌接口返回 400: {"error":"invalid_grant"}`,
+			wantCalls: 1,
+		},
+		{
+			name:      "missing access_token",
+			status:    http.StatusOK,
+			body:      `{"expires_in":3600}`,
+			wantErr:   "令牌接口返回数据格式异常",
+			wantCalls: 1,
+		},
+		{
+			name:         "missing expires_in uses default lifetime",
+			status:       http.StatusOK,
+			body:         `{"access_token":"new"}`,
+			want:         "new",
+			wantCalls:    1,
+			wantLifetime: tokenLifetime,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ts, err := NewTokenSource(writeServiceAccount(t, nil), srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.cached != "" {
+				ts.token, ts.expiry = tt.cached, time.Now().Add(tt.expiresIn)
+			}
+
+			got, err := ts.Token(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if got != tt.want {
+				t.Errorf("token = %q, want %q", got, tt.want)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("令牌接口调用 %d 次, want %d", n, tt.wantCalls)
+			}
+			if tt.wantLifetime != 0 {
+				if d := time.Until(ts.expiry); d < tt.wantLifetime-time.Minute || d > tt.wantLifetime {
+					t.Errorf("expiry in %v", d)
+				}
+			}
+		})
+	}
+}
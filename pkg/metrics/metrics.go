@@ -60,6 +60,78 @@ var (
 		[]string{"service"},
 	)
 
+	// ModelRouteTotal 统一入口按模型路由的请求数
+	ModelRouteTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "goproxy_model_route_total",
+			Help: "统一入口按模型路由的请求数",
+		},
+		[]string{"service", "model"},
+	)
+
+	// RateLimitRejectedTotal 因限流被拒绝的请求数
+	RateLimitRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "goproxy_ratelimit_rejected_total",
+			Help: "因限流被拒绝的请求数",
+		},
+		[]string{"service", "scope"},
+	)
+
+	// PIIFindingsTotal 出站请求中检测到的个人信息数量
+	PIIFindingsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "goproxy_pii_findings_total",
+			Help: "出站请求中检测到的个人信息数量",
+		},
+		[]string{"service", "rule", "action"},
+	)
+
+	// TokensTotal 上游响应中记录的 token 用量
+	TokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "goproxy_tokens_total",
+			Help: "上游响应中记录的 token 用量",
+		},
+		[]string{"service", "type"},
+	)
+
+	// CostTotal 按价格表计算的累计费用
+	CostTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "goproxy_cost_total",
+			Help: "按价格表计算的累计费用",
+		},
+		[]string{"service"},
+	)
+
+	// BudgetAlertsTotal 发出的预算通知次数
+	BudgetAlertsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "goproxy_budget_alerts_total",
+			Help: "发出的预算通知次数",
+		},
+		[]string{"scope", "level"},
+	)
+
+	// Balance 定时查询得到的账户余额
+	Balance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "goproxy_balance",
+			Help: "定时查询得到的账户余额",
+		},
+		[]string{"account", "currency"},
+	)
+
+	// BalanceDaysUntilEmpty 按近期消耗速度预计的余额可用天数
+	BalanceDaysUntilEmpty = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "goproxy_balance_days_until_empty",
+			Help: "按近期消耗速度预计的余额可用天数",
+		},
+		[]string{"account"},
+	)
+
 	// ========================================
 	// 第三类：内部组件指标
 	// ========================================
@@ -106,6 +178,14 @@ func Register() {
 		HttpResponseSize,
 		UpstreamErrorsTotal,
 		ActiveRequests,
+		ModelRouteTotal,
+		RateLimitRejectedTotal,
+		PIIFindingsTotal,
+		TokensTotal,
+		CostTotal,
+		BudgetAlertsTotal,
+		Balance,
+		BalanceDaysUntilEmpty,
 		StatsChannelUsage,
 		StatsChannelDrops,
 		StatsBatchProcessTotal,
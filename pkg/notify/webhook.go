@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-proxy/pkg/redact"
+)
+
+const (
+	webhookTimeout    = 10 * time.Second
+	webhookMaxRetries = 3
+)
+
+var client = &http.Client{Timeout: webhookTimeout}
+
+// Event 是发送到 webhook 的通知内容，Data 为事件相关的详细字段。
+type Event struct {
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// Post 以 JSON 形式发送事件，失败时按指数退避重试，非 2xx 响应视为失败。
+func Post(ctx context.Context, url string, event Event) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= webhookMaxRetries; attempt++ {
+		lastErr = post(ctx, url, body)
+		if lastErr == nil {
+			return nil
+		}
+		if attempt < webhookMaxRetries {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt*attempt) * 500 * time.Millisecond):
+			}
+		}
+	}
+	return lastErr
+}
+
+func post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-proxy-webhook")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Send 在后台发送事件，url 为空时忽略，发送失败只记录日志。
+func Send(url string, event Event) {
+	if url == "" {
+		return
+	}
+	go func() {
+		if err := Post(context.Background(), url, event); err != nil {
+			log.Printf("发送 webhook 通知 (%s) 失败: %s", event.Type, redact.Text(err.Error()))
+		}
+	}()
+}
@@ -0,0 +1,203 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go-proxy/pkg/config"
+)
+
+// Action 是命中规则后的处理方式。
+type Action string
+
+const (
+	ActionMask  Action = "mask"  // 替换为 [PII:规则名] 后转发
+	ActionBlock Action = "block" // 拒绝整个请求
+	ActionLog   Action = "log"   // 只记录
+)
+
+// builtinPatterns 内置规则，未配置 rules 时全部启用
+var builtinPatterns = map[string]string{
+	"email": `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	// 中国大陆手机号与带国家码的国际号码
+	"phone": `(?:\+?86[\s-]?)?\b1[3-9]\d{9}\b|\+\d{1,3}[\s-]?\(?\d{1,4}\)?[\s-]?\d{3,4}[\s-]?\d{3,4}\b`,
+	// 中国大陆居民身份证号
+	"id_card": `\b[1-9]\d{5}(?:19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`,
+	// 美国社会安全号
+	"ssn": `\b\d{3}-\d{2}-\d{4}\b`,
+}
+
+// builtinOrder 保证未配置 rules 时的检测顺序固定
+var builtinOrder = []string{"email", "phone", "id_card", "ssn"}
+
+// textKeys 承载用户文本的 JSON 字段，覆盖 OpenAI、Anthropic 与 Gemini 的请求格式。
+// 只检测这些字段下的字符串，避免误伤模型名、图片数据等
+var textKeys = map[string]bool{
+	"content":      true,
+	"text":         true,
+	"prompt":       true,
+	"input":        true,
+	"system":       true,
+	"instructions": true,
+}
+
+type rule struct {
+	name   string
+	re     *regexp.Regexp
+	action Action
+}
+
+// Detector 按规则检测并处理 JSON 请求体中的个人信息。
+type Detector struct {
+	rules []rule
+}
+
+// Finding 是某条规则在一次请求中的命中情况。
+type Finding struct {
+	Rule   string
+	Action Action
+	Count  int
+}
+
+// Result 是一次检测的结果。Body 仅在 Masked 为 true 时有值，为替换后的请求体。
+type Result struct {
+	Findings []Finding
+	Blocked  bool
+	Masked   bool
+	Body     []byte
+}
+
+func parseAction(s string, def Action) (Action, error) {
+	switch Action(strings.ToLower(s)) {
+	case "":
+		return def, nil
+	case ActionMask:
+		return ActionMask, nil
+	case ActionBlock:
+		return ActionBlock, nil
+	case ActionLog:
+		return ActionLog, nil
+	}
+	return "", fmt.Errorf("不支持的处理方式 %q，可选 mask、block、log", s)
+}
+
+// NewDetector 根据配置编译检测规则，未配置规则时启用全部内置规则。
+func NewDetector(cfg config.PIIConfig) (*Detector, error) {
+	defAction, err := parseAction(cfg.Action, ActionLog)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		for _, name := range builtinOrder {
+			rules = append(rules, config.PIIRule{Name: name})
+		}
+	}
+
+	d := &Detector{}
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("PII 规则缺少 name")
+		}
+		action, err := parseAction(r.Action, defAction)
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s: %w", r.Name, err)
+		}
+		pattern := r.Pattern
+		switch {
+		case pattern != "":
+		case len(r.Words) > 0:
+			words := make([]string, 0, len(r.Words))
+			for _, w := range r.Words {
+				if w = strings.TrimSpace(w); w != "" {
+					words = append(words, regexp.QuoteMeta(w))
+				}
+			}
+			pattern = `(?i)(?:` + strings.Join(words, "|") + `)`
+		default:
+			builtin, ok := builtinPatterns[r.Name]
+			if !ok {
+				return nil, fmt.Errorf("规则 %s 未配置 pattern 或 words，且不是内置规则", r.Name)
+			}
+			pattern = builtin
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s 的正则无效: %w", r.Name, err)
+		}
+		d.rules = append(d.rules, rule{name: r.Name, re: re, action: action})
+	}
+	return d, nil
+}
+
+// Scan 检测 JSON 请求体，body 不是合法 JSON 时返回错误。
+func (d *Detector) Scan(body []byte) (*Result, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	counts := make([]int, len(d.rules))
+	res := &Result{}
+	doc = d.walk(doc, false, counts, res)
+
+	for i, r := range d.rules {
+		if counts[i] == 0 {
+			continue
+		}
+		res.Findings = append(res.Findings, Finding{Rule: r.name, Action: r.action, Count: counts[i]})
+		if r.action == ActionBlock {
+			res.Blocked = true
+		}
+	}
+	if res.Masked && !res.Blocked {
+		out, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		res.Body = out
+	} else {
+		res.Masked = false
+	}
+	return res, nil
+}
+
+// walk 遍历 JSON 文档，inText 表示当前值位于承载文本的字段下。
+func (d *Detector) walk(node any, inText bool, counts []int, res *Result) any {
+	switch v := node.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = d.walk(child, textKeys[k], counts, res)
+		}
+	case []any:
+		for i := range v {
+			v[i] = d.walk(v[i], inText, counts, res)
+		}
+	case string:
+		if inText {
+			return d.scanString(v, counts, res)
+		}
+	}
+	return node
+}
+
+func (d *Detector) scanString(s string, counts []int, res *Result) string {
+	for i, r := range d.rules {
+		matches := r.re.FindAllStringIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		counts[i] += len(matches)
+		if r.action == ActionMask {
+			s = r.re.ReplaceAllLiteralString(s, "[PII:"+r.name+"]")
+			res.Masked = true
+		}
+	}
+	return s
+}
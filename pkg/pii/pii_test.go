@@ -0,0 +1,201 @@
+package pii
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-proxy/pkg/config"
+)
+
+// chatBody 覆盖 OpenAI 消息的字符串与多模态内容，以及不应检测的字段。
+const chatBody = `{
+	"model": "ops@example.com",
+	"temperature": 0.70,
+	"user": "alice@example.com",
+	"messages": [
+		{"role": "system", "content": "Escalate to 13812345678."},
+		{"role": "user", "content": [
+			{"type": "text", "text": "I am bob@example.com, SSN 123-45-6789, ID 11010519491231002X, project Falcon."},
+			{"type": "image_url", "image_url": {"url": "https://example.com/a@b.co.png"}}
+		]}
+	]
+}`
+
+func mustJSON(t *testing.T, data []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("无法解析 JSON %s: %v", data, err)
+	}
+	return v
+}
+
+func TestDetectorScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.PIIConfig
+		body     string
+		findings []Finding
+		blocked  bool
+		want     string // 非空时为替换后的请求体
+	}{
+		{
+			name: "builtin rules log by default",
+			cfg:  config.PIIConfig{},
+			body: chatBody,
+			findings: []Finding{
+				{Rule: "email", Action: ActionLog, Count: 1},
+				{Rule: "phone", Action: ActionLog, Count: 1},
+				{Rule: "id_card", Action: ActionLog, Count: 1},
+				{Rule: "ssn", Action: ActionLog, Count: 1},
+			},
+		},
+		{
+			name: "mask only text fields",
+			cfg:  config.PIIConfig{Action: "MASK", Rules: []config.PIIRule{{Name: "email"}, {Name: "ssn"}}},
+			body: chatBody,
+			findings: []Finding{
+				{Rule: "email", Action: ActionMask, Count: 1},
+				{Rule: "ssn", Action: ActionMask, Count: 1},
+			},
+			want: strings.NewReplacer(
+				"bob@example.com", "[PII:email]",
+				"123-45-6789", "[PII:ssn]",
+			).Replace(chatBody),
+		},
+		{
+			name: "block wins over mask",
+			cfg: config.PIIConfig{Action: "mask", Rules: []config.PIIRule{
+				{Name: "email"},
+				{Name: "codename", Words: []string{" falcon ", ""}, Action: "block"},
+			}},
+			body: chatBody,
+			findings: []Finding{
+				{Rule: "email", Action: ActionMask, Count: 1},
+				{Rule: "codename", Action: ActionBlock, Count: 1},
+			},
+			blocked: true,
+		},
+		{
+			name: "custom pattern in anthropic and gemini fields",
+			cfg:  config.PIIConfig{Rules: []config.PIIRule{{Name: "employee_id", Pattern: `EMP-\d{6}`, Action: "mask"}}},
+			body: `{"system":"EMP-000001 is on call","contents":[{"parts":[{"text":"ask EMP-123456"}]}],"metadata":{"user_id":"EMP-999999"}}`,
+			findings: []Finding{
+				{Rule: "employee_id", Action: ActionMask, Count: 2},
+			},
+			want: `{"system":"[PII:employee_id] is on call","contents":[{"parts":[{"text":"ask [PII:employee_id]"}]}],"metadata":{"user_id":"EMP-999999"}}`,
+		},
+		{
+			name: "no findings",
+			cfg:  config.PIIConfig{Action: "block"},
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDetector(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := d.Scan([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Findings, tt.findings) {
+				t.Errorf("Findings = %+v, want %+v", res.Findings, tt.findings)
+			}
+			if res.Blocked != tt.blocked {
+				t.Errorf("Blocked = %v, want %v", res.Blocked, tt.blocked)
+			}
+			if res.Masked != (tt.want != "") {
+				t.Fatalf("Masked = %v", res.Masked)
+			}
+			if tt.want == "" {
+				if res.Body != nil {
+					t.Errorf("未替换时 Body = %s", res.Body)
+				}
+				return
+			}
+			if !reflect.DeepEqual(mustJSON(t, res.Body), mustJSON(t, []byte(tt.want))) {
+				t.Errorf("Body =\n  %s\nwant\n  %s", res.Body, tt.want)
+			}
+		})
+	}
+}
+
+// 替换后重新编码时数字保持原样，不会变成 0.7 或科学计数法。
+func TestDetectorScanKeepsNumbers(t *testing.T) {
+	d, err := NewDetector(config.PIIConfig{Action: "mask", Rules: []config.PIIRule{{Name: "email"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.Scan([]byte(`{"temperature":0.70,"seed":12345678901234567890,"prompt":"mail a@b.io"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"temperature":0.70`, `"seed":12345678901234567890`, `"prompt":"mail [PII:email]"`} {
+		if !strings.Contains(string(res.Body), want) {
+			t.Errorf("Body %s 缺少 %s", res.Body, want)
+		}
+	}
+}
+
+func TestNewDetectorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.PIIConfig
+		want string
+	}{
+		{name: "bad default action", cfg: config.PIIConfig{Action: "drop"}, want: "不支持的处理方式"},
+		{name: "bad rule action", cfg: config.PIIConfig{Rules: []config.PIIRule{{Name: "email", Action: "drop"}}}, want: "规则 email"},
+		{name: "missing name", cfg: config.PIIConfig{Rules: []config.PIIRule{{Pattern: "x"}}}, want: "缺少 name"},
+		{name: "unknown builtin", cfg: config.PIIConfig{Rules: []config.PIIRule{{Name: "passport"}}}, want: "不是内置规则"},
+		{name: "bad pattern", cfg: config.PIIConfig{Rules: []config.PIIRule{{Name: "x", Pattern: "("}}}, want: "正则无效"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDetector(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewDetector error = %v, want 包含 %q", err, tt.want)
+			}
+		})
+	}
+
+	d, _ := NewDetector(config.PIIConfig{})
+	if _, err := d.Scan([]byte("not json")); err == nil {
+		t.Error("非 JSON 请求体应返回错误")
+	}
+}
+
+func TestBuiltinPatterns(t *testing.T) {
+	tests := []struct {
+		rule  string
+		match []string
+		miss  []string
+	}{
+		{rule: "email", match: []string{"a.b+c@mail.example.org"}, miss: []string{"user@localhost", "@example.com"}},
+		{rule: "phone", match: []string{"13812345678", "+86 13812345678", "+1 (415) 555-0132"}, miss: []string{"12812345678", "138123456789"}},
+		{rule: "id_card", match: []string{"11010519491231002X", "440308199901010012"}, miss: []string{"110105194913310021", "11010519491231002"}},
+		{rule: "ssn", match: []string{"078-05-1120"}, miss: []string{"078-05-11201", "078051120"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			d, err := NewDetector(config.PIIConfig{Rules: []config.PIIRule{{Name: tt.rule}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			re := d.rules[0].re
+			for _, s := range tt.match {
+				if !re.MatchString(s) {
+					t.Errorf("%s 未匹配 %q", tt.rule, s)
+				}
+			}
+			for _, s := range tt.miss {
+				if re.MatchString(s) {
+					t.Errorf("%s 误匹配 %q", tt.rule, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-proxy/pkg/config"
+)
+
+const defaultAzureAPIVersion = "2024-10-21"
+
+// azureAdapter 将 OpenAI 风格的请求改写为 Azure OpenAI 的部署路径：
+// /v1/chat/completions + {"model":"gpt-4o"} -> /openai/deployments/{部署}/chat/completions?api-version=...
+type azureAdapter struct {
+	apiVersion  string
+	deployments map[string]string
+	fallback    string
+}
+
+func newAzureAdapter(cfg config.AzureConfig) *azureAdapter {
+	a := &azureAdapter{
+		apiVersion:  cfg.APIVersion,
+		deployments: make(map[string]string, len(cfg.Deployments)),
+	}
+	if a.apiVersion == "" {
+		a.apiVersion = defaultAzureAPIVersion
+	}
+	for model, deployment := range cfg.Deployments {
+		if model == "*" {
+			a.fallback = deployment
+			continue
+		}
+		a.deployments[strings.ToLower(model)] = deployment
+	}
+	return a
+}
+
+// deployment 返回模型对应的部署名，未配置映射时使用默认部署或模型名本身。
+func (a *azureAdapter) deployment(model string) string {
+	if d, ok := a.deployments[strings.ToLower(model)]; ok {
+		return d
+	}
+	if a.fallback != "" {
+		return a.fallback
+	}
+	return model
+}
+
+// rewrite 改写请求路径、追加 api-version 并把 Bearer 凭据转换为 api-key 请求头。
+// basePath 为目标地址的路径，relativePath 为去掉代理前缀后的路径。
+func (a *azureAdapter) rewrite(req *http.Request, basePath, relativePath string) {
+	op := strings.TrimPrefix(relativePath, "/v1")
+	switch {
+	case strings.HasPrefix(relativePath, "/openai/"):
+		// 已是 Azure 格式的路径，原样转发
+		op = relativePath
+	case op == "/models":
+		op = "/openai/models"
+	default:
+		model := a.deployment(requestModel(req))
+		if model != "" {
+			op = "/openai/deployments/" + url.PathEscape(model) + op
+		}
+	}
+	req.URL.Path = basePath + op
+	req.URL.RawPath = ""
+
+	query := req.URL.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", a.apiVersion)
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if req.Header.Get("api-key") == "" {
+		if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			req.Header.Set("api-key", token)
+		}
+	}
+	req.Header.Del("Authorization")
+}
+
+// requestModel 读取 JSON 请求体中的 model 字段，并恢复请求体供后续转发。
+// 超过 maxTranslateBodySize 的请求体不解析，已读取部分与剩余部分拼接后原样转发。
+func requestModel(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody || !strings.Contains(req.Header.Get("Content-Type"), "json") {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxTranslateBodySize+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+	if err != nil || len(body) > maxTranslateBodySize {
+		return ""
+	}
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return payload.Model
+}
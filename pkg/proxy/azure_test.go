@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestModel(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	if got := requestModel(req); got != "gpt-4o" {
+		t.Errorf("requestModel = %q, want gpt-4o", got)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"model":"gpt-4o","messages":[]}` {
+		t.Errorf("请求体未恢复: %s", body)
+	}
+}
+
+// 超过读取上限的请求体不解析 model，但必须完整转发。
+func TestRequestModelOversizedBody(t *testing.T) {
+	payload := append([]byte(`{"model":"gpt-4o","input":"`), bytes.Repeat([]byte("a"), maxTranslateBodySize+1024)...)
+	payload = append(payload, `"}`...)
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+
+	if got := requestModel(req); got != "" {
+		t.Errorf("requestModel = %q, want 空", got)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, payload) {
+		t.Errorf("转发的请求体长度 = %d, want %d", len(body), len(payload))
+	}
+}
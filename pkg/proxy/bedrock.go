@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/sigv4"
+
+	"github.com/labstack/gommon/log"
+)
+
+// maxSignedBodySize 签名时需要完整读取请求体计算哈希，超过该大小的请求不签名
+const maxSignedBodySize = 32 << 20
+
+// newBedrockSigner 根据配置创建 SigV4 签名器，未填写的字段从 AWS 标准环境变量读取。
+func newBedrockSigner(cfg config.AWSConfig) (*sigv4.Signer, error) {
+	creds := sigv4.Credentials{
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+	}
+	if creds.AccessKeyID == "" && creds.SecretAccessKey == "" {
+		creds.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		creds.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		creds.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("未配置 AWS 访问凭据")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("未配置 AWS 区域")
+	}
+	service := cfg.Service
+	if service == "" {
+		service = "bedrock"
+	}
+	return sigv4.NewSigner(creds, region, service), nil
+}
+
+// signBedrockRequest 移除客户端凭据并为请求签名。请求体会被完整读取以计算哈希，
+// 流式接口（invoke-with-response-stream、converse-stream）的请求体同样是普通 JSON。
+func signBedrockRequest(req *http.Request, cfg config.ProxyConfig, signer *sigv4.Signer) {
+	req.Header.Del("x-api-key")
+	req.Header.Del("x-goog-api-key")
+
+	payloadHash := sigv4.EmptyPayloadHash
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxSignedBodySize+1))
+		req.Body.Close()
+		if err != nil || len(body) > maxSignedBodySize {
+			req.Header.Del("Authorization")
+			req.Body = http.NoBody
+			req.ContentLength = 0
+			metrics.UpstreamErrorsTotal.WithLabelValues(cfg.Path, "sign").Inc()
+			log.Printf("代理 %s 读取待签名的请求体失败或请求体过大，请求未签名", cfg.Path)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		payloadHash = sigv4.HashPayload(body)
+	}
+	signer.Sign(req, payloadHash, time.Now())
+}
@@ -7,25 +7,59 @@ import (
 	"strings"
 
 	"go-proxy/pkg/config"
+	"go-proxy/pkg/gcpauth"
 	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/redact"
+	"go-proxy/pkg/sigv4"
+	"go-proxy/pkg/translate"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 )
 
 type ReverseProxy struct {
-	proxy   *httputil.ReverseProxy
-	service string // 代理路径，用作指标标签
+	proxy      *httputil.ReverseProxy
+	service    string               // 代理路径，用作指标标签
+	prefix     string               // 代理路径前缀
+	translator translate.Translator // 协议转换器，未配置 translate 时为 nil
 }
 
 func NewReverseProxy(cfg config.ProxyConfig) *ReverseProxy {
 	targetURL, _ := url.Parse(cfg.Target)
-	log.Printf("Creating reverse proxy for %s", cfg.Target)
+	log.Printf("Creating reverse proxy for %s", redact.Text(cfg.Target))
 	// 创建一个反向代理
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	// 处理路径
 	pathPrefix := cfg.Path
 
+	// 服务账号令牌
+	var tokens *gcpauth.TokenSource
+	if cfg.ServiceAccount != "" {
+		var err error
+		tokens, err = gcpauth.NewTokenSource(cfg.ServiceAccount, cfg.TokenURL, cfg.Scopes)
+		if err != nil {
+			log.Printf("代理 %s 的服务账号配置无效，已忽略: %v", cfg.Path, err)
+		} else {
+			log.Printf("代理 %s 使用服务账号 %s 访问上游", cfg.Path, tokens.Email())
+		}
+	}
+
+	// Bedrock SigV4 签名
+	var signer *sigv4.Signer
+	if strings.EqualFold(cfg.Vendor, "bedrock") {
+		var err error
+		signer, err = newBedrockSigner(cfg.AWS)
+		if err != nil {
+			log.Printf("代理 %s 的 AWS 签名配置无效，请求将不签名: %v", cfg.Path, err)
+		}
+	}
+
+	// Azure OpenAI 部署映射
+	var azure *azureAdapter
+	if strings.EqualFold(cfg.Vendor, "azure") {
+		azure = newAzureAdapter(cfg.Azure)
+	}
+
 	// 自定义 Director 函数来修改请求头
 	proxy.Director = func(req *http.Request) {
 		req.URL.Scheme = targetURL.Scheme
@@ -34,9 +68,27 @@ func NewReverseProxy(cfg config.ProxyConfig) *ReverseProxy {
 
 		// 移除路径前缀，保留目标 URL 的完整路径
 		relativePath := strings.TrimPrefix(req.URL.Path, pathPrefix)
+		if state, ok := req.Context().Value(translateCtxKey{}).(*translateState); ok {
+			// 格式转换后的请求改用上游原生端点
+			relativePath = state.path
+			req.URL.RawPath = ""
+			req.URL.RawQuery = state.rawQuery
+		}
 		req.URL.Path = targetURL.Path + relativePath
+		if azure != nil {
+			azure.rewrite(req, targetURL.Path, relativePath)
+		}
+
+		// 注入 SigV4 签名、服务账号令牌或服务端保存的上游密钥，签名需在其他修改完成后进行
+		if signer != nil {
+			signBedrockRequest(req, cfg, signer)
+		} else if tokens != nil {
+			applyServiceAccountToken(req, cfg, tokens)
+		} else if cfg.APIKey != "" {
+			applyUpstreamKey(req, cfg)
+		}
 
-		log.Printf("Forwarding request to %s%s", req.URL.Host, req.URL.RequestURI()) // 记录转发的请求
+		log.Printf("Forwarding request to %s%s", req.URL.Host, redact.Text(req.URL.RequestURI())) // 记录转发的请求
 	}
 
 	// 自定义 ErrorHandler 记录上游错误指标
@@ -52,25 +104,77 @@ func NewReverseProxy(cfg config.ProxyConfig) *ReverseProxy {
 			errorType = "dns_error"
 		}
 		metrics.UpstreamErrorsTotal.WithLabelValues(cfg.Path, errorType).Inc()
-		log.Printf("Upstream error for %s: %s (%s)", cfg.Path, err.Error(), errorType)
+		log.Printf("Upstream error for %s: %s (%s)", cfg.Path, redact.Text(err.Error()), errorType)
 		w.WriteHeader(http.StatusBadGateway)
 	}
 
-	return &ReverseProxy{proxy: proxy, service: cfg.Path}
+	rp := &ReverseProxy{proxy: proxy, service: cfg.Path, prefix: pathPrefix}
+
+	if cfg.Translate != "" {
+		translator, err := translate.New(cfg.Translate, cfg.Vendor)
+		if err != nil {
+			log.Printf("代理 %s 的协议转换配置无效，已忽略: %v", cfg.Path, err)
+		} else {
+			rp.translator = translator
+			proxy.ModifyResponse = modifyTranslatedResponse
+			log.Printf("代理 %s 已启用 %s -> %s 协议转换", cfg.Path, cfg.Translate, cfg.Vendor)
+		}
+	}
+
+	return rp
+}
+
+// applyUpstreamKey 按厂商约定的请求头注入上游密钥，覆盖客户端携带的凭据。
+func applyUpstreamKey(req *http.Request, cfg config.ProxyConfig) {
+	switch strings.ToLower(cfg.Vendor) {
+	case "anthropic":
+		req.Header.Del("Authorization")
+		req.Header.Set("x-api-key", cfg.APIKey)
+	case "google", "gemini":
+		req.Header.Del("Authorization")
+		req.Header.Set("x-goog-api-key", cfg.APIKey)
+	case "azure":
+		req.Header.Del("Authorization")
+		req.Header.Set("api-key", cfg.APIKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+}
+
+// applyServiceAccountToken 注入服务账号的访问令牌，替换客户端携带的凭据。
+// 获取令牌失败时移除客户端凭据继续转发，由上游返回 401。
+func applyServiceAccountToken(req *http.Request, cfg config.ProxyConfig, tokens *gcpauth.TokenSource) {
+	req.Header.Del("x-goog-api-key")
+	req.Header.Del("x-api-key")
+	token, err := tokens.Token(req.Context())
+	if err != nil {
+		req.Header.Del("Authorization")
+		metrics.UpstreamErrorsTotal.WithLabelValues(cfg.Path, "auth_token").Inc()
+		log.Printf("代理 %s 获取服务账号访问令牌失败: %s", cfg.Path, redact.Text(err.Error()))
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
 }
 
 func (p *ReverseProxy) Handler(c echo.Context) error {
 	// 在请求开始时记录基本信息
-	log.Printf("Received request: %s %s from %s", c.Request().Method, c.Request().URL.RequestURI(), c.Request().RemoteAddr)
+	log.Printf("Received request: %s %s from %s", c.Request().Method, redact.Text(c.Request().URL.RequestURI()), c.Request().RemoteAddr)
 
 	metrics.ActiveRequests.WithLabelValues(p.service).Inc()
 	defer metrics.ActiveRequests.WithLabelValues(p.service).Dec()
 
 	// 处理请求
-	p.proxy.ServeHTTP(c.Response(), c.Request())
+	relativePath := strings.TrimPrefix(c.Request().URL.Path, p.prefix)
+	if p.translator != nil && c.Request().Method == http.MethodPost && translate.IsChatCompletionsPath(relativePath) {
+		if err := p.handleTranslated(c); err != nil {
+			return err
+		}
+	} else {
+		p.proxy.ServeHTTP(c.Response(), c.Request())
+	}
 
 	// 在请求结束后记录状态码
-	log.Printf("Request completed: %s %s, status: %d", c.Request().Method, c.Request().URL.RequestURI(), c.Response().Status)
+	log.Printf("Request completed: %s %s, status: %d", c.Request().Method, redact.Text(c.Request().URL.RequestURI()), c.Response().Status)
 
 	return nil
 }
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-proxy/pkg/config"
+	"go-proxy/pkg/metrics"
+	"go-proxy/pkg/redact"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	defaultModelsCacheTTL = 300 * time.Second
+	defaultModelsTimeout  = 10 * time.Second
+)
+
+// Model 是 OpenAI 模型列表中的一项。
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ModelSourceError 记录某个上游获取模型列表失败的原因。
+type ModelSourceError struct {
+	Proxy string `json:"proxy"`
+	Error string `json:"error"`
+}
+
+// ModelList 是聚合后的模型列表，Errors 为非标准扩展字段，OpenAI 客户端会忽略它。
+type ModelList struct {
+	Object string             `json:"object"`
+	Data   []Model            `json:"data"`
+	Errors []ModelSourceError `json:"errors,omitempty"`
+}
+
+type modelsCacheEntry struct {
+	list    *ModelList
+	expires time.Time
+}
+
+// ModelAggregator 并发拉取各个 OpenAI 兼容上游的模型列表并合并。
+type ModelAggregator struct {
+	cfg     config.ModelsConfig
+	proxies []config.ProxyConfig
+	client  *http.Client
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]modelsCacheEntry // 键为客户端凭据的摘要，避免不同客户端共享结果
+}
+
+// NewModelAggregator 根据配置选出参与聚合的代理。
+func NewModelAggregator(cfg config.ModelsConfig, proxies []config.ProxyConfig) *ModelAggregator {
+	selected := make([]config.ProxyConfig, 0, len(proxies))
+	if len(cfg.Proxies) > 0 {
+		wanted := make(map[string]bool, len(cfg.Proxies))
+		for _, p := range cfg.Proxies {
+			wanted[p] = true
+		}
+		for _, p := range proxies {
+			if wanted[p.Path] {
+				selected = append(selected, p)
+			}
+		}
+	} else {
+		for _, p := range proxies {
+			if p.IsOpenAICompatible() {
+				selected = append(selected, p)
+			}
+		}
+	}
+
+	ttl := defaultModelsCacheTTL
+	if cfg.CacheTTL > 0 {
+		ttl = time.Duration(cfg.CacheTTL) * time.Second
+	}
+	timeout := defaultModelsTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	return &ModelAggregator{
+		cfg:     cfg,
+		proxies: selected,
+		client:  &http.Client{Timeout: timeout},
+		ttl:     ttl,
+		cache:   make(map[string]modelsCacheEntry),
+	}
+}
+
+// List 返回聚合后的模型列表。clientAuth 为客户端的 Authorization 头，
+// 仅在代理未配置 api_key 时转发给上游。
+func (a *ModelAggregator) List(ctx context.Context, clientAuth string) *ModelList {
+	sum := sha256.Sum256([]byte(clientAuth))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	a.mu.Lock()
+	if entry, ok := a.cache[cacheKey]; ok && time.Now().Before(entry.expires) {
+		a.mu.Unlock()
+		return entry.list
+	}
+	a.mu.Unlock()
+
+	type result struct {
+		proxy  config.ProxyConfig
+		models []Model
+		err    error
+	}
+	results := make([]result, len(a.proxies))
+	var wg sync.WaitGroup
+	for i, p := range a.proxies {
+		wg.Add(1)
+		go func(i int, p config.ProxyConfig) {
+			defer wg.Done()
+			models, err := a.fetch(ctx, p, clientAuth)
+			results[i] = result{proxy: p, models: models, err: err}
+		}(i, p)
+	}
+	wg.Wait()
+
+	list := &ModelList{Object: "list", Data: []Model{}}
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.err != nil {
+			errMsg := redact.Text(r.err.Error())
+			log.Printf("获取 %s 模型列表失败: %s", r.proxy.Path, errMsg)
+			metrics.UpstreamErrorsTotal.WithLabelValues(r.proxy.Path, "models_fetch").Inc()
+			list.Errors = append(list.Errors, ModelSourceError{Proxy: r.proxy.Path, Error: errMsg})
+			continue
+		}
+		source := strings.TrimPrefix(r.proxy.Path, "/")
+		for _, m := range r.models {
+			id := m.ID
+			if a.cfg.Prefix {
+				id = source + "/" + id
+			}
+			if alias, ok := a.cfg.Aliases[id]; ok {
+				id = alias
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			m.ID = id
+			m.Object = "model"
+			if m.OwnedBy == "" {
+				m.OwnedBy = source
+			}
+			list.Data = append(list.Data, m)
+		}
+	}
+	sort.Slice(list.Data, func(i, j int) bool { return list.Data[i].ID < list.Data[j].ID })
+
+	// 全部上游失败时不缓存，便于尽快恢复
+	if len(list.Errors) < len(a.proxies) || len(a.proxies) == 0 {
+		a.mu.Lock()
+		a.cache[cacheKey] = modelsCacheEntry{list: list, expires: time.Now().Add(a.ttl)}
+		a.mu.Unlock()
+	}
+	return list
+}
+
+// fetch 请求单个上游的 /v1/models。
+func (a *ModelAggregator) fetch(ctx context.Context, p config.ProxyConfig, clientAuth string) ([]Model, error) {
+	endpoint := strings.TrimSuffix(p.Target, "/") + "/v1/models"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	} else if clientAuth != "" {
+		req.Header.Set("Authorization", clientAuth)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("上游返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var list struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("无法解析模型列表: %w", err)
+	}
+	return list.Data, nil
+}
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go-proxy/pkg/config"
+
+	"github.com/labstack/gommon/log"
+)
+
+// RouteDecision 描述一次模型路由的结果。
+type RouteDecision struct {
+	RequestedModel string `json:"requested_model"` // 客户端请求中的原始模型名
+	Model          string `json:"model"`           // 别名展开后发往上游的模型名
+	Proxy          string `json:"proxy"`           // 目标代理路径
+	Rule           string `json:"rule"`            // 命中的规则模式，使用默认代理时为 "default"
+}
+
+// RouteStat 是某个路由决策的累计次数。
+type RouteStat struct {
+	RequestedModel string `json:"requested_model"`
+	Model          string `json:"model"`
+	Proxy          string `json:"proxy"`
+	Count          int64  `json:"count"`
+}
+
+type compiledRule struct {
+	pattern string
+	re      *regexp.Regexp
+	proxy   string
+}
+
+type routeKey struct {
+	requested, model, proxy string
+}
+
+// ModelRouter 根据请求中的 model 字段选择目标代理。
+type ModelRouter struct {
+	rules   []compiledRule
+	aliases map[string]string
+	def     string
+	proxies map[string]bool
+	// prefixed 表示聚合模型列表为模型 ID 添加了来源前缀，此时允许按前缀直接路由
+	prefixed bool
+
+	mu     sync.Mutex
+	counts map[routeKey]int64
+}
+
+// NewModelRouter 根据配置创建模型路由器，proxies 用于校验规则中的代理路径是否存在，
+// prefixed 对应聚合模型列表的 prefix 选项。
+func NewModelRouter(cfg config.RouterConfig, proxies []config.ProxyConfig, prefixed bool) *ModelRouter {
+	r := &ModelRouter{
+		aliases:  make(map[string]string, len(cfg.Aliases)),
+		def:      cfg.Default,
+		proxies:  make(map[string]bool, len(proxies)),
+		prefixed: prefixed,
+		counts:   make(map[routeKey]int64),
+	}
+	for _, p := range proxies {
+		r.proxies[p.Path] = true
+	}
+	for alias, model := range cfg.Aliases {
+		r.aliases[strings.ToLower(alias)] = model
+	}
+	for _, rule := range cfg.Rules {
+		if !r.proxies[rule.Proxy] {
+			log.Printf("模型路由规则 %s -> %s 指向不存在的代理，已忽略", rule.Model, rule.Proxy)
+			continue
+		}
+		r.rules = append(r.rules, compiledRule{
+			pattern: rule.Model,
+			re:      compileModelPattern(rule.Model),
+			proxy:   rule.Proxy,
+		})
+	}
+	if r.def != "" && !r.proxies[r.def] {
+		log.Printf("模型路由默认代理 %s 不存在，已忽略", r.def)
+		r.def = ""
+	}
+	return r
+}
+
+// compileModelPattern 将通配符模式编译为不区分大小写的正则，* 可匹配包括 / 在内的任意字符。
+func compileModelPattern(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, `.*`)
+	quoted = strings.ReplaceAll(quoted, `\?`, `.`)
+	return regexp.MustCompile(`(?i)^` + quoted + `$`)
+}
+
+// Resolve 展开模型别名并按规则顺序查找目标代理。
+// 启用模型 ID 前缀时，未命中任何规则的 openai/gpt-4o 这类模型名（聚合模型列表中带来源前缀的 ID）
+// 会去掉前缀后路由到 /openai，显式规则始终优先。
+func (r *ModelRouter) Resolve(model string) (RouteDecision, bool) {
+	decision := RouteDecision{RequestedModel: model, Model: model}
+	if alias, ok := r.aliases[strings.ToLower(model)]; ok {
+		decision.Model = alias
+	}
+
+	for _, rule := range r.rules {
+		if rule.re.MatchString(decision.Model) {
+			decision.Proxy = rule.proxy
+			decision.Rule = rule.pattern
+			return decision, true
+		}
+	}
+	if r.prefixed {
+		if source, rest, found := strings.Cut(decision.Model, "/"); found && rest != "" && r.proxies["/"+source] {
+			decision.Model = rest
+			decision.Proxy = "/" + source
+			decision.Rule = "prefix"
+			return decision, true
+		}
+	}
+	if r.def != "" {
+		decision.Proxy = r.def
+		decision.Rule = "default"
+		return decision, true
+	}
+	return decision, false
+}
+
+// Record 累计一次路由决策，用于 /api/stats/routes 展示。
+func (r *ModelRouter) Record(d RouteDecision) {
+	r.mu.Lock()
+	r.counts[routeKey{d.RequestedModel, d.Model, d.Proxy}]++
+	r.mu.Unlock()
+}
+
+// Stats 返回按次数降序排列的路由统计。
+func (r *ModelRouter) Stats() []RouteStat {
+	r.mu.Lock()
+	stats := make([]RouteStat, 0, len(r.counts))
+	for k, v := range r.counts {
+		stats = append(stats, RouteStat{RequestedModel: k.requested, Model: k.model, Proxy: k.proxy, Count: v})
+	}
+	r.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].RequestedModel < stats[j].RequestedModel
+	})
+	return stats
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"testing"
+
+	"go-proxy/pkg/config"
+)
+
+func TestModelRouterResolve(t *testing.T) {
+	proxies := []config.ProxyConfig{{Path: "/openai"}, {Path: "/openrouter"}, {Path: "/groq"}}
+	cfg := config.RouterConfig{
+		Default: "/openrouter",
+		Aliases: map[string]string{"fast": "llama-3.1-8b-instant"},
+		Rules: []config.RouteRule{
+			{Model: "openai/gpt-4o", Proxy: "/openrouter"},
+			{Model: "gpt-*", Proxy: "/openai"},
+			{Model: "llama-*", Proxy: "/groq"},
+			{Model: "missing-*", Proxy: "/missing"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		prefixed bool
+		model    string
+		want     RouteDecision
+	}{
+		{
+			name:  "rule",
+			model: "GPT-4o-mini",
+			want:  RouteDecision{RequestedModel: "GPT-4o-mini", Model: "GPT-4o-mini", Proxy: "/openai", Rule: "gpt-*"},
+		},
+		{
+			name:  "alias",
+			model: "Fast",
+			want:  RouteDecision{RequestedModel: "Fast", Model: "llama-3.1-8b-instant", Proxy: "/groq", Rule: "llama-*"},
+		},
+		{
+			name:     "explicit rule wins over prefix",
+			prefixed: true,
+			model:    "openai/gpt-4o",
+			want:     RouteDecision{RequestedModel: "openai/gpt-4o", Model: "openai/gpt-4o", Proxy: "/openrouter", Rule: "openai/gpt-4o"},
+		},
+		{
+			name:     "prefix after rules",
+			prefixed: true,
+			model:    "groq/mixtral-8x7b",
+			want:     RouteDecision{RequestedModel: "groq/mixtral-8x7b", Model: "mixtral-8x7b", Proxy: "/groq", Rule: "prefix"},
+		},
+		{
+			name:  "prefix disabled",
+			model: "groq/mixtral-8x7b",
+			want:  RouteDecision{RequestedModel: "groq/mixtral-8x7b", Model: "groq/mixtral-8x7b", Proxy: "/openrouter", Rule: "default"},
+		},
+		{
+			name:  "rule to unknown proxy is ignored",
+			model: "missing-model",
+			want:  RouteDecision{RequestedModel: "missing-model", Model: "missing-model", Proxy: "/openrouter", Rule: "default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewModelRouter(cfg, proxies, tt.prefixed).Resolve(tt.model)
+			if !ok || got != tt.want {
+				t.Errorf("Resolve(%q) = %+v, %v, want %+v", tt.model, got, ok, tt.want)
+			}
+		})
+	}
+
+	cfg.Default = ""
+	if d, ok := NewModelRouter(cfg, proxies, false).Resolve("claude-3"); ok {
+		t.Errorf("未配置默认代理时 Resolve = %+v, want 未匹配", d)
+	}
+}
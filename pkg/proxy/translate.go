@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-proxy/pkg/translate"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// maxTranslateBodySize 限制待转换请求体的大小，防止超大请求占满内存
+const maxTranslateBodySize = 32 << 20
+
+type translateCtxKey struct{}
+
+// translateState 随请求上下文传递到 ModifyResponse，用于转换响应。
+type translateState struct {
+	translator   translate.Translator
+	model        string
+	stream       bool
+	includeUsage bool
+	// 上游原生端点，由 Director 在转发的请求副本上替换路径
+	path     string
+	rawQuery string
+}
+
+// handleTranslated 将 OpenAI 格式的请求转换为上游原生格式后再转发。
+func (p *ReverseProxy) handleTranslated(c echo.Context) error {
+	req := c.Request()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxTranslateBodySize+1))
+	req.Body.Close()
+	if err != nil {
+		return writeOpenAIError(c, http.StatusBadRequest, "读取请求体失败: "+err.Error(), "invalid_request_error")
+	}
+	if len(body) > maxTranslateBodySize {
+		return writeOpenAIError(c, http.StatusRequestEntityTooLarge, "请求体过大", "invalid_request_error")
+	}
+
+	var chatReq translate.ChatCompletionRequest
+	if err := json.Unmarshal(body, &chatReq); err != nil {
+		return writeOpenAIError(c, http.StatusBadRequest, "无法解析请求体: "+err.Error(), "invalid_request_error")
+	}
+
+	// 在副本上修改请求头与请求体，统计中间件记录的仍是客户端的原始请求
+	state := &translateState{
+		translator:   p.translator,
+		model:        chatReq.Model,
+		stream:       chatReq.Stream,
+		includeUsage: chatReq.StreamOptions != nil && chatReq.StreamOptions.IncludeUsage,
+	}
+	out := req.Clone(context.WithValue(req.Context(), translateCtxKey{}, state))
+
+	upstream, err := p.translator.ConvertRequest(&chatReq, out.Header)
+	if err != nil {
+		return writeOpenAIError(c, http.StatusBadRequest, err.Error(), "invalid_request_error")
+	}
+	state.path = upstream.Path
+	state.rawQuery = upstream.RawQuery
+
+	out.Body = io.NopCloser(bytes.NewReader(upstream.Body))
+	out.ContentLength = int64(len(upstream.Body))
+	out.Header.Set("Content-Length", strconv.Itoa(len(upstream.Body)))
+	out.Header.Set("Content-Type", "application/json")
+	// 交由 Transport 自行协商压缩，保证 ModifyResponse 拿到的是解压后的内容
+	out.Header.Del("Accept-Encoding")
+
+	p.proxy.ServeHTTP(c.Response(), out)
+	return nil
+}
+
+// modifyTranslatedResponse 将上游响应转换回 OpenAI 格式，未经转换的请求原样返回。
+func modifyTranslatedResponse(resp *http.Response) error {
+	state, ok := resp.Request.Context().Value(translateCtxKey{}).(*translateState)
+	if !ok {
+		return nil
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		replaceBody(resp, state.translator.ConvertError(resp.StatusCode, body), "application/json")
+		return nil
+	}
+
+	if state.stream && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		upstream := resp.Body
+		pr, pw := io.Pipe()
+		go func() {
+			defer upstream.Close()
+			err := state.translator.ConvertStream(upstream, pw, state.model, state.includeUsage)
+			if err != nil {
+				log.Printf("转换流式响应时出错: %v", err)
+			}
+			pw.CloseWithError(err)
+		}()
+		resp.Body = pr
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		resp.Header.Set("Content-Type", "text/event-stream")
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	converted, err := state.translator.ConvertResponse(body, state.model)
+	if err != nil {
+		log.Printf("转换响应时出错: %v", err)
+		resp.StatusCode = http.StatusBadGateway
+		converted = translate.NewError(err.Error(), "upstream_error")
+	}
+	replaceBody(resp, converted, "application/json")
+	return nil
+}
+
+// replaceBody 用新的内容替换响应体并修正相关头部。
+func replaceBody(resp *http.Response, body []byte, contentType string) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Del("Content-Encoding")
+}
+
+func writeOpenAIError(c echo.Context, status int, message, errType string) error {
+	return c.JSONBlob(status, translate.NewError(message, errType))
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Result 是一次限流检查的结果，用于生成 Retry-After 与 x-ratelimit-* 响应头。
+type Result struct {
+	Allowed    bool
+	Limit      int           // 每分钟上限（请求数或 token 数）
+	Remaining  int           // 剩余可用额度
+	Reset      time.Duration // 令牌恢复满额所需时间
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时间
+}
+
+// TokenBucket 是按分钟配额匀速补充的令牌桶，容量等于每分钟请求数，允许短时突发。
+type TokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // 每秒补充的令牌数
+	last     time.Time
+}
+
+// NewTokenBucket 创建一个每分钟 perMinute 个令牌的令牌桶，初始为满。
+func NewTokenBucket(perMinute int) *TokenBucket {
+	return &TokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// refill 按流逝时间补充令牌，调用方需持有锁。
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// TakeN 尝试取出 n 个令牌，令牌不足时不扣减。
+func (b *TokenBucket) TakeN(now time.Time, n float64) Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+
+	res := Result{Limit: int(b.capacity)}
+	if b.tokens >= n {
+		b.tokens -= n
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = time.Duration((b.capacity - b.tokens) / b.rate * float64(time.Second))
+	return res
+}
+
+// Take 尝试取出一个令牌。
+func (b *TokenBucket) Take(now time.Time) Result {
+	return b.TakeN(now, 1)
+}
+
+// Check 只检查桶中是否还有余量而不扣减，用于事后才知道消耗量的场景（例如按 token 计费）。
+func (b *TokenBucket) Check(now time.Time) Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+
+	res := Result{Limit: int(b.capacity), Allowed: b.tokens > 0}
+	if !res.Allowed {
+		res.RetryAfter = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	res.Remaining = max(0, int(math.Floor(b.tokens)))
+	res.Reset = time.Duration((b.capacity - b.tokens) / b.rate * float64(time.Second))
+	return res
+}
+
+// Consume 扣减 n 个令牌，允许扣成负数，欠下的额度需要等待补充后才能再次通过 Check。
+func (b *TokenBucket) Consume(now time.Time, n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.tokens -= n
+}
+
+// Refund 归还此前取出的 n 个令牌，最多补回到容量上限，用于后续检查失败时撤销 Take。
+func (b *TokenBucket) Refund(now time.Time, n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.tokens = math.Min(b.capacity, b.tokens+n)
+}
+
+// Semaphore 限制同时进行中的请求数。
+type Semaphore struct {
+	mu      sync.Mutex
+	limit   int
+	current int
+}
+
+// NewSemaphore 创建一个上限为 limit 的并发计数器。
+func NewSemaphore(limit int) *Semaphore {
+	return &Semaphore{limit: limit}
+}
+
+// TryAcquire 尝试占用一个并发名额，成功时必须调用 Release 归还。
+func (s *Semaphore) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current >= s.limit {
+		return false
+	}
+	s.current++
+	return true
+}
+
+// Release 归还一个并发名额。
+func (s *Semaphore) Release() {
+	s.mu.Lock()
+	if s.current > 0 {
+		s.current--
+	}
+	s.mu.Unlock()
+}
+
+// Registry 按名称懒加载并复用令牌桶与并发计数器。
+type Registry struct {
+	mu      sync.Mutex
+	buckets map[string]*TokenBucket
+	sems    map[string]*Semaphore
+}
+
+// NewRegistry 创建一个空的限流器注册表。
+func NewRegistry() *Registry {
+	return &Registry{
+		buckets: make(map[string]*TokenBucket),
+		sems:    make(map[string]*Semaphore),
+	}
+}
+
+// Bucket 返回名称对应的令牌桶，配额变化时重新创建。
+func (r *Registry) Bucket(name string, perMinute int) *TokenBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.buckets[name]
+	if !ok || int(b.capacity) != perMinute {
+		b = NewTokenBucket(perMinute)
+		r.buckets[name] = b
+	}
+	return b
+}
+
+// Semaphore 返回名称对应的并发计数器，上限变化时就地调整。
+func (r *Registry) Semaphore(name string, limit int) *Semaphore {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sems[name]
+	if !ok {
+		s = NewSemaphore(limit)
+		r.sems[name] = s
+	} else {
+		s.mu.Lock()
+		s.limit = limit
+		s.mu.Unlock()
+	}
+	return s
+}
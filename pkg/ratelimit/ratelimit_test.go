@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	b := NewTokenBucket(60)
+	now := b.last
+
+	for i := range 60 {
+		if res := b.Take(now); !res.Allowed {
+			t.Fatalf("第 %d 次 Take 被拒绝", i+1)
+		}
+	}
+	res := b.Take(now)
+	if res.Allowed {
+		t.Fatal("令牌耗尽后仍然放行")
+	}
+	if res.Limit != 60 || res.Remaining != 0 {
+		t.Errorf("Limit = %d, Remaining = %d", res.Limit, res.Remaining)
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", res.RetryAfter)
+	}
+	if res.Reset != time.Minute {
+		t.Errorf("Reset = %v, want 1m", res.Reset)
+	}
+
+	// 每秒补充一个令牌
+	if res := b.Take(now.Add(time.Second)); !res.Allowed {
+		t.Error("补充后 Take 被拒绝")
+	}
+}
+
+func TestTokenBucketCheckAndConsume(t *testing.T) {
+	b := NewTokenBucket(600)
+	now := b.last
+
+	if res := b.Check(now); !res.Allowed || res.Remaining != 600 {
+		t.Fatalf("Check = %+v", res)
+	}
+	// 事后扣减可以透支，欠下的额度补齐前 Check 都不放行
+	b.Consume(now, 1000)
+	res := b.Check(now)
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("透支后 Check = %+v", res)
+	}
+	if res.RetryAfter != 40100*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 40.1s", res.RetryAfter)
+	}
+	if res := b.Check(now.Add(41 * time.Second)); !res.Allowed {
+		t.Errorf("补齐后 Check = %+v", res)
+	}
+}
+
+func TestTokenBucketRefund(t *testing.T) {
+	b := NewTokenBucket(2)
+	now := b.last
+
+	b.Take(now)
+	b.Take(now)
+	b.Refund(now, 1)
+	if res := b.Take(now); !res.Allowed {
+		t.Fatal("归还的令牌不可用")
+	}
+	// 归还不能超过容量
+	b.Refund(now, 10)
+	if res := b.Check(now); res.Remaining != 2 {
+		t.Errorf("Remaining = %d, want 2", res.Remaining)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(2)
+	if !s.TryAcquire() || !s.TryAcquire() {
+		t.Fatal("未达上限时 TryAcquire 失败")
+	}
+	if s.TryAcquire() {
+		t.Fatal("超过上限时 TryAcquire 成功")
+	}
+	s.Release()
+	if !s.TryAcquire() {
+		t.Fatal("Release 后 TryAcquire 失败")
+	}
+	// 多余的 Release 不会让计数变成负数
+	s.Release()
+	s.Release()
+	s.Release()
+	if !s.TryAcquire() || !s.TryAcquire() || s.TryAcquire() {
+		t.Error("多余的 Release 改变了上限")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if r.Bucket("a", 10) != r.Bucket("a", 10) {
+		t.Error("相同名称与配额应复用令牌桶")
+	}
+	if b := r.Bucket("a", 20); b.capacity != 20 {
+		t.Errorf("配额变化后容量 = %v, want 20", b.capacity)
+	}
+
+	s := r.Semaphore("a", 1)
+	s.TryAcquire()
+	if r.Semaphore("a", 2) != s {
+		t.Error("上限变化时应就地调整并发计数器")
+	}
+	if !s.TryAcquire() {
+		t.Error("调高上限后 TryAcquire 失败")
+	}
+}
@@ -0,0 +1,165 @@
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Placeholder 替换被脱敏的值
+const Placeholder = "[REDACTED]"
+
+// ErrNotRedactable 表示内容看起来是 JSON 但无法解析（例如被截断），因此无法可靠地脱敏。
+var ErrNotRedactable = errors.New("内容无法解析，无法脱敏")
+
+// segment 是 JSON 路径中的一段：对象键（* 匹配所有键）或数组下标（-1 匹配所有元素）。
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// JSONPath 是编译后的 JSON 路径，例如 messages[*].content。
+type JSONPath []segment
+
+// CompilePaths 编译 JSON 路径，支持 a.b、a[0]、a[*]、*.b 以及可选的 $. 前缀。
+func CompilePaths(paths []string) ([]JSONPath, error) {
+	compiled := make([]JSONPath, 0, len(paths))
+	for _, p := range paths {
+		path, err := compilePath(p)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, path)
+	}
+	return compiled, nil
+}
+
+func compilePath(p string) (JSONPath, error) {
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	if p == "" {
+		return nil, fmt.Errorf("JSON 路径不能为空")
+	}
+	var path JSONPath
+	for _, part := range strings.Split(p, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			path = append(path, segment{key: name})
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, fmt.Errorf("JSON 路径 %q 缺少 ]", p)
+			}
+			if idx == "*" {
+				path = append(path, segment{index: -1, isIndex: true})
+			} else {
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("JSON 路径 %q 中的下标 %q 无效", p, idx)
+				}
+				path = append(path, segment{index: n, isIndex: true})
+			}
+			rest = strings.TrimPrefix(after, "[")
+		}
+		if name == "" && !strings.Contains(part, "[") {
+			return nil, fmt.Errorf("JSON 路径 %q 包含空段", p)
+		}
+	}
+	return path, nil
+}
+
+// apply 将路径命中的值替换为 Placeholder。
+func apply(node any, path JSONPath) any {
+	if len(path) == 0 {
+		return Placeholder
+	}
+	seg, rest := path[0], path[1:]
+	if seg.isIndex {
+		arr, ok := node.([]any)
+		if !ok {
+			return node
+		}
+		for i := range arr {
+			if seg.index < 0 || seg.index == i {
+				arr[i] = apply(arr[i], rest)
+			}
+		}
+		return arr
+	}
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return node
+	}
+	for k, v := range obj {
+		if seg.key == "*" || seg.key == k {
+			obj[k] = apply(v, rest)
+		}
+	}
+	return obj
+}
+
+// JSON 对 JSON 文档应用脱敏路径，解析失败时返回 ErrNotRedactable。
+func JSON(data []byte, paths []JSONPath) ([]byte, error) {
+	if len(paths) == 0 {
+		return data, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, ErrNotRedactable
+	}
+	for _, p := range paths {
+		doc = apply(doc, p)
+	}
+	return json.Marshal(doc)
+}
+
+// looksLikeJSON 判断内容是否应按 JSON 处理。
+func looksLikeJSON(data []byte, contentType string) bool {
+	if strings.Contains(contentType, "json") {
+		return true
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+// Body 按内容类型对请求/响应体脱敏：JSON 整体处理，SSE 逐个 data 事件处理，其他内容原样返回。
+// 看起来是 JSON 但无法解析时返回 ErrNotRedactable，调用方不应保存原文。
+func Body(data []byte, contentType string, paths []JSONPath) ([]byte, error) {
+	if len(paths) == 0 || len(data) == 0 {
+		return data, nil
+	}
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		var out bytes.Buffer
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+				payload = bytes.TrimSpace(payload)
+				// OpenAI 的结束标记 [DONE] 以 [ 开头但不是 JSON，原样保留
+				if looksLikeJSON(payload, "") && !bytes.Equal(payload, []byte("[DONE]")) {
+					redacted, err := JSON(payload, paths)
+					if err != nil {
+						// 被截断的最后一个事件直接丢弃
+						break
+					}
+					line = append([]byte("data: "), redacted...)
+				}
+			}
+			out.Write(line)
+			out.WriteByte('\n')
+		}
+		return out.Bytes(), nil
+	}
+	if looksLikeJSON(data, contentType) {
+		return JSON(data, paths)
+	}
+	return data, nil
+}
@@ -0,0 +1,176 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompilePathsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "empty", path: ""},
+		{name: "root only", path: "$."},
+		{name: "missing bracket", path: "messages[0"},
+		{name: "bad index", path: "messages[x]"},
+		{name: "negative index", path: "messages[-1]"},
+		{name: "empty segment", path: "a..b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompilePaths([]string{tt.path}); err == nil {
+				t.Errorf("CompilePaths(%q) 应返回错误", tt.path)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		body  string
+		want  string
+	}{
+		{
+			name:  "nested key",
+			paths: []string{"metadata.user_id"},
+			body:  `{"metadata":{"user_id":"u-1","plan":"pro"},"model":"gpt-4o"}`,
+			want:  `{"metadata":{"plan":"pro","user_id":"[REDACTED]"},"model":"gpt-4o"}`,
+		},
+		{
+			name:  "dollar prefix and wildcard index",
+			paths: []string{"$.messages[*].content"},
+			body:  `{"messages":[{"role":"system","content":"a"},{"role":"user","content":[{"type":"text","text":"b"}]}]}`,
+			want:  `{"messages":[{"content":"[REDACTED]","role":"system"},{"content":"[REDACTED]","role":"user"}]}`,
+		},
+		{
+			name:  "fixed index",
+			paths: []string{"messages[1].content"},
+			body:  `{"messages":[{"content":"a"},{"content":"b"},{"content":"c"}]}`,
+			want:  `{"messages":[{"content":"a"},{"content":"[REDACTED]"},{"content":"c"}]}`,
+		},
+		{
+			name:  "wildcard key",
+			paths: []string{"*.text"},
+			body:  `{"a":{"text":"x","n":1},"b":{"text":"y"},"c":"z"}`,
+			want:  `{"a":{"n":1,"text":"[REDACTED]"},"b":{"text":"[REDACTED]"},"c":"z"}`,
+		},
+		{
+			name:  "top-level array",
+			paths: []string{"[*].prompt"},
+			body:  `[{"prompt":"a"},{"prompt":"b"}]`,
+			want:  `[{"prompt":"[REDACTED]"},{"prompt":"[REDACTED]"}]`,
+		},
+		{
+			name:  "missing path and type mismatch are ignored",
+			paths: []string{"input.text", "messages[0]", "model.name"},
+			body:  `{"messages":{"0":"a"},"model":"gpt-4o"}`,
+			want:  `{"messages":{"0":"a"},"model":"gpt-4o"}`,
+		},
+		{
+			name:  "numbers keep precision",
+			paths: []string{"user"},
+			body:  `{"seed":12345678901234567890,"temperature":0.70,"user":"alice"}`,
+			want:  `{"seed":12345678901234567890,"temperature":0.70,"user":"[REDACTED]"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := CompilePaths(tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := JSON([]byte(tt.body), paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JSON = %s\nwant   %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBody(t *testing.T) {
+	paths, err := CompilePaths([]string{"messages[*].content", "choices[*].delta.content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     error
+	}{
+		{
+			name:        "json content type",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"messages":[{"content":"secret"}]}`,
+			want:        `{"messages":[{"content":"[REDACTED]"}]}`,
+		},
+		{
+			name: "json sniffed without content type",
+			body: "  \n{\"messages\":[{\"content\":\"secret\"}]}",
+			want: `{"messages":[{"content":"[REDACTED]"}]}`,
+		},
+		{
+			name:        "truncated json",
+			contentType: "application/json",
+			body:        `{"messages":[{"content":"sec`,
+			wantErr:     ErrNotRedactable,
+		},
+		{
+			name:        "plain text passes through",
+			contentType: "text/plain",
+			body:        "upstream timeout",
+			want:        "upstream timeout",
+		},
+		{
+			name:        "sse events",
+			contentType: "text/event-stream",
+			body: "event: message\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want: "event: message\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"[REDACTED]\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+		},
+		{
+			// 被截断的最后一个事件及其后内容都应丢弃，不能保存原文
+			name:        "sse truncated tail",
+			contentType: "text/event-stream",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"sec",
+			want: "data: {\"choices\":[{\"delta\":{\"content\":\"[REDACTED]\"}}]}\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Body([]byte(tt.body), tt.contentType, paths)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Body = %q\nwant   %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 未配置路径时原样返回，不做解析。
+func TestBodyWithoutPaths(t *testing.T) {
+	body := []byte(`{"messages":[{"content":"sec`)
+	got, err := Body(body, "application/json", nil)
+	if err != nil || string(got) != string(body) {
+		t.Errorf("Body = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,76 @@
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 内置的敏感查询参数与请求头，Configure 追加的名称与之合并
+var (
+	defaultQueryParams = []string{"key", "api_key", "apikey", "api-key", "access_token", "token", "client_secret", "sig", "signature"}
+	defaultHeaders     = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key", "Cookie", "Set-Cookie"}
+)
+
+var (
+	secretsMu    sync.RWMutex
+	paramPattern *regexp.Regexp
+	headerSet    map[string]bool
+)
+
+func init() {
+	Configure(nil, nil)
+}
+
+// Configure 设置需要脱敏的查询参数与请求头（在内置列表基础上追加），应在处理请求前调用。
+func Configure(queryParams, headers []string) {
+	names := make([]string, 0, len(defaultQueryParams)+len(queryParams))
+	for _, p := range append(append([]string{}, defaultQueryParams...), queryParams...) {
+		if p = strings.TrimSpace(p); p != "" {
+			names = append(names, regexp.QuoteMeta(p))
+		}
+	}
+	// 匹配 ?key=xxx 或 &key=xxx，值截止到 &、空白、引号或 #
+	pattern := regexp.MustCompile(`(?i)([?&](?:` + strings.Join(names, "|") + `)=)[^&\s"'#]*`)
+
+	set := make(map[string]bool, len(defaultHeaders)+len(headers))
+	for _, h := range append(append([]string{}, defaultHeaders...), headers...) {
+		if h = strings.TrimSpace(h); h != "" {
+			set[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+
+	secretsMu.Lock()
+	paramPattern = pattern
+	headerSet = set
+	secretsMu.Unlock()
+}
+
+// Text 隐藏文本中敏感查询参数的值，适用于请求 URI、完整 URL 以及包含 URL 的错误信息。
+func Text(s string) string {
+	if !strings.ContainsAny(s, "?&") {
+		return s
+	}
+	secretsMu.RLock()
+	pattern := paramPattern
+	secretsMu.RUnlock()
+	return pattern.ReplaceAllString(s, "${1}"+Placeholder)
+}
+
+// Header 返回隐藏了敏感请求头的副本。
+func Header(h http.Header) http.Header {
+	secretsMu.RLock()
+	set := headerSet
+	secretsMu.RUnlock()
+
+	out := h.Clone()
+	for k, values := range out {
+		if set[http.CanonicalHeaderKey(k)] {
+			for i := range values {
+				values[i] = Placeholder
+			}
+		}
+	}
+	return out
+}
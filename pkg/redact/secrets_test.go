@@ -0,0 +1,87 @@
+package redact
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "gemini key in uri",
+			in:   "/v1beta/models/gemini-2.0-flash:streamGenerateContent?alt=sse&key=AIzaSyTest",
+			want: "/v1beta/models/gemini-2.0-flash:streamGenerateContent?alt=sse&key=[REDACTED]",
+		},
+		{
+			name: "first parameter and case insensitive",
+			in:   "https://example.com/v1?API_KEY=abc&model=x",
+			want: "https://example.com/v1?API_KEY=[REDACTED]&model=x",
+		},
+		{
+			name: "value ends at quote and fragment",
+			in:   `Get "https://example.com/a?sig=abc%2F#frag": dial tcp: timeout`,
+			want: `Get "https://example.com/a?sig=[REDACTED]#frag": dial tcp: timeout`,
+		},
+		{
+			name: "similar names are kept",
+			in:   "/v1/files?monkey=1&tokens=2&key_id=3",
+			want: "/v1/files?monkey=1&tokens=2&key_id=3",
+		},
+		{
+			name: "no query",
+			in:   "/v1/chat/completions",
+			want: "/v1/chat/completions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.in); got != tt.want {
+				t.Errorf("Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer sk-test")
+	h.Set("X-Goog-Api-Key", "AIza")
+	h["cookie"] = []string{"a=1", "b=2"}
+	h.Set("Content-Type", "application/json")
+
+	got := Header(h)
+	for _, k := range []string{"Authorization", "X-Goog-Api-Key"} {
+		if v := got.Get(k); v != Placeholder {
+			t.Errorf("%s = %q", k, v)
+		}
+	}
+	if v := got["cookie"]; len(v) != 2 || v[0] != Placeholder || v[1] != Placeholder {
+		t.Errorf("cookie = %q", v)
+	}
+	if v := got.Get("Content-Type"); v != "application/json" {
+		t.Errorf("Content-Type = %q", v)
+	}
+	if v := h.Get("Authorization"); v != "Bearer sk-test" {
+		t.Errorf("原请求头被修改: %q", v)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { Configure(nil, nil) })
+	Configure([]string{" session ", ""}, []string{"x-internal-token"})
+
+	if got, want := Text("/a?session=s1&key=k1&page=2"), "/a?session=[REDACTED]&key=[REDACTED]&page=2"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+	h := http.Header{}
+	h.Set("X-Internal-Token", "t")
+	h.Set("Authorization", "Bearer sk")
+	got := Header(h)
+	if got.Get("X-Internal-Token") != Placeholder || got.Get("Authorization") != Placeholder {
+		t.Errorf("Header = %v", got)
+	}
+}
@@ -0,0 +1,491 @@
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// anthropicVersion 是未指定 anthropic-version 请求头时使用的默认 API 版本
+	anthropicVersion = "2023-06-01"
+	// anthropicDefaultMaxTokens 是 Anthropic 必填的 max_tokens 在客户端未指定时的默认值
+	anthropicDefaultMaxTokens = 4096
+)
+
+// ========================================
+// Anthropic Messages API 结构
+// ========================================
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	Messages      []anthropicMessage `json:"messages"`
+	System        string             `json:"system,omitempty"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice    *anthropicChoice   `json:"tool_choice,omitempty"`
+	Metadata      *anthropicMetadata `json:"metadata,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type string `json:"type"`
+	// text / thinking
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+	// image
+	Source *anthropicImageSource `json:"source,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"` // base64 或 url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicChoice struct {
+	Type                   string `json:"type"` // auto / any / tool / none
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+type anthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u anthropicUsage) toOpenAI() *Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return &Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+}
+
+type anthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStreamEvent 覆盖流式响应中用到的所有事件字段。
+type anthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Index   int                `json:"index"`
+	Message *anthropicResponse `json:"message,omitempty"`
+	// content_block_start
+	ContentBlock *anthropicBlock `json:"content_block,omitempty"`
+	// content_block_delta / message_delta
+	Delta *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// ========================================
+// 转换实现
+// ========================================
+
+type anthropicTranslator struct{}
+
+func (t *anthropicTranslator) ConvertRequest(req *ChatCompletionRequest, header http.Header) (*UpstreamRequest, error) {
+	out := anthropicRequest{
+		Model:         req.Model,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: ParseStop(req.Stop),
+		Stream:        req.Stream,
+		MaxTokens:     anthropicDefaultMaxTokens,
+	}
+	if req.MaxCompletionTokens != nil {
+		out.MaxTokens = *req.MaxCompletionTokens
+	} else if req.MaxTokens != nil {
+		out.MaxTokens = *req.MaxTokens
+	}
+	if req.User != "" {
+		out.Metadata = &anthropicMetadata{UserID: req.User}
+	}
+
+	var systemParts []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := ContentText(msg.Content); text != "" {
+				systemParts = append(systemParts, text)
+			}
+
+		case "user":
+			blocks, err := anthropicContentBlocks(msg.Content)
+			if err != nil {
+				return nil, err
+			}
+			out.Messages = appendAnthropicMessage(out.Messages, "user", blocks)
+
+		case "assistant":
+			var blocks []anthropicBlock
+			if text := ContentText(msg.Content); text != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: text})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) || strings.TrimSpace(tc.Function.Arguments) == "" {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+			if len(blocks) == 0 {
+				continue
+			}
+			out.Messages = appendAnthropicMessage(out.Messages, "assistant", blocks)
+
+		case "tool":
+			block := anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: ContentText(msg.Content)}
+			out.Messages = appendAnthropicMessage(out.Messages, "user", []anthropicBlock{block})
+
+		default:
+			return nil, fmt.Errorf("不支持的消息角色: %s", msg.Role)
+		}
+	}
+	out.System = strings.Join(systemParts, "\n\n")
+	if len(out.Messages) == 0 {
+		return nil, fmt.Errorf("messages 中至少需要一条非 system 消息")
+	}
+
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	out.ToolChoice = anthropicToolChoice(req.ToolChoice)
+	if req.ParallelToolCalls != nil && !*req.ParallelToolCalls && len(out.Tools) > 0 {
+		if out.ToolChoice == nil {
+			out.ToolChoice = &anthropicChoice{Type: "auto"}
+		}
+		out.ToolChoice.DisableParallelToolUse = true
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenAI 客户端使用 Authorization: Bearer，Anthropic 使用 x-api-key
+	if auth := header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && header.Get("x-api-key") == "" {
+		header.Set("x-api-key", strings.TrimPrefix(auth, "Bearer "))
+	}
+	header.Del("Authorization")
+	if header.Get("anthropic-version") == "" {
+		header.Set("anthropic-version", anthropicVersion)
+	}
+
+	return &UpstreamRequest{Path: "/v1/messages", Body: body}, nil
+}
+
+// anthropicContentBlocks 将 OpenAI 用户消息内容转换为 Anthropic 内容块。
+func anthropicContentBlocks(raw json.RawMessage) ([]anthropicBlock, error) {
+	parts, err := ParseContent(raw)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析消息内容: %w", err)
+	}
+	blocks := make([]anthropicBlock, 0, len(parts))
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			// Anthropic 拒绝空的 text 块
+			if p.Text == "" {
+				continue
+			}
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: p.Text})
+		case "image_url":
+			if p.ImageURL == nil {
+				continue
+			}
+			if mimeType, data, ok := ParseDataURL(p.ImageURL.URL); ok {
+				blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicImageSource{Type: "base64", MediaType: mimeType, Data: data}})
+			} else {
+				blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicImageSource{Type: "url", URL: p.ImageURL.URL}})
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// appendAnthropicMessage 追加消息，相邻同角色消息会被合并，以满足 Anthropic 的角色交替要求。
+func appendAnthropicMessage(msgs []anthropicMessage, role string, blocks []anthropicBlock) []anthropicMessage {
+	if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+		msgs[n-1].Content = append(msgs[n-1].Content, blocks...)
+		return msgs
+	}
+	return append(msgs, anthropicMessage{Role: role, Content: blocks})
+}
+
+func anthropicToolChoice(raw json.RawMessage) *anthropicChoice {
+	if len(raw) == 0 {
+		return nil
+	}
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "auto":
+			return &anthropicChoice{Type: "auto"}
+		case "none":
+			return &anthropicChoice{Type: "none"}
+		case "required":
+			return &anthropicChoice{Type: "any"}
+		}
+		return nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err == nil && named.Function.Name != "" {
+		return &anthropicChoice{Type: "tool", Name: named.Function.Name}
+	}
+	return nil
+}
+
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+func (t *anthropicTranslator) ConvertResponse(body []byte, model string) ([]byte, error) {
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("无法解析 Anthropic 响应: %w", err)
+	}
+	if resp.Model != "" {
+		model = resp.Model
+	}
+
+	var text strings.Builder
+	var reasoning strings.Builder
+	var toolCalls []ToolCall
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "thinking":
+			reasoning.WriteString(block.Thinking)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			toolCalls = append(toolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name, Arguments: args},
+			})
+		}
+	}
+
+	msg := ResponseDelta{Role: "assistant", ReasoningContent: reasoning.String(), ToolCalls: toolCalls}
+	if text.Len() > 0 || len(toolCalls) == 0 {
+		msg.Content = strPtr(text.String())
+	}
+	out := ChatCompletionResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []ChatChoice{{
+			Index:        0,
+			Message:      msg,
+			FinishReason: strPtr(anthropicFinishReason(resp.StopReason)),
+		}},
+		Usage: resp.Usage.toOpenAI(),
+	}
+	return json.Marshal(out)
+}
+
+func (t *anthropicTranslator) ConvertStream(r io.Reader, w io.Writer, model string, includeUsage bool) error {
+	id := ""
+	created := time.Now().Unix()
+	var usage anthropicUsage
+	finishReason := "stop"
+	toolIndex := -1
+	// Anthropic 的内容块索引与 OpenAI tool_calls 索引不同，需要单独映射
+	blockToTool := make(map[int]int)
+
+	chunk := func(delta ResponseDelta, finish *string) ChatCompletionChunk {
+		return ChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []ChunkChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+		}
+	}
+
+	err := readSSE(r, func(ev sseEvent) error {
+		if ev.Data == "" {
+			return nil
+		}
+		var e anthropicStreamEvent
+		if err := json.Unmarshal([]byte(ev.Data), &e); err != nil {
+			return fmt.Errorf("无法解析 Anthropic 流事件: %w", err)
+		}
+
+		switch e.Type {
+		case "message_start":
+			if e.Message != nil {
+				id = e.Message.ID
+				if e.Message.Model != "" {
+					model = e.Message.Model
+				}
+				usage = e.Message.Usage
+			}
+			return writeSSEData(w, chunk(ResponseDelta{Role: "assistant", Content: strPtr("")}, nil))
+
+		case "content_block_start":
+			if e.ContentBlock != nil && e.ContentBlock.Type == "tool_use" {
+				toolIndex++
+				blockToTool[e.Index] = toolIndex
+				idx := toolIndex
+				return writeSSEData(w, chunk(ResponseDelta{ToolCalls: []ToolCall{{
+					Index:    &idx,
+					ID:       e.ContentBlock.ID,
+					Type:     "function",
+					Function: ToolCallFunction{Name: e.ContentBlock.Name, Arguments: ""},
+				}}}, nil))
+			}
+
+		case "content_block_delta":
+			if e.Delta == nil {
+				return nil
+			}
+			switch e.Delta.Type {
+			case "text_delta":
+				return writeSSEData(w, chunk(ResponseDelta{Content: strPtr(e.Delta.Text)}, nil))
+			case "thinking_delta":
+				return writeSSEData(w, chunk(ResponseDelta{ReasoningContent: e.Delta.Thinking}, nil))
+			case "input_json_delta":
+				idx, ok := blockToTool[e.Index]
+				if !ok {
+					return nil
+				}
+				return writeSSEData(w, chunk(ResponseDelta{ToolCalls: []ToolCall{{
+					Index:    &idx,
+					Function: ToolCallFunction{Arguments: e.Delta.PartialJSON},
+				}}}, nil))
+			}
+
+		case "message_delta":
+			if e.Delta != nil && e.Delta.StopReason != "" {
+				finishReason = anthropicFinishReason(e.Delta.StopReason)
+			}
+			if e.Usage != nil {
+				usage.OutputTokens = e.Usage.OutputTokens
+			}
+
+		case "message_stop":
+			if err := writeSSEData(w, chunk(ResponseDelta{}, strPtr(finishReason))); err != nil {
+				return err
+			}
+			if includeUsage {
+				final := chunk(ResponseDelta{}, nil)
+				final.Choices = []ChunkChoice{}
+				final.Usage = usage.toOpenAI()
+				if err := writeSSEData(w, final); err != nil {
+					return err
+				}
+			}
+			return io.EOF
+
+		case "error":
+			msg := "upstream stream error"
+			errType := "api_error"
+			if e.Error != nil {
+				msg = e.Error.Message
+				errType = e.Error.Type
+			}
+			if _, err := w.Write([]byte("data: " + string(NewError(msg, errType)) + "\n\n")); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writeSSEDone(w)
+}
+
+func (t *anthropicTranslator) ConvertError(status int, body []byte) []byte {
+	var e anthropicError
+	if err := json.Unmarshal(body, &e); err == nil && e.Error.Message != "" {
+		return NewError(e.Error.Message, e.Error.Type)
+	}
+	return NewError(fmt.Sprintf("upstream returned status %d: %s", status, strings.TrimSpace(string(body))), "upstream_error")
+}
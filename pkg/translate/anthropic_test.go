@@ -0,0 +1,138 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAnthropicConvertRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{name: "system, tools, tool_calls and images", request: "request.openai.json", want: "request.anthropic.json"},
+		{name: "empty text parts", request: "request_empty_text.openai.json", want: "request_empty_text.anthropic.json"},
+	}
+
+	tr := &anthropicTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ChatCompletionRequest
+			if err := json.Unmarshal(readFixture(t, "anthropic", tt.request), &req); err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			header.Set("Authorization", "Bearer sk-ant-test")
+
+			up, err := tr.ConvertRequest(&req, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if up.Path != "/v1/messages" {
+				t.Errorf("Path = %q", up.Path)
+			}
+			assertJSONEqual(t, up.Body, readFixture(t, "anthropic", tt.want))
+
+			if got := header.Get("x-api-key"); got != "sk-ant-test" {
+				t.Errorf("x-api-key = %q", got)
+			}
+			if got := header.Get("Authorization"); got != "" {
+				t.Errorf("Authorization 未移除: %q", got)
+			}
+			if got := header.Get("anthropic-version"); got != anthropicVersion {
+				t.Errorf("anthropic-version = %q", got)
+			}
+		})
+	}
+}
+
+func TestAnthropicConvertResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream string
+		want     string
+	}{
+		{name: "text and tool_use", upstream: "response_tool_use.anthropic.json", want: "response_tool_use.openai.json"},
+		{name: "thinking and max_tokens", upstream: "response_thinking.anthropic.json", want: "response_thinking.openai.json"},
+	}
+
+	tr := &anthropicTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tr.ConvertResponse(readFixture(t, "anthropic", tt.upstream), "fallback-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, readFixture(t, "anthropic", tt.want), "created")
+		})
+	}
+}
+
+func TestAnthropicConvertStream(t *testing.T) {
+	tests := []struct {
+		name         string
+		upstream     string
+		want         string
+		includeUsage bool
+	}{
+		{name: "text and tool_use", upstream: "stream_tool_use.anthropic.sse", want: "stream_tool_use.openai.sse", includeUsage: true},
+		{name: "error event", upstream: "stream_error.anthropic.sse", want: "stream_error.openai.sse"},
+	}
+
+	tr := &anthropicTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tr.ConvertStream(bytes.NewReader(readFixture(t, "anthropic", tt.upstream)), &out, "fallback-model", tt.includeUsage); err != nil {
+				t.Fatal(err)
+			}
+			got, want := sseData(t, out.Bytes()), sseData(t, readFixture(t, "anthropic", tt.want))
+			if len(got) != len(want) {
+				t.Fatalf("事件数 = %d, want %d\n%s", len(got), len(want), out.String())
+			}
+			for i := range want {
+				if want[i] == "[DONE]" || got[i] == "[DONE]" {
+					if got[i] != want[i] {
+						t.Errorf("事件 %d = %s, want %s", i, got[i], want[i])
+					}
+					continue
+				}
+				assertJSONEqual(t, []byte(got[i]), []byte(want[i]), "created")
+			}
+		})
+	}
+}
+
+func TestAnthropicConvertError(t *testing.T) {
+	tr := &anthropicTranslator{}
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{
+			name:   "anthropic error",
+			status: http.StatusBadRequest,
+			body:   `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: Field required"}}`,
+			want:   `{"error":{"message":"max_tokens: Field required","type":"invalid_request_error","param":null,"code":null}}`,
+		},
+		{
+			name:   "non-json body",
+			status: http.StatusBadGateway,
+			body:   "bad gateway\n",
+			want:   `{"error":{"message":"upstream returned status 502: bad gateway","type":"upstream_error","param":null,"code":null}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tr.ConvertError(tt.status, []byte(tt.body))
+			if compactJSON(got) != tt.want {
+				t.Errorf("ConvertError = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,569 @@
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ========================================
+// Gemini generateContent 结构
+// ========================================
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FileData         *geminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig geminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+type geminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"` // AUTO / ANY / NONE
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates    []geminiCandidate `json:"candidates"`
+	UsageMetadata *geminiUsage      `json:"usageMetadata,omitempty"`
+	ModelVersion  string            `json:"modelVersion,omitempty"`
+	ResponseID    string            `json:"responseId,omitempty"`
+	// Error 在流中途出错时出现，此时事件仍能解析为 geminiResponse
+	Error *geminiErrorBody `json:"error,omitempty"`
+}
+
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+type geminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+func (u *geminiUsage) toOpenAI() *Usage {
+	if u == nil {
+		return nil
+	}
+	completion := u.CandidatesTokenCount + u.ThoughtsTokenCount
+	total := u.TotalTokenCount
+	if total == 0 {
+		total = u.PromptTokenCount + completion
+	}
+	return &Usage{PromptTokens: u.PromptTokenCount, CompletionTokens: completion, TotalTokens: total}
+}
+
+type geminiError struct {
+	Error geminiErrorBody `json:"error"`
+}
+
+type geminiErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// geminiUnsupportedSchemaKeys 是 Gemini 函数参数 Schema 不接受的 JSON Schema 关键字
+var geminiUnsupportedSchemaKeys = []string{"$schema", "additionalProperties", "strict"}
+
+// ========================================
+// 转换实现
+// ========================================
+
+type geminiTranslator struct{}
+
+func (t *geminiTranslator) ConvertRequest(req *ChatCompletionRequest, header http.Header) (*UpstreamRequest, error) {
+	model := strings.TrimPrefix(req.Model, "models/")
+	if model == "" {
+		return nil, fmt.Errorf("model 不能为空")
+	}
+
+	var out geminiRequest
+	var systemParts []geminiPart
+	// tool 消息只携带 tool_call_id，Gemini 的 functionResponse 需要函数名，因此记录 id 到函数名的映射
+	toolNames := make(map[string]string)
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := ContentText(msg.Content); text != "" {
+				systemParts = append(systemParts, geminiPart{Text: text})
+			}
+
+		case "user":
+			parts, err := geminiContentParts(msg.Content)
+			if err != nil {
+				return nil, err
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			out.Contents = appendGeminiContent(out.Contents, "user", parts)
+
+		case "assistant":
+			var parts []geminiPart
+			if text := ContentText(msg.Content); text != "" {
+				parts = append(parts, geminiPart{Text: text})
+			}
+			for _, tc := range msg.ToolCalls {
+				toolNames[tc.ID] = tc.Function.Name
+				args := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(args) || strings.TrimSpace(tc.Function.Arguments) == "" {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: tc.Function.Name, Args: args}})
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			out.Contents = appendGeminiContent(out.Contents, "model", parts)
+
+		case "tool":
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				name = msg.Name
+			}
+			text := ContentText(msg.Content)
+			response := json.RawMessage(text)
+			if !json.Valid(response) || !strings.HasPrefix(strings.TrimSpace(text), "{") {
+				// functionResponse.response 必须是对象
+				response, _ = json.Marshal(map[string]string{"content": text})
+			}
+			part := geminiPart{FunctionResponse: &geminiFunctionResponse{Name: name, Response: response}}
+			out.Contents = appendGeminiContent(out.Contents, "user", []geminiPart{part})
+
+		default:
+			return nil, fmt.Errorf("不支持的消息角色: %s", msg.Role)
+		}
+	}
+	if len(out.Contents) == 0 {
+		return nil, fmt.Errorf("messages 中至少需要一条非 system 消息")
+	}
+	if len(systemParts) > 0 {
+		out.SystemInstruction = &geminiContent{Parts: systemParts}
+	}
+
+	gen := geminiGenerationConfig{
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: ParseStop(req.Stop),
+	}
+	if req.MaxCompletionTokens != nil {
+		gen.MaxOutputTokens = req.MaxCompletionTokens
+	} else if req.MaxTokens != nil {
+		gen.MaxOutputTokens = req.MaxTokens
+	}
+	if gen.Temperature != nil || gen.TopP != nil || gen.MaxOutputTokens != nil || len(gen.StopSequences) > 0 {
+		out.GenerationConfig = &gen
+	}
+
+	var decls []geminiFunctionDeclaration
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		decls = append(decls, geminiFunctionDeclaration{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  sanitizeGeminiSchema(tool.Function.Parameters),
+		})
+	}
+	if len(decls) > 0 {
+		out.Tools = []geminiTool{{FunctionDeclarations: decls}}
+		out.ToolConfig = geminiToolChoice(req.ToolChoice)
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenAI 客户端使用 Authorization: Bearer；Gemini API Key 通过 x-goog-api-key 传递，
+	// OAuth 访问令牌（ya29. 开头）则保留在 Authorization 中
+	if auth := header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if !strings.HasPrefix(token, "ya29.") {
+			if header.Get("x-goog-api-key") == "" {
+				header.Set("x-goog-api-key", token)
+			}
+			header.Del("Authorization")
+		}
+	}
+
+	up := &UpstreamRequest{Body: body}
+	escaped := url.PathEscape(model)
+	if req.Stream {
+		up.Path = "/v1beta/models/" + escaped + ":streamGenerateContent"
+		up.RawQuery = "alt=sse"
+	} else {
+		up.Path = "/v1beta/models/" + escaped + ":generateContent"
+	}
+	return up, nil
+}
+
+// geminiContentParts 将 OpenAI 用户消息内容转换为 Gemini parts。
+func geminiContentParts(raw json.RawMessage) ([]geminiPart, error) {
+	parts, err := ParseContent(raw)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析消息内容: %w", err)
+	}
+	out := make([]geminiPart, 0, len(parts))
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			// Gemini 拒绝空 text part
+			if p.Text == "" {
+				continue
+			}
+			out = append(out, geminiPart{Text: p.Text})
+		case "image_url":
+			if p.ImageURL == nil {
+				continue
+			}
+			if mimeType, data, ok := ParseDataURL(p.ImageURL.URL); ok {
+				out = append(out, geminiPart{InlineData: &geminiBlob{MimeType: mimeType, Data: data}})
+			} else {
+				out = append(out, geminiPart{FileData: &geminiFileData{MimeType: guessMimeType(p.ImageURL.URL), FileURI: p.ImageURL.URL}})
+			}
+		}
+	}
+	return out, nil
+}
+
+// guessMimeType 根据 URL 扩展名推断 MIME 类型，无法判断时按 JPEG 处理。
+func guessMimeType(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return strings.Split(t, ";")[0]
+		}
+	}
+	return "image/jpeg"
+}
+
+// appendGeminiContent 追加内容，相邻同角色内容会被合并。
+func appendGeminiContent(contents []geminiContent, role string, parts []geminiPart) []geminiContent {
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, geminiContent{Role: role, Parts: parts})
+}
+
+// sanitizeGeminiSchema 递归移除 Gemini 不支持的 Schema 关键字。
+func sanitizeGeminiSchema(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+	var walk func(any)
+	walk = func(node any) {
+		switch n := node.(type) {
+		case map[string]any:
+			for _, key := range geminiUnsupportedSchemaKeys {
+				delete(n, key)
+			}
+			for _, child := range n {
+				walk(child)
+			}
+		case []any:
+			for _, child := range n {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+func geminiToolChoice(raw json.RawMessage) *geminiToolConfig {
+	if len(raw) == 0 {
+		return nil
+	}
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "auto":
+			return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{Mode: "AUTO"}}
+		case "none":
+			return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{Mode: "NONE"}}
+		case "required":
+			return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{Mode: "ANY"}}
+		}
+		return nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err == nil && named.Function.Name != "" {
+		return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{
+			Mode:                 "ANY",
+			AllowedFunctionNames: []string{named.Function.Name},
+		}}
+	}
+	return nil
+}
+
+func geminiFinishReason(reason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// geminiCandidateDelta 将候选内容转换为 OpenAI 消息增量，toolIndex 用于为工具调用分配连续索引。
+func geminiCandidateDelta(cand geminiCandidate, toolIndex *int, withIndex bool) ResponseDelta {
+	var text, reasoning strings.Builder
+	var toolCalls []ToolCall
+	for _, part := range cand.Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			args := string(part.FunctionCall.Args)
+			if args == "" {
+				args = "{}"
+			}
+			tc := ToolCall{
+				ID:       fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), *toolIndex),
+				Type:     "function",
+				Function: ToolCallFunction{Name: part.FunctionCall.Name, Arguments: args},
+			}
+			if withIndex {
+				idx := *toolIndex
+				tc.Index = &idx
+			}
+			*toolIndex++
+			toolCalls = append(toolCalls, tc)
+		case part.Thought:
+			reasoning.WriteString(part.Text)
+		default:
+			text.WriteString(part.Text)
+		}
+	}
+	delta := ResponseDelta{ReasoningContent: reasoning.String(), ToolCalls: toolCalls}
+	if text.Len() > 0 {
+		delta.Content = strPtr(text.String())
+	}
+	return delta
+}
+
+func (t *geminiTranslator) ConvertResponse(body []byte, model string) ([]byte, error) {
+	var resp geminiResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("无法解析 Gemini 响应: %w", err)
+	}
+	if resp.ModelVersion != "" {
+		model = resp.ModelVersion
+	}
+
+	out := ChatCompletionResponse{
+		ID:      "chatcmpl-" + resp.ResponseID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []ChatChoice{},
+		Usage:   resp.UsageMetadata.toOpenAI(),
+	}
+	for i, cand := range resp.Candidates {
+		toolIndex := 0
+		msg := geminiCandidateDelta(cand, &toolIndex, false)
+		msg.Role = "assistant"
+		if msg.Content == nil && len(msg.ToolCalls) == 0 {
+			msg.Content = strPtr("")
+		}
+		out.Choices = append(out.Choices, ChatChoice{
+			Index:        i,
+			Message:      msg,
+			FinishReason: strPtr(geminiFinishReason(cand.FinishReason, len(msg.ToolCalls) > 0)),
+		})
+	}
+	return json.Marshal(out)
+}
+
+func (t *geminiTranslator) ConvertStream(r io.Reader, w io.Writer, model string, includeUsage bool) error {
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+	var usage *geminiUsage
+	finishReason := ""
+	toolIndex := 0
+	started := false
+	failed := false
+
+	chunk := func(delta ResponseDelta, finish *string) ChatCompletionChunk {
+		return ChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []ChunkChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+		}
+	}
+
+	err := readSSE(r, func(ev sseEvent) error {
+		if ev.Data == "" {
+			return nil
+		}
+		var resp geminiResponse
+		if err := json.Unmarshal([]byte(ev.Data), &resp); err != nil {
+			return fmt.Errorf("无法解析 Gemini 流事件: %w", err)
+		}
+		if resp.Error != nil {
+			msg, errType := resp.Error.Message, strings.ToLower(resp.Error.Status)
+			if msg == "" {
+				msg = "upstream stream error"
+			}
+			if errType == "" {
+				errType = "api_error"
+			}
+			if _, err := w.Write([]byte("data: " + string(NewError(msg, errType)) + "\n\n")); err != nil {
+				return err
+			}
+			failed = true
+			return io.EOF
+		}
+		if resp.ResponseID != "" {
+			id = "chatcmpl-" + resp.ResponseID
+		}
+		if resp.ModelVersion != "" {
+			model = resp.ModelVersion
+		}
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+
+		if !started {
+			started = true
+			if err := writeSSEData(w, chunk(ResponseDelta{Role: "assistant", Content: strPtr("")}, nil)); err != nil {
+				return err
+			}
+		}
+
+		if len(resp.Candidates) == 0 {
+			return nil
+		}
+		cand := resp.Candidates[0]
+		hadTools := toolIndex > 0
+		delta := geminiCandidateDelta(cand, &toolIndex, true)
+		if cand.FinishReason != "" {
+			finishReason = geminiFinishReason(cand.FinishReason, hadTools || len(delta.ToolCalls) > 0)
+		}
+		if delta.Content == nil && delta.ReasoningContent == "" && len(delta.ToolCalls) == 0 {
+			return nil
+		}
+		return writeSSEData(w, chunk(delta, nil))
+	})
+	if err != nil {
+		return err
+	}
+	if failed {
+		return writeSSEDone(w)
+	}
+
+	if finishReason == "" {
+		finishReason = geminiFinishReason("", toolIndex > 0)
+	}
+	if err := writeSSEData(w, chunk(ResponseDelta{}, strPtr(finishReason))); err != nil {
+		return err
+	}
+	if includeUsage && usage != nil {
+		final := chunk(ResponseDelta{}, nil)
+		final.Choices = []ChunkChoice{}
+		final.Usage = usage.toOpenAI()
+		if err := writeSSEData(w, final); err != nil {
+			return err
+		}
+	}
+	return writeSSEDone(w)
+}
+
+func (t *geminiTranslator) ConvertError(status int, body []byte) []byte {
+	var e geminiError
+	if err := json.Unmarshal(body, &e); err == nil && e.Error.Message != "" {
+		return NewError(e.Error.Message, strings.ToLower(e.Error.Status))
+	}
+	// 流式接口的错误可能以数组形式返回
+	var list []geminiError
+	if err := json.Unmarshal(body, &list); err == nil && len(list) > 0 && list[0].Error.Message != "" {
+		return NewError(list[0].Error.Message, strings.ToLower(list[0].Error.Status))
+	}
+	return NewError(fmt.Sprintf("upstream returned status %d: %s", status, strings.TrimSpace(string(body))), "upstream_error")
+}
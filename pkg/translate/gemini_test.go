@@ -0,0 +1,178 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// geminiCallID 匹配按时间生成的工具调用 ID，比较前统一替换为 call_<序号>
+var geminiCallID = regexp.MustCompile(`"call_\d+_(\d+)"`)
+
+func normalizeCallIDs(data []byte) []byte {
+	return geminiCallID.ReplaceAll(data, []byte(`"call_$1"`))
+}
+
+func TestGeminiConvertRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		want     string
+		path     string
+		rawQuery string
+	}{
+		{
+			name:    "system, tools, tool_calls and images",
+			request: "request.openai.json",
+			want:    "request.gemini.json",
+			path:    "/v1beta/models/gemini-2.0-flash:generateContent",
+		},
+		{
+			name:     "empty text parts",
+			request:  "request_empty_text.openai.json",
+			want:     "request_empty_text.gemini.json",
+			path:     "/v1beta/models/gemini-2.0-flash:streamGenerateContent",
+			rawQuery: "alt=sse",
+		},
+	}
+
+	tr := &geminiTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ChatCompletionRequest
+			if err := json.Unmarshal(readFixture(t, "gemini", tt.request), &req); err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			header.Set("Authorization", "Bearer AIza-test")
+
+			up, err := tr.ConvertRequest(&req, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if up.Path != tt.path || up.RawQuery != tt.rawQuery {
+				t.Errorf("Path = %q, RawQuery = %q", up.Path, up.RawQuery)
+			}
+			assertJSONEqual(t, up.Body, readFixture(t, "gemini", tt.want))
+
+			if got := header.Get("x-goog-api-key"); got != "AIza-test" {
+				t.Errorf("x-goog-api-key = %q", got)
+			}
+			if got := header.Get("Authorization"); got != "" {
+				t.Errorf("Authorization 未移除: %q", got)
+			}
+		})
+	}
+}
+
+// OAuth 访问令牌应保留在 Authorization 中。
+func TestGeminiConvertRequestOAuthToken(t *testing.T) {
+	req := ChatCompletionRequest{Model: "gemini-2.0-flash", Messages: []ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}}}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer ya29.token")
+	if _, err := (&geminiTranslator{}).ConvertRequest(&req, header); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("Authorization"); got != "Bearer ya29.token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := header.Get("x-goog-api-key"); got != "" {
+		t.Errorf("x-goog-api-key = %q", got)
+	}
+}
+
+func TestGeminiConvertResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream string
+		want     string
+	}{
+		{name: "text and function calls", upstream: "response_tool_use.gemini.json", want: "response_tool_use.openai.json"},
+		{name: "thinking and max_tokens", upstream: "response_thinking.gemini.json", want: "response_thinking.openai.json"},
+	}
+
+	tr := &geminiTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tr.ConvertResponse(readFixture(t, "gemini", tt.upstream), "fallback-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, normalizeCallIDs(got), readFixture(t, "gemini", tt.want), "created")
+		})
+	}
+}
+
+func TestGeminiConvertStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream string
+		want     string
+	}{
+		{name: "text and function call", upstream: "stream_tool_use.gemini.sse", want: "stream_tool_use.openai.sse"},
+		// 中途出错时只发送错误事件和 [DONE]，不应再补发 finish_reason
+		{name: "error event", upstream: "stream_error.gemini.sse", want: "stream_error.openai.sse"},
+	}
+
+	tr := &geminiTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tr.ConvertStream(bytes.NewReader(readFixture(t, "gemini", tt.upstream)), &out, "fallback-model", true); err != nil {
+				t.Fatal(err)
+			}
+			got, want := sseData(t, normalizeCallIDs(out.Bytes())), sseData(t, readFixture(t, "gemini", tt.want))
+			if len(got) != len(want) {
+				t.Fatalf("事件数 = %d, want %d\n%s", len(got), len(want), out.String())
+			}
+			for i := range want {
+				if want[i] == "[DONE]" || got[i] == "[DONE]" {
+					if got[i] != want[i] {
+						t.Errorf("事件 %d = %s, want %s", i, got[i], want[i])
+					}
+					continue
+				}
+				assertJSONEqual(t, []byte(got[i]), []byte(want[i]), "created")
+			}
+		})
+	}
+}
+
+func TestGeminiConvertError(t *testing.T) {
+	tr := &geminiTranslator{}
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{
+			name:   "gemini error",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"code":400,"message":"API key not valid.","status":"INVALID_ARGUMENT"}}`,
+			want:   `{"error":{"message":"API key not valid.","type":"invalid_argument","param":null,"code":null}}`,
+		},
+		{
+			name:   "streaming error array",
+			status: http.StatusTooManyRequests,
+			body:   `[{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}]`,
+			want:   `{"error":{"message":"Resource has been exhausted","type":"resource_exhausted","param":null,"code":null}}`,
+		},
+		{
+			name:   "non-json body",
+			status: http.StatusBadGateway,
+			body:   "bad gateway\n",
+			want:   `{"error":{"message":"upstream returned status 502: bad gateway","type":"upstream_error","param":null,"code":null}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tr.ConvertError(tt.status, []byte(tt.body))
+			if compactJSON(got) != tt.want {
+				t.Errorf("ConvertError = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
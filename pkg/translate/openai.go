@@ -0,0 +1,229 @@
+package translate
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ========================================
+// OpenAI Chat Completions 请求/响应结构
+// ========================================
+
+// ChatCompletionRequest 是 OpenAI /v1/chat/completions 的请求体。
+// 只声明转换需要用到的字段，其余字段会被忽略。
+type ChatCompletionRequest struct {
+	Model               string          `json:"model"`
+	Messages            []ChatMessage   `json:"messages"`
+	MaxTokens           *int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	TopP                *float64        `json:"top_p,omitempty"`
+	Stop                json.RawMessage `json:"stop,omitempty"` // string 或 []string
+	Stream              bool            `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
+	Tools               []Tool          `json:"tools,omitempty"`
+	ToolChoice          json.RawMessage `json:"tool_choice,omitempty"` // string 或 object
+	ParallelToolCalls   *bool           `json:"parallel_tool_calls,omitempty"`
+	User                string          `json:"user,omitempty"`
+}
+
+// StreamOptions 对应 stream_options 字段。
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
+// ChatMessage 是单条对话消息，Content 可能是字符串或内容块数组。
+type ChatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// ContentPart 是多模态消息中的一个内容块。
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 既可以是 http(s) 地址，也可以是 data:<mime>;base64,<data> 形式的内联图片。
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Tool 是函数工具定义。
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 描述一个可供模型调用的函数。
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall 是模型发起的一次函数调用。流式响应中 Index 用于拼接增量。
+type ToolCall struct {
+	Index    *int             `json:"index,omitempty"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 是函数调用的名称与 JSON 字符串参数。
+type ToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionResponse 是非流式响应体。
+type ChatCompletionResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *Usage       `json:"usage,omitempty"`
+}
+
+// ChatChoice 是非流式响应中的一个候选结果。
+type ChatChoice struct {
+	Index        int           `json:"index"`
+	Message      ResponseDelta `json:"message"`
+	FinishReason *string       `json:"finish_reason"`
+}
+
+// ChatCompletionChunk 是流式响应中的一个 SSE 数据块。
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// ChunkChoice 是流式数据块中的一个候选增量。
+type ChunkChoice struct {
+	Index        int           `json:"index"`
+	Delta        ResponseDelta `json:"delta"`
+	FinishReason *string       `json:"finish_reason"`
+}
+
+// ResponseDelta 同时用作完整消息（message）与流式增量（delta）。
+type ResponseDelta struct {
+	Role             string     `json:"role,omitempty"`
+	Content          *string    `json:"content,omitempty"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Usage 是 token 用量统计。
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ErrorResponse 是 OpenAI 风格的错误响应体。
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail 是错误详情。
+type ErrorDetail struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// ========================================
+// 辅助函数
+// ========================================
+
+// ParseContent 将消息的 Content 统一解析为内容块数组。
+// 字符串内容会被包装为单个 text 块，null 或缺失返回空切片。
+func ParseContent(raw json.RawMessage) ([]ContentPart, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return []ContentPart{{Type: "text", Text: s}}, nil
+	}
+	var parts []ContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// ContentText 拼接消息中所有 text 块的文本，忽略图片等其他类型。
+func ContentText(raw json.RawMessage) string {
+	parts, err := ParseContent(raw)
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, p := range parts {
+		if p.Type == "text" {
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String()
+}
+
+// ParseStop 将 stop 字段（字符串或字符串数组）解析为字符串切片。
+func ParseStop(raw json.RawMessage) []string {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil
+		}
+		return []string{single}
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	return nil
+}
+
+// ParseDataURL 解析 data:<mime>;base64,<data> 形式的 URL，返回 MIME 类型和 base64 数据。
+func ParseDataURL(u string) (mimeType, data string, ok bool) {
+	if !strings.HasPrefix(u, "data:") {
+		return "", "", false
+	}
+	meta, payload, found := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), payload, true
+}
+
+// NewError 构造一个 OpenAI 风格的错误响应体。
+func NewError(message, errType string) []byte {
+	body, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{Message: message, Type: errType}})
+	return body
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,134 @@
+package translate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ModeOpenAI 表示代理对外暴露 OpenAI Chat Completions 兼容接口。
+const ModeOpenAI = "openai"
+
+// UpstreamRequest 是转换后发往上游的请求。
+type UpstreamRequest struct {
+	Path     string // 相对于代理目标地址的路径，例如 /v1/messages
+	RawQuery string // 需要附加的查询参数，可为空
+	Body     []byte
+}
+
+// Translator 在 OpenAI Chat Completions 格式与某个厂商的原生格式之间互相转换。
+type Translator interface {
+	// ConvertRequest 将 OpenAI 请求转换为上游请求，header 为即将发往上游的请求头，可就地修改（例如鉴权头）。
+	ConvertRequest(req *ChatCompletionRequest, header http.Header) (*UpstreamRequest, error)
+	// ConvertResponse 将上游非流式响应体转换为 OpenAI 响应体。
+	ConvertResponse(body []byte, model string) ([]byte, error)
+	// ConvertStream 逐事件读取上游 SSE 流并以 OpenAI chunk 格式写出，结束时写出 [DONE]。
+	ConvertStream(r io.Reader, w io.Writer, model string, includeUsage bool) error
+	// ConvertError 将上游错误响应体转换为 OpenAI 错误响应体。
+	ConvertError(status int, body []byte) []byte
+}
+
+// New 根据对外模式和上游厂商创建转换器。
+func New(mode, vendor string) (Translator, error) {
+	if mode != ModeOpenAI {
+		return nil, fmt.Errorf("不支持的转换模式: %s", mode)
+	}
+	switch strings.ToLower(vendor) {
+	case "anthropic":
+		return &anthropicTranslator{}, nil
+	case "google", "gemini":
+		return &geminiTranslator{}, nil
+	default:
+		return nil, fmt.Errorf("转换模式 %s 不支持厂商 %q", mode, vendor)
+	}
+}
+
+// IsChatCompletionsPath 判断去掉代理前缀后的路径是否为 Chat Completions 端点。
+func IsChatCompletionsPath(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	return path == "/v1/chat/completions" || path == "/chat/completions"
+}
+
+// ========================================
+// SSE 读写
+// ========================================
+
+// sseEvent 是一个解析后的 SSE 事件。
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE 逐个读取 SSE 事件并回调 fn，fn 返回 io.EOF 时提前结束且不视为错误。
+func readSSE(r io.Reader, fn func(ev sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var ev sseEvent
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 && ev.Event == "" {
+			return nil
+		}
+		ev.Data = strings.Join(data, "\n")
+		err := fn(ev)
+		ev = sseEvent{}
+		data = data[:0]
+		return err
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			if err := dispatch(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // 注释行（心跳）
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := dispatch(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// writeSSEData 将 v 序列化为 JSON 并写出一个 data 事件。
+func writeSSEData(w io.Writer, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("data: ")
+	buf.Write(payload)
+	buf.WriteString("\n\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeSSEDone 写出 OpenAI 流结束标记。
+func writeSSEDone(w io.Writer) error {
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
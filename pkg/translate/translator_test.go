@@ -0,0 +1,66 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// 夹具位于 testdata/<厂商>：*.openai.* 为客户端侧（OpenAI 格式），其余为上游侧。
+
+func readFixture(t *testing.T, vendor, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", vendor, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// assertJSONEqual 按语义比较两段 JSON，忽略 ignore 中列出的顶层字段（例如随时间变化的 created）。
+func assertJSONEqual(t *testing.T, got, want []byte, ignore ...string) {
+	t.Helper()
+	g, w := decodeJSON(t, got, ignore), decodeJSON(t, want, ignore)
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("JSON 不一致\n got: %s\nwant: %s", compactJSON(got), compactJSON(want))
+	}
+}
+
+func decodeJSON(t *testing.T, data []byte, ignore []string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("无法解析 JSON %q: %v", data, err)
+	}
+	if m, ok := v.(map[string]any); ok {
+		for _, k := range ignore {
+			delete(m, k)
+		}
+	}
+	return v
+}
+
+func compactJSON(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
+
+// sseData 按顺序返回 SSE 流中每个事件的 data 内容。
+func sseData(t *testing.T, stream []byte) []string {
+	t.Helper()
+	var out []string
+	err := readSSE(bytes.NewReader(stream), func(ev sseEvent) error {
+		out = append(out, ev.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
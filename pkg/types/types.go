@@ -1,5 +1,11 @@
 package types
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // RequestStat 定义一个结构体来传递统计数据
 // 这个结构体现在被 middleware 和 db 包共享
 type RequestStat struct {
@@ -8,4 +14,76 @@ type RequestStat struct {
 	RequestURI   string
 	StatusCode   int
 	ResponseTime int64 // 添加响应时间字段，单位为毫秒
+	// 以下字段来自上游响应中的用量与客户端鉴权，无法获取时为零值
+	Model            string
+	ClientKey        string // 客户端密钥名称
+	Team             string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	// 以下字段描述请求本身，由统计中间件填写
+	Method        string
+	ClientIP      string // 按 X-Forwarded-For / X-Real-IP 解析出的客户端地址
+	UserAgent     string
+	RequestBytes  int64  // 请求体字节数
+	ResponseBytes int64  // 响应体字节数
+	Upstream      string // 转发的上游地址
+	RequestID     string // 代理请求 ID，与响应头 X-Request-Id 一致
+	// Body 采样记录的请求/响应体，未开启或未被采样时为 nil
+	Body *RequestBody
+}
+
+// RequestBody 是已脱敏的请求与响应体，Compressed 为 true 时内容为 gzip 数据。
+type RequestBody struct {
+	ContentType       string // 响应的 Content-Type
+	RequestHeaders    string // 已脱敏的客户端请求头（JSON）
+	Request           []byte
+	Response          []byte
+	RequestTruncated  bool
+	ResponseTruncated bool
+	Compressed        bool
+	ExpiresAt         time.Time
+}
+
+// ClientKey 表示一个客户端密钥，可能来自配置文件或 SQLite。
+type ClientKey struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	KeyPrefix    string    `json:"key_prefix"`    // 密钥前几位，便于识别，完整密钥只保存哈希
+	AllowedPaths []string  `json:"allowed_paths"` // 允许访问的代理路径，为空表示全部
+	ExpiresAt    time.Time `json:"expires_at"`    // 零值表示永不过期
+	Disabled     bool      `json:"disabled"`
+	Source       string    `json:"source"` // config 或 db
+	Team         string    `json:"team"`   // 所属团队，用于按团队汇总预算
+	CreatedAt    time.Time `json:"created_at"`
+	// 限流配置，0 表示不限制
+	RateLimitRPM        int `json:"rate_limit_rpm"`
+	RateLimitTPM        int `json:"rate_limit_tpm"`
+	RateLimitConcurrent int `json:"rate_limit_concurrent"`
+}
+
+// Identity 返回密钥的唯一标识，用于限流等按密钥聚合的场景。
+func (k *ClientKey) Identity() string {
+	if k.Source == "db" {
+		return fmt.Sprintf("db:%d", k.ID)
+	}
+	return "config:" + k.Name
+}
+
+// Expired 判断密钥是否已过期。
+func (k *ClientKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// AllowsPath 判断密钥是否允许访问指定的代理路径。
+func (k *ClientKey) AllowsPath(proxyPath string) bool {
+	if len(k.AllowedPaths) == 0 {
+		return true
+	}
+	for _, p := range k.AllowedPaths {
+		if p == "*" || strings.TrimSuffix(p, "/") == proxyPath {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// EventStreamContentType 是 AWS 二进制事件流（Bedrock invoke-with-response-stream、converse-stream）的类型
+const EventStreamContentType = "application/vnd.amazon.eventstream"
+
+// decodeEventStream 依次返回事件流中每条消息的负载，遇到不完整或损坏的消息时停止。
+// 消息格式：总长度(4) 头部长度(4) 前导 CRC(4) 头部 负载 消息 CRC(4)。
+func decodeEventStream(data []byte) [][]byte {
+	var payloads [][]byte
+	for len(data) >= 16 {
+		total := int(binary.BigEndian.Uint32(data[0:4]))
+		headersLen := int(binary.BigEndian.Uint32(data[4:8]))
+		if total < 16+headersLen || total > len(data) {
+			break
+		}
+		payloads = append(payloads, data[12+headersLen:total-4])
+		data = data[total:]
+	}
+	return payloads
+}
+
+// extractEventStream 提取 Bedrock 流式响应中的用量。invoke-with-response-stream 的负载为
+// {"bytes": base64(模型原生事件)}，converse-stream 的 metadata 事件直接携带 usage。
+func extractEventStream(body []byte) Usage {
+	var u Usage
+	for _, raw := range decodeEventStream(body) {
+		var chunk struct {
+			Bytes string `json:"bytes"`
+		}
+		if json.Unmarshal(raw, &chunk) == nil && chunk.Bytes != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(chunk.Bytes); err == nil {
+				raw = decoded
+			}
+		}
+		var p payload
+		if json.Unmarshal(raw, &p) == nil {
+			u.merge(p)
+		}
+	}
+	return u
+}
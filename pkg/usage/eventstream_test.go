@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// eventMessage 按 AWS 事件流格式编码一条消息，headers 为已编码的头部。
+func eventMessage(headers, payload []byte) []byte {
+	total := 16 + len(headers) + len(payload)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(total))
+	binary.Write(&buf, binary.BigEndian, uint32(len(headers)))
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(headers)
+	buf.Write(payload)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// eventType 编码 :event-type 字符串头部。
+func eventType(name string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(len(":event-type")))
+	buf.WriteString(":event-type")
+	buf.WriteByte(7)
+	binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+	buf.WriteString(name)
+	return buf.Bytes()
+}
+
+// invokeChunk 是 invoke-with-response-stream 的 chunk 事件，负载为 base64 编码的模型原生事件。
+func invokeChunk(event string) []byte {
+	payload := `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(event)) + `"}`
+	return eventMessage(eventType("chunk"), []byte(payload))
+}
+
+func TestExtractEventStream(t *testing.T) {
+	invoke := bytes.Join([][]byte{
+		invokeChunk(`{"type":"message_start","message":{"model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":21,"output_tokens":1}}}`),
+		invokeChunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`),
+		invokeChunk(`{"type":"message_delta","usage":{"output_tokens":12}}`),
+		invokeChunk(`{"type":"message_stop","amazon-bedrock-invocationMetrics":{"inputTokenCount":21,"outputTokenCount":12,"invocationLatency":800}}`),
+	}, nil)
+	converse := bytes.Join([][]byte{
+		eventMessage(eventType("messageStart"), []byte(`{"role":"assistant"}`)),
+		eventMessage(eventType("contentBlockDelta"), []byte(`{"contentBlockIndex":0,"delta":{"text":"hi"}}`)),
+		eventMessage(eventType("messageStop"), []byte(`{"stopReason":"end_turn"}`)),
+		eventMessage(eventType("metadata"), []byte(`{"usage":{"inputTokens":30,"outputTokens":8,"totalTokens":38},"metrics":{"latencyMs":500}}`)),
+	}, nil)
+	first := invokeChunk(`{"type":"message_start","message":{"model":"claude-3-haiku","usage":{"input_tokens":5}}}`)
+	second := invokeChunk(`{"type":"message_delta","usage":{"output_tokens":9}}`)
+
+	corrupt := append([]byte{}, second...)
+	binary.BigEndian.PutUint32(corrupt[4:8], 1<<20)
+
+	tests := []struct {
+		name string
+		body []byte
+		want Usage
+	}{
+		{name: "invoke with response stream", body: invoke, want: Usage{Model: "claude-3-5-sonnet-20241022", PromptTokens: 21, CompletionTokens: 12}},
+		{name: "converse stream metadata", body: converse, want: Usage{PromptTokens: 30, CompletionTokens: 8}},
+		{name: "truncated last message", body: append(append([]byte{}, first...), second[:len(second)-5]...), want: Usage{Model: "claude-3-haiku", PromptTokens: 5}},
+		{name: "corrupt header length stops decoding", body: append(append([]byte{}, first...), corrupt...), want: Usage{Model: "claude-3-haiku", PromptTokens: 5}},
+		{name: "shorter than prelude", body: first[:10], want: Usage{}},
+		{name: "empty", body: nil, want: Usage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(tt.body, EventStreamContentType, true)
+			if got != tt.want {
+				t.Errorf("Extract = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEventStream(t *testing.T) {
+	body := append(eventMessage(eventType("chunk"), []byte(`{"a":1}`)), eventMessage(nil, []byte(`{"b":2}`))...)
+	got := decodeEventStream(body)
+	if len(got) != 2 || string(got[0]) != `{"a":1}` || string(got[1]) != `{"b":2}` {
+		t.Errorf("decodeEventStream = %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+package usage
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"go-proxy/pkg/config"
+)
+
+type pricePattern struct {
+	pattern string
+	re      *regexp.Regexp
+	price   config.ModelPrice
+}
+
+// PriceTable 根据模型名查找单价，精确匹配优先，其次是最长的通配符模式。
+type PriceTable struct {
+	exact    map[string]config.ModelPrice
+	patterns []pricePattern
+}
+
+// NewPriceTable 根据配置创建价格表，模型名不区分大小写。
+func NewPriceTable(prices map[string]config.ModelPrice) *PriceTable {
+	t := &PriceTable{exact: make(map[string]config.ModelPrice, len(prices))}
+	for model, price := range prices {
+		if strings.ContainsAny(model, "*?") {
+			quoted := regexp.QuoteMeta(model)
+			quoted = strings.ReplaceAll(quoted, `\*`, `.*`)
+			quoted = strings.ReplaceAll(quoted, `\?`, `.`)
+			t.patterns = append(t.patterns, pricePattern{
+				pattern: model,
+				re:      regexp.MustCompile(`(?i)^` + quoted + `$`),
+				price:   price,
+			})
+			continue
+		}
+		t.exact[strings.ToLower(model)] = price
+	}
+	sort.Slice(t.patterns, func(i, j int) bool { return len(t.patterns[i].pattern) > len(t.patterns[j].pattern) })
+	return t
+}
+
+// Lookup 返回模型的单价，未配置时返回 false。
+func (t *PriceTable) Lookup(model string) (config.ModelPrice, bool) {
+	if t == nil || model == "" {
+		return config.ModelPrice{}, false
+	}
+	if price, ok := t.exact[strings.ToLower(model)]; ok {
+		return price, true
+	}
+	for _, p := range t.patterns {
+		if p.re.MatchString(model) {
+			return p.price, true
+		}
+	}
+	return config.ModelPrice{}, false
+}
+
+// Cost 计算一次请求的费用，单价按每百万 token 计。
+func (t *PriceTable) Cost(u Usage) float64 {
+	price, ok := t.Lookup(u.Model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6
+}
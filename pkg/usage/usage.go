@@ -0,0 +1,163 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Usage 是从上游响应中提取的 token 用量。
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // 根据价格表计算的费用
+}
+
+// TotalTokens 返回输入与输出 token 之和。
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// IsZero 判断是否没有提取到任何用量。
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0
+}
+
+// usageFields 覆盖 OpenAI Chat/Responses、Anthropic 和 Gemini 的用量字段。
+type usageFields struct {
+	// OpenAI Chat Completions
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// OpenAI Responses / Anthropic
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	// Bedrock Converse
+	InputTokensCamel  int `json:"inputTokens"`
+	OutputTokensCamel int `json:"outputTokens"`
+	// Bedrock 流式响应最后一个事件中的 amazon-bedrock-invocationMetrics
+	InputTokenCount  int `json:"inputTokenCount"`
+	OutputTokenCount int `json:"outputTokenCount"`
+	// Gemini usageMetadata
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+}
+
+// payload 是响应体或单个 SSE 事件中与用量相关的字段。
+type payload struct {
+	Model         string       `json:"model"`
+	ModelVersion  string       `json:"modelVersion"`
+	Usage         *usageFields `json:"usage"`
+	UsageMetadata *usageFields `json:"usageMetadata"`
+	// Bedrock 在流式响应的最后一个事件中附带的调用统计
+	InvocationMetrics *usageFields `json:"amazon-bedrock-invocationMetrics"`
+	// Anthropic message_start 事件把用量放在 message 内
+	Message *struct {
+		Model string       `json:"model"`
+		Usage *usageFields `json:"usage"`
+	} `json:"message"`
+	// OpenAI Responses API 的 response.completed 事件
+	Response *struct {
+		Model string       `json:"model"`
+		Usage *usageFields `json:"usage"`
+	} `json:"response"`
+}
+
+// merge 将一个响应体/事件中的用量合并到 u。流式响应中后出现的值覆盖先出现的值，
+// Anthropic 的输入 token 只在 message_start 中出现，因此非零才覆盖。
+func (u *Usage) merge(p payload) {
+	switch {
+	case p.Model != "":
+		u.Model = p.Model
+	case p.ModelVersion != "":
+		u.Model = p.ModelVersion
+	case p.Message != nil && p.Message.Model != "":
+		u.Model = p.Message.Model
+	case p.Response != nil && p.Response.Model != "":
+		u.Model = p.Response.Model
+	}
+
+	apply := func(f *usageFields) {
+		if f == nil {
+			return
+		}
+		prompt := f.PromptTokens + f.InputTokens + f.CacheCreationInputTokens + f.CacheReadInputTokens +
+			f.InputTokensCamel + f.InputTokenCount + f.PromptTokenCount
+		completion := f.CompletionTokens + f.OutputTokens + f.OutputTokensCamel + f.OutputTokenCount +
+			f.CandidatesTokenCount + f.ThoughtsTokenCount
+		if prompt > 0 {
+			u.PromptTokens = prompt
+		}
+		if completion > 0 {
+			u.CompletionTokens = completion
+		}
+	}
+	apply(p.Usage)
+	apply(p.UsageMetadata)
+	apply(p.InvocationMetrics)
+	if p.Message != nil {
+		apply(p.Message.Usage)
+	}
+	if p.Response != nil {
+		apply(p.Response.Usage)
+	}
+}
+
+// Extract 从响应体中提取用量，支持 JSON、SSE 与 AWS 事件流。complete 为 false 表示 body 只是截断后的片段，
+// 此时退化为在片段中搜索最后一个用量对象。
+func Extract(body []byte, contentType string, complete bool) Usage {
+	var u Usage
+	if strings.HasPrefix(contentType, EventStreamContentType) {
+		return extractEventStream(body)
+	}
+	if strings.HasPrefix(contentType, "text/event-stream") || bytes.HasPrefix(bytes.TrimSpace(body), []byte("data:")) || bytes.HasPrefix(bytes.TrimSpace(body), []byte("event:")) {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			data, ok := bytes.CutPrefix(line, []byte("data:"))
+			if !ok {
+				continue
+			}
+			var p payload
+			if json.Unmarshal(bytes.TrimSpace(data), &p) == nil {
+				u.merge(p)
+			}
+		}
+		return u
+	}
+
+	if complete {
+		var p payload
+		if json.Unmarshal(body, &p) == nil {
+			u.merge(p)
+			return u
+		}
+		// Gemini 非流式 streamGenerateContent 会返回 JSON 数组
+		var list []payload
+		if json.Unmarshal(body, &list) == nil {
+			for _, p := range list {
+				u.merge(p)
+			}
+			return u
+		}
+	}
+
+	for _, field := range []string{`"usage"`, `"usageMetadata"`} {
+		if idx := bytes.LastIndex(body, []byte(field)); idx >= 0 {
+			rest := body[idx+len(field):]
+			rest = bytes.TrimLeft(rest, " \t\r\n:")
+			var f usageFields
+			if json.NewDecoder(bytes.NewReader(rest)).Decode(&f) == nil {
+				var p payload
+				p.Usage = &f
+				u.merge(p)
+			}
+		}
+	}
+	return u
+}
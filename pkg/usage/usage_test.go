@@ -0,0 +1,183 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"go-proxy/pkg/config"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		complete    bool
+		body        string
+		want        Usage
+	}{
+		{
+			name:        "openai chat",
+			contentType: "application/json",
+			complete:    true,
+			body:        `{"model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":34,"total_tokens":46}}`,
+			want:        Usage{Model: "gpt-4o-2024-08-06", PromptTokens: 12, CompletionTokens: 34},
+		},
+		{
+			name:        "anthropic with cache tokens",
+			contentType: "application/json",
+			complete:    true,
+			body:        `{"model":"claude-sonnet-4","usage":{"input_tokens":10,"cache_creation_input_tokens":100,"cache_read_input_tokens":1000,"output_tokens":7}}`,
+			want:        Usage{Model: "claude-sonnet-4", PromptTokens: 1110, CompletionTokens: 7},
+		},
+		{
+			name:        "bedrock converse",
+			contentType: "application/json",
+			complete:    true,
+			body:        `{"output":{},"usage":{"inputTokens":5,"outputTokens":6,"totalTokens":11}}`,
+			want:        Usage{PromptTokens: 5, CompletionTokens: 6},
+		},
+		{
+			name:        "gemini with thoughts",
+			contentType: "application/json",
+			complete:    true,
+			body:        `{"modelVersion":"gemini-2.5-pro","usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":3,"thoughtsTokenCount":20}}`,
+			want:        Usage{Model: "gemini-2.5-pro", PromptTokens: 8, CompletionTokens: 23},
+		},
+		{
+			name:        "gemini json array",
+			contentType: "application/json",
+			complete:    true,
+			body: `[{"modelVersion":"gemini-2.0-flash","usageMetadata":{"promptTokenCount":8}},` +
+				`{"modelVersion":"gemini-2.0-flash","usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":9}}]`,
+			want: Usage{Model: "gemini-2.0-flash", PromptTokens: 8, CompletionTokens: 9},
+		},
+		{
+			name:        "openai sse with include_usage",
+			contentType: "text/event-stream",
+			body: "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\n" +
+				"data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":4}}\n\n" +
+				"data: [DONE]\n\n",
+			want: Usage{Model: "gpt-4o", PromptTokens: 3, CompletionTokens: 4},
+		},
+		{
+			// 输入 token 只出现在 message_start 中，message_delta 只更新输出 token
+			name:        "anthropic sse",
+			contentType: "text/event-stream",
+			body: "event: message_start\n" +
+				"data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4\",\"usage\":{\"input_tokens\":25,\"output_tokens\":1}}}\n\n" +
+				"event: content_block_delta\n" +
+				"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+				"event: message_delta\n" +
+				"data: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":15}}\n\n",
+			want: Usage{Model: "claude-sonnet-4", PromptTokens: 25, CompletionTokens: 15},
+		},
+		{
+			name: "openai responses sse sniffed without content type",
+			body: "event: response.created\n" +
+				"data: {\"type\":\"response.created\",\"response\":{\"model\":\"gpt-4.1\",\"usage\":null}}\n\n" +
+				"event: response.completed\n" +
+				"data: {\"type\":\"response.completed\",\"response\":{\"model\":\"gpt-4.1\",\"usage\":{\"input_tokens\":9,\"output_tokens\":2}}}\n\n",
+			want: Usage{Model: "gpt-4.1", PromptTokens: 9, CompletionTokens: 2},
+		},
+		{
+			name:        "truncated json uses last usage object",
+			contentType: "application/json",
+			body:        `{"model":"gpt-4o","usage":{"prompt_tokens":1,"completion_tokens":2},"choices":[{"message":{"content":"long`,
+			want:        Usage{PromptTokens: 1, CompletionTokens: 2},
+		},
+		{
+			name:        "truncated gemini uses usageMetadata",
+			contentType: "application/json",
+			body:        `{"candidates":[],"usageMetadata": {"promptTokenCount":4,"candidatesTokenCount":5}, "modelVersion":"gemini`,
+			want:        Usage{PromptTokens: 4, CompletionTokens: 5},
+		},
+		{
+			name:        "no usage",
+			contentType: "application/json",
+			complete:    true,
+			body:        `{"error":{"message":"bad request"}}`,
+			want:        Usage{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract([]byte(tt.body), tt.contentType, tt.complete)
+			if got != tt.want {
+				t.Errorf("Extract = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageTotals(t *testing.T) {
+	u := Usage{PromptTokens: 3, CompletionTokens: 4}
+	if u.TotalTokens() != 7 || u.IsZero() {
+		t.Errorf("TotalTokens = %d, IsZero = %v", u.TotalTokens(), u.IsZero())
+	}
+	if !(Usage{Model: "gpt-4o"}).IsZero() {
+		t.Error("只有模型名时应视为没有用量")
+	}
+}
+
+func TestPriceTable(t *testing.T) {
+	table := NewPriceTable(map[string]config.ModelPrice{
+		"gpt-4o":          {Input: 2.5, Output: 10},
+		"gpt-4o*":         {Input: 5, Output: 15},
+		"gpt-4o-mini*":    {Input: 0.15, Output: 0.6},
+		"claude-?-haiku":  {Input: 0.8, Output: 4},
+		"Gemini-2.0-Pro":  {Input: 1, Output: 2},
+		"text-embedding*": {Input: 0.02},
+	})
+	tests := []struct {
+		name   string
+		model  string
+		want   config.ModelPrice
+		wantOK bool
+	}{
+		{name: "exact beats pattern", model: "gpt-4o", want: config.ModelPrice{Input: 2.5, Output: 10}, wantOK: true},
+		{name: "exact is case insensitive", model: "gemini-2.0-pro", want: config.ModelPrice{Input: 1, Output: 2}, wantOK: true},
+		{name: "longest pattern wins", model: "gpt-4o-mini-2024-07-18", want: config.ModelPrice{Input: 0.15, Output: 0.6}, wantOK: true},
+		{name: "shorter pattern", model: "gpt-4o-2024-08-06", want: config.ModelPrice{Input: 5, Output: 15}, wantOK: true},
+		{name: "question mark matches one character", model: "claude-3-haiku", want: config.ModelPrice{Input: 0.8, Output: 4}, wantOK: true},
+		{name: "question mark does not match two", model: "claude-35-haiku"},
+		{name: "pattern is case insensitive", model: "TEXT-EMBEDDING-3-small", want: config.ModelPrice{Input: 0.02}, wantOK: true},
+		{name: "pattern is anchored", model: "my-gpt-4o"},
+		{name: "empty model", model: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.Lookup(tt.model)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Lookup(%q) = %+v, %v", tt.model, got, ok)
+			}
+		})
+	}
+
+	var nilTable *PriceTable
+	if _, ok := nilTable.Lookup("gpt-4o"); ok {
+		t.Error("nil 价格表不应命中")
+	}
+}
+
+func TestPriceTableCost(t *testing.T) {
+	table := NewPriceTable(map[string]config.ModelPrice{
+		"gpt-4o":          {Input: 2.5, Output: 10},
+		"text-embedding*": {Input: 0.02},
+	})
+	tests := []struct {
+		name  string
+		usage Usage
+		want  float64
+	}{
+		{name: "per million tokens", usage: Usage{Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 500}, want: 0.0075},
+		{name: "input only", usage: Usage{Model: "text-embedding-3-small", PromptTokens: 2_000_000}, want: 0.04},
+		{name: "unpriced model", usage: Usage{Model: "unknown", PromptTokens: 1000}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := table.Cost(tt.usage); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
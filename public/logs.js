@@ -0,0 +1,137 @@
+const { createApp, ref, onMounted } = Vue;
+createApp({
+    setup() {
+        const filters = ref({
+            service: '',
+            host: '',
+            uri: '',
+            clientIp: '',
+            requestId: '',
+            status: '',
+            minLatency: null,
+            from: '',
+            to: '',
+            sort: 'id:desc'
+        });
+        const logs = ref([]);
+        const nextCursor = ref('');
+        const loading = ref(false);
+        const error = ref('');
+        const searched = ref(false);
+        const isDark = ref(false);
+        const storage = ref(null); // 统计数据的存储方式，内存模式下提示数据不会持久化
+
+        // 将过滤条件转换为 /api/logs 的查询参数
+        function buildParams(cursor) {
+            const f = filters.value;
+            const params = new URLSearchParams();
+            if (f.service) params.set('service', f.service);
+            if (f.host) params.set('host', f.host);
+            if (f.uri) params.set('uri', f.uri);
+            if (f.clientIp) params.set('client_ip', f.clientIp);
+            if (f.requestId) params.set('request_id', f.requestId);
+            if (f.status) {
+                const [min, max] = f.status.split('-');
+                params.set('status_min', min);
+                params.set('status_max', max);
+            }
+            if (f.minLatency > 0) params.set('min_latency', String(f.minLatency));
+            // datetime-local 为本地时间，转换为 RFC3339
+            if (f.from) params.set('from', new Date(f.from).toISOString());
+            if (f.to) params.set('to', new Date(f.to).toISOString());
+            const [sort, order] = f.sort.split(':');
+            params.set('sort', sort);
+            params.set('order', order);
+            if (cursor) params.set('cursor', cursor);
+            return params;
+        }
+
+        async function fetchPage(cursor) {
+            loading.value = true;
+            error.value = '';
+            try {
+                const res = await fetch('/api/logs?' + buildParams(cursor).toString());
+                const data = await res.json();
+                if (!res.ok) throw new Error(data.error || ('请求失败，状态码：' + res.status));
+                logs.value = cursor ? logs.value.concat(data.items) : data.items;
+                nextCursor.value = data.next_cursor || '';
+            } catch (e) {
+                error.value = e.message || '查询失败';
+            }
+            searched.value = true;
+            loading.value = false;
+        }
+
+        function search() {
+            logs.value = [];
+            nextCursor.value = '';
+            fetchPage('');
+        }
+
+        function loadMore() {
+            if (nextCursor.value) fetchPage(nextCursor.value);
+        }
+
+        function formatBytes(n) {
+            if (!n) return '0 B';
+            const units = ['B', 'KB', 'MB', 'GB'];
+            let i = 0;
+            while (n >= 1024 && i < units.length - 1) {
+                n /= 1024;
+                i++;
+            }
+            return (i === 0 ? n : n.toFixed(1)) + ' ' + units[i];
+        }
+
+        function statusClass(code) {
+            if (code >= 500) return 'log-status-error';
+            if (code >= 400) return 'log-status-warn';
+            return 'log-status-ok';
+        }
+
+        const updateHtmlClass = (darkMode) => {
+            if (darkMode) {
+                document.documentElement.classList.add('dark');
+            } else {
+                document.documentElement.classList.remove('dark');
+            }
+        };
+
+        const toggleDarkMode = () => {
+            isDark.value = !isDark.value;
+            localStorage.setItem('darkMode', String(isDark.value));
+            updateHtmlClass(isDark.value);
+        };
+
+        onMounted(() => {
+            const storedDarkMode = localStorage.getItem('darkMode');
+            if (storedDarkMode !== null) {
+                isDark.value = storedDarkMode === 'true';
+            } else {
+                isDark.value = document.documentElement.classList.contains('dark');
+            }
+            updateHtmlClass(isDark.value);
+            fetch('/api/stats/info')
+                .then(res => res.ok ? res.json() : null)
+                .then(info => { storage.value = info; })
+                .catch(() => {});
+            search();
+        });
+
+        return {
+            filters,
+            logs,
+            nextCursor,
+            loading,
+            error,
+            searched,
+            isDark,
+            storage,
+            search,
+            loadMore,
+            statusClass,
+            formatBytes,
+            toggleDarkMode
+        };
+    }
+}).mount('#app');
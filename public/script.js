@@ -57,7 +57,7 @@ const ProxyListItem = {
                     <span class="stat-badge stat-badge-requests">
                         {{ proxy.request_count }} 请求
                     </span>
-                    <span class="stat-badge stat-badge-time">
+                    <span class="stat-badge stat-badge-time" :title="'P50 ' + Math.round(proxy.p50) + 'ms / P95 ' + Math.round(proxy.p95) + 'ms / P99 ' + Math.round(proxy.p99) + 'ms'">
                         {{ Math.round(proxy.response_time) }}ms
                     </span>
                     <button @click="copyProxyUrl(proxy)" class="copy-proxy-btn">
@@ -107,6 +107,14 @@ const ChartCard = {
     `
 }
 
+const HourlyCard = {
+    template: `
+        <div class="chart-card">
+            <div id="hourlyChart"></div>
+        </div>
+    `
+}
+
 const DistributionCard = {
     template: `
         <div class="chart-card">
@@ -124,7 +132,10 @@ const app = createApp({
         const isDark = ref(false)
         const dailyChartInstance = ref(null)
         const distributionChartInstance = ref(null)
+        const hourlyStats = ref([])
+        const hourlyChartInstance = ref(null)
         const loading = ref(true)
+        const storage = ref(null) // 统计数据的存储方式，内存模式下提示数据不会持久化
 
         // 计算所有有效代理的平均响应时间
         const avgResponseTime = computed(() => {
@@ -183,6 +194,7 @@ const app = createApp({
             updateHtmlClass(isDark.value);
             // 重新渲染图表以适应主题变化
             initDailyChart();
+            initHourlyChart();
             initDistributionChart();
         };
 
@@ -361,6 +373,84 @@ const app = createApp({
             }
         }
 
+        // 初始化最近24小时图表 - 柱状图为请求数，折线为错误率与 P95 响应时间
+        const initHourlyChart = () => {
+            const chartEl = document.getElementById('hourlyChart')
+            if (!chartEl || typeof ApexCharts === 'undefined') {
+                return
+            }
+            if (hourlyChartInstance.value) {
+                hourlyChartInstance.value.destroy();
+            }
+            if (!hourlyStats.value.length) {
+                return
+            }
+
+            const colors = getChartColors()
+            const labels = hourlyStats.value.map(item => item.hour.slice(11))
+            const axisLabelStyle = { colors: colors.foreColor, fontSize: '12px' }
+
+            const options = {
+                series: [
+                    { name: '调用次数', type: 'column', data: hourlyStats.value.map(item => item.request_count) },
+                    { name: '错误率', type: 'line', data: hourlyStats.value.map(item => +(item.error_rate * 100).toFixed(2)) },
+                    { name: 'P95', type: 'line', data: hourlyStats.value.map(item => Math.round(item.p95)) }
+                ],
+                chart: {
+                    height: 280,
+                    background: colors.background,
+                    foreColor: colors.foreColor,
+                    fontFamily: 'Inter, system-ui, sans-serif',
+                    toolbar: { show: false }
+                },
+                colors: [colors.primary, '#EF4444', '#3B82F6'],
+                stroke: { width: [0, 2, 2], curve: 'smooth' },
+                plotOptions: { bar: { columnWidth: '60%', borderRadius: 3 } },
+                dataLabels: { enabled: false },
+                title: {
+                    text: '最近24小时',
+                    align: 'left',
+                    style: {
+                        fontSize: '16px',
+                        fontWeight: 600,
+                        color: isDark.value ? '#F1F5F9' : '#1E293B'
+                    }
+                },
+                grid: { show: true, borderColor: colors.gridColor, strokeDashArray: 4 },
+                xaxis: {
+                    categories: labels,
+                    axisBorder: { show: false },
+                    axisTicks: { show: false },
+                    labels: { style: axisLabelStyle, rotate: 0, hideOverlappingLabels: true }
+                },
+                yaxis: [
+                    { seriesName: '调用次数', labels: { style: axisLabelStyle, formatter: (value) => Math.round(value) } },
+                    { seriesName: '错误率', opposite: true, max: 100, labels: { style: axisLabelStyle, formatter: (value) => `${Math.round(value)}%` } },
+                    { seriesName: 'P95', opposite: true, labels: { style: axisLabelStyle, formatter: (value) => `${Math.round(value)}ms` } }
+                ],
+                legend: { labels: { colors: colors.foreColor } },
+                tooltip: {
+                    theme: isDark.value ? 'dark' : 'light',
+                    shared: true,
+                    intersect: false,
+                    y: {
+                        formatter: (value, { seriesIndex }) => {
+                            if (seriesIndex === 1) return `${value}%`
+                            if (seriesIndex === 2) return `${value}ms`
+                            return `${value.toLocaleString()} 次请求`
+                        }
+                    }
+                }
+            }
+
+            try {
+                hourlyChartInstance.value = new ApexCharts(chartEl, options)
+                hourlyChartInstance.value.render()
+            } catch (error) {
+                console.error('初始化小时图表时出错:', error)
+            }
+        }
+
         // 初始化请求分布图表 - 使用径向条形图
         const initDistributionChart = () => {
             const chartEl = document.getElementById('distributionChart')
@@ -541,15 +631,19 @@ const app = createApp({
             updateHtmlClass(isDark.value);
 
             try {
-                const [proxyRes, dailyRes, distRes] = await Promise.all([
+                const [proxyRes, dailyRes, distRes, hourlyRes, infoRes] = await Promise.all([
                     fetch('/api/stats'),
                     fetch('/api/stats/daily'),
-                    fetch('/api/stats/distribution')
+                    fetch('/api/stats/distribution'),
+                    fetch('/api/stats/hourly'),
+                    fetch('/api/stats/info')
                 ])
 
                 proxies.value = proxyRes.ok ? await proxyRes.json() : []
                 dailyStats.value = dailyRes.ok ? await dailyRes.json() : []
                 serviceDistribution.value = distRes.ok ? await distRes.json() : []
+                hourlyStats.value = hourlyRes.ok ? await hourlyRes.json() : []
+                storage.value = infoRes.ok ? await infoRes.json() : null
             } catch (error) {
                 console.error('获取代理统计信息时出错:', error);
             } finally {
@@ -557,6 +651,7 @@ const app = createApp({
                 // 等待 UI 从 loading 切换到内容再初始化图表
                 await Vue.nextTick();
                 initDailyChart();
+                initHourlyChart();
                 initDistributionChart();
             }
         });
@@ -566,9 +661,11 @@ const app = createApp({
             sortOrder,
             isDark,
             loading,
+            storage,
             avgResponseTime,
             sortedProxies,
             dailyStats,
+            hourlyStats,
             serviceDistribution,
             sortByRequests,
             toggleDarkMode,
@@ -580,6 +677,7 @@ const app = createApp({
 app.component('proxy-list-item', ProxyListItem)
 app.component('stat-card', StatCard)
 app.component('chart-card', ChartCard)
+app.component('hourly-card', HourlyCard)
 app.component('distribution-card', DistributionCard)
 
 app.mount('#app')